@@ -2,11 +2,22 @@ package assembly
 
 import (
 	"context"
+	"net"
 	"time"
 
+	"isp-gate-service/audit"
 	"isp-gate-service/cache"
+	"isp-gate-service/clientip"
 	"isp-gate-service/conf"
+	"isp-gate-service/domain"
+	"isp-gate-service/gatemetrics"
+	"isp-gate-service/internaltoken"
+	"isp-gate-service/middleware"
+	"isp-gate-service/proxyprotocol"
+	"isp-gate-service/repository"
+	"isp-gate-service/revocation"
 	"isp-gate-service/routes"
+	"isp-gate-service/service"
 
 	"github.com/pkg/errors"
 	"github.com/txix-open/isp-kit/app"
@@ -16,11 +27,39 @@ import (
 	"github.com/txix-open/isp-kit/http"
 	"github.com/txix-open/isp-kit/lb"
 	"github.com/txix-open/isp-kit/log"
+	"github.com/txix-open/isp-kit/metrics"
 )
 
 const (
-	routerModuleName            = "isp-router-service"
-	usersAuthCachePurgeInterval = 5 * time.Second
+	routerModuleName                  = "isp-router-service"
+	usersAuthCachePurgeInterval       = 5 * time.Second
+	defaultProxyProtocolHeaderTimeout = 5 * time.Second
+	defaultMaxClockSkew               = 5 * time.Minute
+	defaultInternalTokenHeader        = "x-gate-token"
+	defaultInternalTokenTtl           = 60 * time.Second
+	defaultInternalTokenIssuer        = "isp-gate-service"
+	defaultRevocationDenylistTtl      = 60 * time.Second
+	revocationPollInterval            = 1 * time.Second
+	authCachePurgeInterval            = 30 * time.Second
+	defaultMaxBodyLogBytes            = 64 * 1024
+	defaultMetricsApplicationIdsLimit = 100
+
+	authenticationCacheName     = "authentication"
+	userAuthenticationCacheName = "user_authentication"
+	applicationSecretCacheName  = "application_secret"
+	authorizationCacheName      = "authorization"
+	adminCacheName              = "admin"
+)
+
+var (
+	defaultBodyLogContentTypes = []string{ // nolint:gochecknoglobals
+		"application/json",
+		"application/*+json",
+		"application/xml",
+		"application/*+xml",
+		"text/*",
+		"application/x-www-form-urlencoded",
+	}
 )
 
 type Assembly struct {
@@ -34,10 +73,21 @@ type Assembly struct {
 	routerLb  *lb.RoundRobin
 
 	locations                   []conf.Location
+	proxyProtocol               conf.ProxyProtocol
+	proxyProtocolSources        clientip.Networks
 	grpcClientByModuleName      map[string]*client.Client
 	httpHostManagerByModuleName map[string]*lb.RoundRobin
 
-	usersAuthCache *cache.Cache
+	usersAuthCache      *cache.Cache
+	appAuthCache        *cache.Cache
+	appSecretCache      *cache.Cache
+	revocationCache     *cache.Cache
+	adminCache          *cache.Cache
+	revocationService   service.Revocation
+	auditWriter         *audit.Writer
+	internalTokenSigner *internaltoken.Signer
+	bodyMaskingHashKey  []byte
+	gateMetrics         *gatemetrics.Storage
 }
 
 func New(boot *bootstrap.Bootstrap) (*Assembly, error) {
@@ -49,6 +99,34 @@ func New(boot *bootstrap.Bootstrap) (*Assembly, error) {
 		return nil, errors.WithMessage(err, "read local config")
 	}
 
+	proxyProtocolSources, err := clientip.ParseNetworks(localConfig.ProxyProtocol.TrustedSources)
+	if err != nil {
+		return nil, errors.WithMessage(err, "parse proxy protocol trusted sources")
+	}
+
+	var internalTokenSigner *internaltoken.Signer
+	if localConfig.InternalToken.PrivateKeyPath != "" {
+		internalTokenSigner, err = internaltoken.LoadSigner(
+			localConfig.InternalToken.PrivateKeyPath,
+			localConfig.InternalToken.KeyId,
+		)
+		if err != nil {
+			return nil, errors.WithMessage(err, "load internal token signer")
+		}
+		boot.InfraServer.Handle(internaltoken.JwksPath, internaltoken.JwksHandler(internalTokenSigner))
+	}
+
+	var auditWriter *audit.Writer
+	if localConfig.Audit.Enable {
+		auditWriter = audit.NewFileWriter(localConfig.Audit)
+	}
+
+	usersAuthCache := cache.New()
+	appAuthCache := cache.New()
+	appSecretCache := cache.New()
+	revocationCache := cache.New()
+	adminCache := cache.New()
+
 	grpcClientByModuleName := make(map[string]*client.Client)
 	httpHostManagerByModuleName := make(map[string]*lb.RoundRobin)
 	for _, location := range localConfig.Locations {
@@ -79,6 +157,35 @@ func New(boot *bootstrap.Bootstrap) (*Assembly, error) {
 	if err != nil {
 		return nil, errors.WithMessage(err, "create isp-lock-service client")
 	}
+	gateMetrics := gatemetrics.NewStorage(metrics.DefaultRegistry)
+
+	denylistTtl := defaultRevocationDenylistTtl
+	if localConfig.Revocation.DenylistTtlInSec > 0 {
+		denylistTtl = time.Duration(localConfig.Revocation.DenylistTtlInSec) * time.Second
+	}
+	revocationService := service.NewRevocation(
+		repository.NewRevocationList(revocationCache),
+		repository.NewAuthenticationCache(appAuthCache, 0),
+		repository.NewApplicationSecretCache(appSecretCache, 0),
+		repository.NewUserAuthenticationCache(usersAuthCache),
+		repository.NewAdminCache(adminCache, 0),
+		repository.NewRevocationEvents(lockerCli, gateMetrics),
+		denylistTtl,
+		boot.App.Logger(),
+	)
+	adminService := service.NewAdmin(
+		repository.NewAdminCache(cache.New(), 0),
+		repository.NewAdmin(adminCli),
+		repository.NewRevocationList(revocationCache),
+		0,
+		0,
+	)
+	boot.InfraServer.Handle(domain.RevocationPath, revocation.Handler(
+		revocationService,
+		adminService,
+		localConfig.Revocation.AdminPermission,
+		boot.App.Logger(),
+	))
 
 	return &Assembly{
 		boot:                        boot,
@@ -86,13 +193,24 @@ func New(boot *bootstrap.Bootstrap) (*Assembly, error) {
 		logger:                      boot.App.Logger(),
 		routes:                      routes.NewRoutes(boot.App.Logger()),
 		locations:                   localConfig.Locations,
+		proxyProtocol:               localConfig.ProxyProtocol,
+		proxyProtocolSources:        proxyProtocolSources,
 		grpcClientByModuleName:      grpcClientByModuleName,
 		httpHostManagerByModuleName: httpHostManagerByModuleName,
 		systemCli:                   systemCli,
 		adminCli:                    adminCli,
 		lockerCli:                   lockerCli,
 		routerLb:                    lb.NewRoundRobin(nil),
-		usersAuthCache:              cache.New(),
+		usersAuthCache:              usersAuthCache,
+		appAuthCache:                appAuthCache,
+		appSecretCache:              appSecretCache,
+		revocationCache:             revocationCache,
+		adminCache:                  adminCache,
+		revocationService:           revocationService,
+		auditWriter:                 auditWriter,
+		internalTokenSigner:         internalTokenSigner,
+		bodyMaskingHashKey:          []byte(localConfig.BodyMasking.HashKey),
+		gateMetrics:                 gateMetrics,
 	}, nil
 }
 
@@ -106,18 +224,41 @@ func (a *Assembly) ReceiveConfig(ctx context.Context, remoteConfig []byte) error
 		a.logger.Fatal(ctx, errors.WithMessage(err, "upgrade remote config"))
 	}
 	a.logger.SetLevel(newCfg.Logging.LogLevel)
+	// запросы логируются через общий логгер,уровень правила ниже общего уровня отфильтровывается
+	for i, override := range newCfg.Logging.Overrides {
+		if override.LogLevel < newCfg.Logging.LogLevel {
+			a.logger.Warn(ctx, "logging override level is below global log level, successful requests won't be logged",
+				log.Int("override", i),
+				log.String("overrideLevel", override.LogLevel.String()),
+				log.String("logLevel", newCfg.Logging.LogLevel.String()),
+			)
+		}
+	}
 
-	locator := NewLocator(
-		a.logger,
-		a.grpcClientByModuleName,
-		a.httpHostManagerByModuleName,
-		a.routes,
-		a.systemCli,
-		a.adminCli,
-		a.lockerCli,
-		a.routerLb,
-		a.usersAuthCache,
-	)
+	// nil *audit.Writer в интерфейсе не равен nil,поэтому отключенный журнал передается явно
+	var auditWriter middleware.AuditWriter
+	if a.auditWriter != nil {
+		auditWriter = a.auditWriter
+	}
+	locator := NewLocator(LocatorDeps{
+		Logger:              a.logger,
+		GrpcClients:         a.grpcClientByModuleName,
+		HttpHostManagers:    a.httpHostManagerByModuleName,
+		Routes:              a.routes,
+		SystemCli:           a.systemCli,
+		AdminCli:            a.adminCli,
+		LockerCli:           a.lockerCli,
+		RouterLb:            a.routerLb,
+		UsersAuthCache:      a.usersAuthCache.WithMetrics(userAuthenticationCacheName, a.gateMetrics),
+		AppAuthCache:        a.appAuthCache.WithMetrics(authenticationCacheName, a.gateMetrics),
+		AppSecretCache:      a.appSecretCache.WithMetrics(applicationSecretCacheName, a.gateMetrics),
+		RevocationCache:     a.revocationCache,
+		AdminCache:          a.adminCache.WithMetrics(adminCacheName, a.gateMetrics),
+		AuditWriter:         auditWriter,
+		InternalTokenSigner: a.internalTokenSigner,
+		BodyMaskingHashKey:  a.bodyMaskingHashKey,
+		GateMetrics:         a.gateMetrics,
+	})
 	handler, err := locator.Handler(newCfg, a.locations)
 	if err != nil {
 		return errors.WithMessage(err, "locator handler")
@@ -133,20 +274,23 @@ func (a *Assembly) Runners() []app.Runner {
 		RoutesReceiver(a.routes).
 		RemoteConfigReceiver(a)
 
+	requireModule := func(moduleName string, upgrader cluster.HostsUpgrader) {
+		eventHandler.RequireModule(moduleName, gatemetrics.ObserveHosts(a.gateMetrics, moduleName, upgrader))
+	}
 	for moduleName, upgrader := range a.grpcClientByModuleName {
-		eventHandler.RequireModule(moduleName, upgrader)
+		requireModule(moduleName, upgrader)
 	}
 	for moduleName, upgrader := range a.httpHostManagerByModuleName {
-		eventHandler.RequireModule(moduleName, upgrader)
+		requireModule(moduleName, upgrader)
 	}
-	eventHandler.RequireModule("isp-system-service", a.systemCli)
-	eventHandler.RequireModule("msp-admin-service", a.adminCli)
-	eventHandler.RequireModule("isp-lock-service", a.lockerCli)
-	eventHandler.RequireModule(routerModuleName, a.routerLb)
+	requireModule("isp-system-service", a.systemCli)
+	requireModule("msp-admin-service", a.adminCli)
+	requireModule("isp-lock-service", a.lockerCli)
+	requireModule(routerModuleName, a.routerLb)
 
 	return []app.Runner{
 		app.RunnerFunc(func(ctx context.Context) error {
-			return a.server.ListenAndServe(a.boot.BindingAddress)
+			return a.listenAndServe(ctx)
 		}),
 		app.RunnerFunc(func(ctx context.Context) error {
 			return a.boot.ClusterCli.Run(ctx, eventHandler)
@@ -155,9 +299,46 @@ func (a *Assembly) Runners() []app.Runner {
 			a.usersAuthCache.StartCleaner(ctx, usersAuthCachePurgeInterval)
 			return nil
 		}),
+		app.RunnerFunc(func(ctx context.Context) error {
+			a.appAuthCache.StartCleaner(ctx, authCachePurgeInterval)
+			return nil
+		}),
+		app.RunnerFunc(func(ctx context.Context) error {
+			a.appSecretCache.StartCleaner(ctx, authCachePurgeInterval)
+			return nil
+		}),
+		app.RunnerFunc(func(ctx context.Context) error {
+			a.revocationCache.StartCleaner(ctx, authCachePurgeInterval)
+			return nil
+		}),
+		app.RunnerFunc(func(ctx context.Context) error {
+			a.adminCache.StartCleaner(ctx, authCachePurgeInterval)
+			return nil
+		}),
+		app.RunnerFunc(func(ctx context.Context) error {
+			a.revocationService.Subscribe(ctx, revocationPollInterval)
+			return nil
+		}),
 	}
 }
 
+func (a *Assembly) listenAndServe(ctx context.Context) error {
+	if !a.proxyProtocol.Enable {
+		return a.server.ListenAndServe(a.boot.BindingAddress)
+	}
+
+	var lc net.ListenConfig
+	listener, err := lc.Listen(ctx, "tcp", a.boot.BindingAddress)
+	if err != nil {
+		return errors.WithMessagef(err, "listen: %s", a.boot.BindingAddress)
+	}
+	headerTimeout := defaultProxyProtocolHeaderTimeout
+	if a.proxyProtocol.HeaderTimeoutInSec > 0 {
+		headerTimeout = time.Duration(a.proxyProtocol.HeaderTimeoutInSec) * time.Second
+	}
+	return a.server.Serve(proxyprotocol.NewListener(listener, a.proxyProtocolSources, headerTimeout))
+}
+
 func (a *Assembly) Closers() []app.Closer {
 	closers := []app.Closer{
 		a.boot.ClusterCli,
@@ -169,6 +350,9 @@ func (a *Assembly) Closers() []app.Closer {
 		closers = append(closers, cliCloser)
 	}
 	closers = append(closers, a.systemCli, a.adminCli, a.lockerCli)
+	if a.auditWriter != nil {
+		closers = append(closers, a.auditWriter)
+	}
 
 	return closers
 }
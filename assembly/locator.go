@@ -9,7 +9,11 @@ import (
 	"github.com/txix-open/isp-kit/metrics/http_metrics"
 
 	"isp-gate-service/cache"
+	"isp-gate-service/clientip"
 	"isp-gate-service/conf"
+	"isp-gate-service/domain"
+	"isp-gate-service/gatemetrics"
+	"isp-gate-service/internaltoken"
 	"isp-gate-service/middleware"
 	"isp-gate-service/proxy"
 	"isp-gate-service/repository"
@@ -21,6 +25,7 @@ import (
 	"github.com/txix-open/isp-kit/grpc/client"
 	"github.com/txix-open/isp-kit/lb"
 	"github.com/txix-open/isp-kit/log"
+	"google.golang.org/grpc/codes"
 )
 
 type Locator struct {
@@ -33,29 +38,61 @@ type Locator struct {
 	lockerCli                   *client.Client
 	routerLb                    *lb.RoundRobin
 	usersAuthCache              *cache.Cache
+	appAuthCache                *cache.Cache
+	appSecretCache              *cache.Cache
+	revocationCache             *cache.Cache
+	adminCache                  *cache.Cache
+	auditWriter                 middleware.AuditWriter
+	internalTokenSigner         *internaltoken.Signer
+	bodyMaskingHashKey          []byte
+	gateMetrics                 *gatemetrics.Storage
 }
 
-func NewLocator(
-	logger log.Logger,
-	grpcClientByModuleName map[string]*client.Client,
-	httpHostManagerByModuleName map[string]*lb.RoundRobin,
-	routes *routes.Routes,
-	systemCli *client.Client,
-	adminCli *client.Client,
-	lockerCli *client.Client,
-	routerLb *lb.RoundRobin,
-	usersAuthCache *cache.Cache,
-) Locator {
+type LocatorDeps struct {
+	Logger              log.Logger
+	GrpcClients         map[string]*client.Client
+	HttpHostManagers    map[string]*lb.RoundRobin
+	Routes              *routes.Routes
+	SystemCli           *client.Client
+	AdminCli            *client.Client
+	LockerCli           *client.Client
+	RouterLb            *lb.RoundRobin
+	UsersAuthCache      *cache.Cache
+	AppAuthCache        *cache.Cache
+	AppSecretCache      *cache.Cache
+	RevocationCache     *cache.Cache
+	AdminCache          *cache.Cache
+	AuditWriter         middleware.AuditWriter
+	InternalTokenSigner *internaltoken.Signer
+	BodyMaskingHashKey  []byte
+	// GateMetrics хранилище метрик живет дольше Locator,который создается при каждом обновлении конфигурации,
+	// при отсутствии создается хранилище в metrics.DefaultRegistry
+	GateMetrics *gatemetrics.Storage
+}
+
+func NewLocator(deps LocatorDeps) Locator {
+	gateMetrics := deps.GateMetrics
+	if gateMetrics == nil {
+		gateMetrics = gatemetrics.NewStorage(metrics.DefaultRegistry)
+	}
 	return Locator{
-		logger:                      logger,
-		grpcClientByModuleName:      grpcClientByModuleName,
-		httpHostManagerByModuleName: httpHostManagerByModuleName,
-		routes:                      routes,
-		systemCli:                   systemCli,
-		adminCli:                    adminCli,
-		lockerCli:                   lockerCli,
-		routerLb:                    routerLb,
-		usersAuthCache:              usersAuthCache,
+		logger:                      deps.Logger,
+		grpcClientByModuleName:      deps.GrpcClients,
+		httpHostManagerByModuleName: deps.HttpHostManagers,
+		routes:                      deps.Routes,
+		systemCli:                   deps.SystemCli,
+		adminCli:                    deps.AdminCli,
+		lockerCli:                   deps.LockerCli,
+		routerLb:                    deps.RouterLb,
+		usersAuthCache:              deps.UsersAuthCache,
+		appAuthCache:                deps.AppAuthCache,
+		appSecretCache:              deps.AppSecretCache,
+		revocationCache:             deps.RevocationCache,
+		adminCache:                  deps.AdminCache,
+		auditWriter:                 deps.AuditWriter,
+		internalTokenSigner:         deps.InternalTokenSigner,
+		bodyMaskingHashKey:          deps.BodyMaskingHashKey,
+		gateMetrics:                 gateMetrics,
 	}
 }
 
@@ -63,8 +100,12 @@ func (l Locator) Handler(config conf.Remote, locations []conf.Location) (http.Ha
 	systemRepo := repository.NewSystem(l.systemCli)
 	adminRepo := repository.NewAdmin(l.adminCli)
 
-	authenticationCache := repository.NewAuthenticationCache(time.Duration(config.Caching.AuthenticationDataInSec) * time.Second)
-	authentication := service.NewAuthentication(authenticationCache, systemRepo)
+	revocationList := repository.NewRevocationList(l.revocationCache)
+	authenticationCache := repository.NewAuthenticationCache(
+		l.appAuthCache,
+		time.Duration(config.Caching.AuthenticationDataInSec)*time.Second,
+	)
+	authentication := service.NewAuthentication(authenticationCache, systemRepo, revocationList)
 
 	userAuthenticationCache := repository.NewUserAuthenticationCache(l.usersAuthCache)
 	userAuthRepo := repository.NewUserAuth(l.routerLb)
@@ -72,22 +113,91 @@ func (l Locator) Handler(config conf.Remote, locations []conf.Location) (http.Ha
 		config.CustomAuth,
 		userAuthenticationCache,
 		userAuthRepo,
+		repository.NewIntrospection(),
+		revocationList,
 	)
 	if err != nil {
 		return nil, errors.WithMessage(err, "new user authentication")
 	}
 
+	maxClockSkew := defaultMaxClockSkew
+	if config.RequestSigning.MaxClockSkewInSec > 0 {
+		maxClockSkew = time.Duration(config.RequestSigning.MaxClockSkewInSec) * time.Second
+	}
+	requestSigning := service.NewRequestSigning(
+		repository.NewApplicationSecretCache(l.appSecretCache, time.Duration(config.Caching.AuthenticationDataInSec)*time.Second),
+		systemRepo,
+		repository.NewNonceRegistry(l.lockerCli, l.gateMetrics),
+		revocationList,
+		maxClockSkew,
+	)
+	signedAuthenticateConfig := middleware.SignedAuthenticateConfig{
+		Enable:        config.RequestSigning.Enable,
+		SignedHeaders: config.RequestSigning.SignedHeaders,
+	}
+
+	tokenProviders, err := service.NewTokenProviders(config.CustomAuth.TokenProviders)
+	if err != nil {
+		return nil, errors.WithMessage(err, "new token providers")
+	}
+
+	internalTokenConfig, err := l.internalTokenConfig(config.InternalToken)
+	if err != nil {
+		return nil, errors.WithMessage(err, "internal token config")
+	}
+
 	adminService := service.NewAdmin(
-		repository.NewAuthorizationCache(time.Duration(config.Caching.AuthorizationDataInSec)*time.Second),
+		repository.NewAdminCache(l.adminCache, time.Duration(config.Caching.AuthorizationDataInSec)*time.Second),
 		adminRepo,
+		revocationList,
+		time.Duration(config.Caching.AdminAuthenticationDataInSec)*time.Second,
+		time.Duration(config.Caching.AdminNegativeAuthenticationDataInSec)*time.Second,
 	)
 
+	permissionsRefreshInterval := time.Duration(config.Caching.AuthorizationDataInSec) * time.Second
+	if config.AuthorizationPrefetch.RefreshIntervalInSec > 0 {
+		permissionsRefreshInterval = time.Duration(config.AuthorizationPrefetch.RefreshIntervalInSec) * time.Second
+	}
 	authorization := service.NewAuthorization(
-		repository.NewAuthorizationCache(time.Duration(config.Caching.AuthorizationDataInSec)*time.Second),
+		repository.NewAuthorizationCache(
+			cache.New().WithMetrics(authorizationCacheName, l.gateMetrics),
+			time.Duration(config.Caching.AuthorizationDataInSec)*time.Second,
+		),
 		systemRepo,
+		repository.NewApplicationPermissionsCache(),
+		config.AuthorizationPrefetch.Enable,
+		permissionsRefreshInterval,
+		l.logger,
 	)
 
-	lockRepo := repository.NewLocker(l.lockerCli)
+	accessPolicy, err := service.NewAccessPolicy(config.AccessPolicy)
+	if err != nil {
+		return nil, errors.WithMessage(err, "new access policy")
+	}
+
+	bodyMasking, err := service.NewBodyMasking(config.Logging.BodyMasking, l.bodyMaskingHashKey)
+	if err != nil {
+		return nil, errors.WithMessage(err, "new body masking")
+	}
+
+	bodyLogConfig := middleware.BodyLogConfig{
+		MaxBytes:     config.Logging.MaxBodyLogBytes,
+		ContentTypes: config.Logging.BodyLogContentTypes,
+		BinaryMode:   config.Logging.BinaryBodyLogMode,
+	}
+	if bodyLogConfig.MaxBytes <= 0 {
+		bodyLogConfig.MaxBytes = defaultMaxBodyLogBytes
+	}
+	if len(bodyLogConfig.ContentTypes) == 0 {
+		bodyLogConfig.ContentTypes = defaultBodyLogContentTypes
+	}
+	if bodyLogConfig.BinaryMode == "" {
+		bodyLogConfig.BinaryMode = middleware.HashBinaryBodyLogMode
+	}
+
+	loggingOverrides := service.NewLoggingOverrides(config.Logging.Overrides)
+
+	lockRepo := repository.NewLocker(l.lockerCli, l.gateMetrics)
 	dailyLimitService := service.NewDailyLimit(lockRepo, config.DailyLimits)
 	throttlingService := service.NewThrottling(lockRepo, config.Throttling)
 
@@ -96,25 +206,84 @@ func (l Locator) Handler(config conf.Remote, locations []conf.Location) (http.Ha
 		skipBodyLoggingEndpointPrefixes = append(skipBodyLoggingEndpointPrefixes, strings.TrimPrefix(prefix, "/"))
 	}
 
+	settingByPathPrefix := make(map[string]conf.LocationSetting, len(config.LocationSettings))
+	for _, setting := range config.LocationSettings {
+		settingByPathPrefix[setting.PathPrefix] = setting
+	}
+	problemJsonErrors := config.Http.ErrorFormat == conf.ProblemJsonErrorFormat
+
+	trustedProxies, err := clientip.ParseNetworks(config.ClientIp.TrustedProxies)
+	if err != nil {
+		return nil, errors.WithMessage(err, "parse trusted proxies")
+	}
+	clientIpResolver := clientip.NewResolver(trustedProxies, config.ClientIp.Sources)
+	tracingConfig := middleware.NewTracingConfig()
+	requestIds := service.NewRequestIdGenerator(config.RequestId)
+	stage := func(stage string, timing string, name string, m middleware.Middleware) middleware.Middleware {
+		return middleware.Stage(stage, middleware.TimeStage(timing, middleware.TraceStage(tracingConfig, name, m)))
+	}
+	serverTimingConfig := middleware.ServerTimingConfig{
+		HeaderEnable:          config.ServerTiming.Enable,
+		TrustedApplicationIds: config.ServerTiming.ApplicationIds,
+		AdminEnable:           config.ServerTiming.AdminEnable,
+		SlowRequestThreshold:  time.Duration(config.Logging.SlowRequestThresholdInMs) * time.Millisecond,
+	}
+	applicationIdsLimit := defaultMetricsApplicationIdsLimit
+	if config.Metrics.ApplicationIdsLimit > 0 {
+		applicationIdsLimit = config.Metrics.ApplicationIdsLimit
+	}
+
 	mux := mux2.NewRouter()
 	for _, location := range locations {
 		var proxyFunc middleware.Handler
-		enableBodyLog := config.Logging.BodyLogEnable
+		bodyLogSupported := true
+		locationSetting := settingByPathPrefix[location.PathPrefix]
+		headerRules := proxy.NewHeaderRules(locationSetting.RequestHeaderRules, locationSetting.ResponseHeaderRules)
+		tokenExtraction := config.TokenExtraction
+		if locationSetting.TokenExtraction != nil {
+			tokenExtraction = *locationSetting.TokenExtraction
+		}
+		appTokenProviders, adminTokenProviders, err := selectTokenProviders(tokenProviders, tokenExtraction)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "token providers for location '%s'", location.PathPrefix)
+		}
+		ipAccess, err := service.NewIpAccess(config.ApplicationIpRules, locationSetting.IpRule)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "ip access for location '%s'", location.PathPrefix)
+		}
 
 		switch location.Protocol {
 		case conf.GrpcProtocol:
 			cli := l.grpcClientByModuleName[location.TargetModule]
-			proxyFunc = proxy.NewGrpc(cli, location.SkipAuth, time.Duration(config.Http.ProxyTimeoutInSec)*time.Second)
+			statusOverrides, err := grpcStatusOverrides(locationSetting.GrpcStatusMapping)
+			if err != nil {
+				return nil, errors.WithMessagef(err, "grpc status mapping for location '%s'", location.PathPrefix)
+			}
+			proxyFunc = proxy.NewGrpc(
+				cli,
+				location.SkipAuth,
+				time.Duration(config.Http.ProxyTimeoutInSec)*time.Second,
+				config.GrpcProxy.ResponseHeadersAllowlist,
+				statusOverrides,
+				problemJsonErrors,
+				headerRules,
+			)
 		case conf.HttpProtocol:
 			hostManager := l.httpHostManagerByModuleName[location.TargetModule]
 			if location.TargetModule == routerModuleName {
 				hostManager = l.routerLb
 			}
-			proxyFunc = proxy.NewHttp(hostManager, location.SkipAuth, time.Duration(config.Http.ProxyTimeoutInSec)*time.Second)
+			proxyFunc = proxy.NewHttp(
+				hostManager,
+				location.SkipAuth,
+				time.Duration(config.Http.ProxyTimeoutInSec)*time.Second,
+				headerRules,
+				locationSetting.ForwardCredentials,
+			)
 		case conf.WsProtocol:
 			hostManager := l.httpHostManagerByModuleName[location.TargetModule]
-			proxyFunc = proxy.NewWs(hostManager, location.SkipAuth)
-			enableBodyLog = false
+			proxyFunc = proxy.NewWs(hostManager, location.SkipAuth, headerRules, locationSetting.ForwardCredentials)
+			bodyLogSupported = false
 		default:
 			return nil, errors.Errorf("not supported protocol %s", location.Protocol)
 		}
@@ -125,42 +294,77 @@ func (l Locator) Handler(config conf.Remote, locations []conf.Location) (http.Ha
 		}
 
 		metricsStorage := http_metrics.NewServerStorage(metrics.DefaultRegistry)
+		loggerConfig := middleware.LoggerConfig{
+			Location:                        location.PathPrefix,
+			RequestLogEnable:                config.Logging.RequestLogEnable,
+			BodyLogEnable:                   config.Logging.BodyLogEnable,
+			BodyLogSupported:                bodyLogSupported,
+			SkipBodyLoggingEndpointPrefixes: config.Logging.SkipBodyLoggingEndpointPrefixes,
+			EnableForceUnescapingUnicode:    config.Logging.EnableForceUnescapingUnicode,
+			Body:                            bodyLogConfig,
+		}
 
+		locationInternalTokenConfig := internalTokenConfig
+		locationInternalTokenConfig.Audience = location.TargetModule
+
+		requestMetricsConfig := middleware.RequestMetricsConfig{
+			Location:            location.PathPrefix,
+			TargetModule:        location.TargetModule,
+			ApplicationIdsLimit: applicationIdsLimit,
+		}
 		handler := middleware.Chain(
 			proxyFunc,
-			middleware.Logger(
-				l.logger, config.Logging.RequestLogEnable,
-				enableBodyLog,
-				config.Logging.SkipBodyLoggingEndpointPrefixes,
-				config.Logging.EnableForceUnescapingUnicode,
-			),
-			middleware.RequestId(),
-			middleware.ErrorHandler(l.logger),
-			middleware.UserAuthenticate(userAuthentication, l.logger),
-			middleware.Authenticate(authentication),
-			middleware.AdminAuthenticate(adminService),
+			middleware.Tracing(tracingConfig, location.PathPrefix),
+			middleware.RequestsMetrics(l.gateMetrics, requestMetricsConfig),
+			middleware.ServerTiming(serverTimingConfig, l.logger),
+			middleware.ClientIp(clientIpResolver),
+			middleware.Logger(l.logger, loggerConfig, bodyMasking, loggingOverrides),
+			middleware.RequestId(requestIds),
+			middleware.Audit(l.auditWriter, config.Logging.AuditRequestBodyHashEnable, l.logger),
+			middleware.ErrorHandler(l.logger, problemJsonErrors),
+			stage(domain.AuthStage, domain.UserAuthTiming, "userAuthenticate", middleware.UserAuthenticate(userAuthentication, l.logger)),
+			stage(domain.AuthStage, domain.AppAuthTiming, "signedAuthenticate", middleware.SignedAuthenticate(requestSigning, signedAuthenticateConfig)),
+			stage(domain.AuthStage, domain.AppAuthTiming, "authenticate", middleware.Authenticate(authentication, appTokenProviders)),
+			stage(domain.AuthStage, domain.AdminAuthTiming, "adminAuthenticate", middleware.AdminAuthenticate(adminService, adminTokenProviders)),
+			stage(domain.AuthzStage, domain.AuthzTiming, "ipAccess", middleware.IpAccess(ipAccess, l.gateMetrics, l.logger)),
 			middleware.ClientRequestId(config.EnableClientRequestIdForwarding, forwardReqIdByAppId),
-			middleware.Authorize(authorization, l.logger),
-			middleware.AdminAuthorize(adminService),
-			middleware.Throttling(throttlingService),
-			middleware.DailyLimit(dailyLimitService),
+			stage(domain.AuthzStage, domain.AuthzTiming, "accessPolicy", middleware.AccessPolicy(accessPolicy, config.AccessPolicy.DryRun, l.logger)),
+			stage(domain.AuthzStage, domain.AuthzTiming, "authorize", middleware.Authorize(authorization, l.logger)),
+			stage(domain.AuthzStage, domain.AuthzTiming, "userAuthorize", middleware.UserAuthorize()),
+			stage(domain.AuthzStage, domain.AuthzTiming, "adminAuthorize", middleware.AdminAuthorize(adminService)),
+			stage(domain.ThrottleStage, domain.ThrottleTiming, "throttling", middleware.Throttling(throttlingService)),
+			stage(domain.QuotaStage, domain.QuotaTiming, "dailyLimit", middleware.DailyLimit(dailyLimitService)),
 			middleware.Metrics(metricsStorage),
+			stage(domain.ProxyStage, domain.InternalTokenTiming, "internalToken", middleware.InternalToken(l.internalTokenSigner, locationInternalTokenConfig)),
+			middleware.Stage(domain.ProxyStage, middleware.TraceUpstream(tracingConfig, location.TargetModule)),
+			middleware.TimeUpstream(),
 		)
 
 		errorOnUnknownEndpoint := true
 		if location.SkipAuth {
 			errorOnUnknownEndpoint = false
+			skipAuthLoggerConfig := loggerConfig
+			skipAuthLoggerConfig.SkipBodyLoggingEndpointPrefixes = skipBodyLoggingEndpointPrefixes
+			// токен в upstream без аутентификации не передается,но заголовок клиента удаляется
+			skipAuthInternalTokenConfig := locationInternalTokenConfig
+			skipAuthInternalTokenConfig.Enable = false
 			handler = middleware.Chain(
 				proxyFunc,
-				middleware.Logger(l.logger, config.Logging.RequestLogEnable,
-					enableBodyLog,
-					skipBodyLoggingEndpointPrefixes,
-					config.Logging.EnableForceUnescapingUnicode,
-				),
-				middleware.RequestId(),
-				middleware.ErrorHandler(l.logger),
+				middleware.Tracing(tracingConfig, location.PathPrefix),
+				middleware.RequestsMetrics(l.gateMetrics, requestMetricsConfig),
+				middleware.ServerTiming(serverTimingConfig, l.logger),
+				middleware.ClientIp(clientIpResolver),
+				middleware.Logger(l.logger, skipAuthLoggerConfig, bodyMasking, loggingOverrides),
+				middleware.RequestId(requestIds),
+				middleware.Audit(l.auditWriter, config.Logging.AuditRequestBodyHashEnable, l.logger),
+				middleware.ErrorHandler(l.logger, problemJsonErrors),
+				stage(domain.AuthzStage, domain.AuthzTiming, "ipAccess", middleware.IpAccess(ipAccess, l.gateMetrics, l.logger)),
 				middleware.ClientRequestId(config.EnableClientRequestIdForwarding, forwardReqIdByAppId),
+				stage(domain.AuthzStage, domain.AuthzTiming, "accessPolicy", middleware.AccessPolicy(accessPolicy, config.AccessPolicy.DryRun, l.logger)),
 				middleware.Metrics(metricsStorage),
+				stage(domain.ProxyStage, domain.InternalTokenTiming, "internalToken", middleware.InternalToken(l.internalTokenSigner, skipAuthInternalTokenConfig)),
+				middleware.Stage(domain.ProxyStage, middleware.TraceUpstream(tracingConfig, location.TargetModule)),
+				middleware.TimeUpstream(),
 			)
 		}
 		entrypoint := middleware.Entrypoint(
@@ -171,12 +375,97 @@ func (l Locator) Handler(config conf.Remote, locations []conf.Location) (http.Ha
 				PathPrefix:             location.PathPrefix,
 				ErrorOnUnknownEndpoint: errorOnUnknownEndpoint,
 				WithLendingSlash:       location.Protocol != conf.GrpcProtocol,
+				ProblemJsonErrors:      problemJsonErrors,
 			},
 			l.routes,
+			requestIds,
+			l.gateMetrics,
 			l.logger,
 		)
+		if locationSetting.Cors != nil {
+			entrypoint, err = middleware.Cors(corsConfig(*locationSetting.Cors), entrypoint)
+			if err != nil {
+				return nil, errors.WithMessagef(err, "cors for location '%s'", location.PathPrefix)
+			}
+		}
 		mux.PathPrefix(location.PathPrefix).Handler(entrypoint)
 	}
 
 	return mux, nil
 }
+
+func (l Locator) internalTokenConfig(cfg conf.InternalToken) (middleware.InternalTokenConfig, error) {
+	if cfg.Enable && l.internalTokenSigner == nil {
+		return middleware.InternalTokenConfig{}, errors.New("internal token is enabled, but private key is not set in local config")
+	}
+	result := middleware.InternalTokenConfig{
+		Enable:     cfg.Enable,
+		HeaderName: defaultInternalTokenHeader,
+		Issuer:     defaultInternalTokenIssuer,
+		Ttl:        defaultInternalTokenTtl,
+	}
+	if cfg.HeaderName != "" {
+		result.HeaderName = strings.ToLower(cfg.HeaderName)
+	}
+	if cfg.Issuer != "" {
+		result.Issuer = cfg.Issuer
+	}
+	if cfg.TtlInSec > 0 {
+		result.Ttl = time.Duration(cfg.TtlInSec) * time.Second
+	}
+	return result, nil
+}
+
+func grpcStatusOverrides(mapping []conf.GrpcStatusMapping) (map[codes.Code]int, error) {
+	overrides := make(map[codes.Code]int, len(mapping))
+	for _, item := range mapping {
+		code, err := proxy.ParseGrpcCode(item.GrpcCode)
+		if err != nil {
+			return nil, errors.WithMessage(err, "parse grpc code")
+		}
+		overrides[code] = item.HttpStatus
+	}
+	return overrides, nil
+}
+
+func selectTokenProviders(
+	tokenProviders service.TokenProviders,
+	cfg conf.TokenExtraction,
+) ([]middleware.TokenProvider, []middleware.TokenProvider, error) {
+	appProviders, err := tokenProviders.Select(
+		cfg.ApplicationTokenProviders,
+		service.DefaultApplicationTokenProviders(),
+		cfg.ForbidQueryTokens,
+	)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "application token providers")
+	}
+	adminProviders, err := tokenProviders.Select(
+		cfg.AdminTokenProviders,
+		service.DefaultAdminTokenProviders(),
+		cfg.ForbidQueryTokens,
+	)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "admin token providers")
+	}
+	return toMiddlewareTokenProviders(appProviders), toMiddlewareTokenProviders(adminProviders), nil
+}
+
+func toMiddlewareTokenProviders(providers []service.TokenProvider) []middleware.TokenProvider {
+	result := make([]middleware.TokenProvider, 0, len(providers))
+	for _, provider := range providers {
+		result = append(result, provider)
+	}
+	return result
+}
+
+func corsConfig(cfg conf.Cors) middleware.CorsConfig {
+	return middleware.CorsConfig{
+		AllowedOrigins:   cfg.AllowedOrigins,
+		AllowedMethods:   cfg.AllowedMethods,
+		AllowedHeaders:   cfg.AllowedHeaders,
+		ExposedHeaders:   cfg.ExposedHeaders,
+		AllowCredentials: cfg.AllowCredentials,
+		MaxAgeInSec:      cfg.MaxAgeInSec,
+	}
+}
@@ -0,0 +1,62 @@
+package audit
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"isp-gate-service/conf"
+	"isp-gate-service/domain"
+
+	"github.com/pkg/errors"
+	"github.com/txix-open/isp-kit/json"
+	"github.com/txix-open/isp-kit/log/file"
+)
+
+// Writer записывает записи журнала аудита в формате JSON,по одной записи на строку
+type Writer struct {
+	writer io.Writer
+	lock   sync.Mutex
+}
+
+func NewWriter(writer io.Writer) *Writer {
+	return &Writer{
+		writer: writer,
+	}
+}
+
+// NewFileWriter журнал аудита в отдельном файле с ротацией по размеру и возрасту
+func NewFileWriter(cfg conf.Audit) *Writer {
+	return NewWriter(file.NewFileWriter(file.Output{
+		File:       cfg.Path,
+		MaxSizeMb:  cfg.MaxSizeMb,
+		MaxDays:    cfg.MaxDays,
+		MaxBackups: cfg.MaxBackups,
+		Compress:   cfg.Compress,
+	}))
+}
+
+func (w *Writer) Write(ctx context.Context, record domain.AuditRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return errors.WithMessage(err, "json marshal audit record")
+	}
+	data = append(data, '\n')
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	_, err = w.writer.Write(data)
+	if err != nil {
+		return errors.WithMessage(err, "write audit record")
+	}
+	return nil
+}
+
+func (w *Writer) Close() error {
+	closer, ok := w.writer.(io.Closer)
+	if !ok {
+		return nil
+	}
+	return closer.Close()
+}
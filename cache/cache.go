@@ -11,9 +11,17 @@ type Item struct {
 	expiredAt time.Time
 }
 
+type Metrics interface {
+	CountCacheHit(cache string)
+	CountCacheMiss(cache string)
+}
+
 type Cache struct {
 	store map[string]Item
 	lock  *sync.RWMutex
+
+	name    string
+	metrics Metrics
 }
 
 func New() *Cache {
@@ -23,7 +31,29 @@ func New() *Cache {
 	}
 }
 
+// WithMetrics returns a view of the same store which counts hits and misses of Get.
+func (c *Cache) WithMetrics(name string, metrics Metrics) *Cache {
+	return &Cache{
+		store:   c.store,
+		lock:    c.lock,
+		name:    name,
+		metrics: metrics,
+	}
+}
+
 func (c *Cache) Get(key string) ([]byte, bool) {
+	data, ok := c.get(key)
+	if c.metrics != nil {
+		if ok {
+			c.metrics.CountCacheHit(c.name)
+		} else {
+			c.metrics.CountCacheMiss(c.name)
+		}
+	}
+	return data, ok
+}
+
+func (c *Cache) get(key string) ([]byte, bool) {
 	c.lock.RLock()
 	defer c.lock.RUnlock()
 
@@ -49,6 +79,32 @@ func (c *Cache) Set(key string, data []byte, lifeTime time.Duration) {
 	}
 }
 
+func (c *Cache) Delete(key string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	delete(c.store, key)
+}
+
+// DeleteFunc removes all items for which fn returns true.
+func (c *Cache) DeleteFunc(fn func(key string, data []byte) bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for k, v := range c.store {
+		if fn(k, v.data) {
+			delete(c.store, k)
+		}
+	}
+}
+
+func (c *Cache) Clear() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	clear(c.store)
+}
+
 // StartCleaner runs periodic cleanup.
 // Blocking call: intended to be run in a separate goroutine.
 func (c *Cache) StartCleaner(ctx context.Context, interval time.Duration) {
@@ -48,3 +48,65 @@ func TestNilValue(t *testing.T) {
 	require.True(ok)
 	require.Nil(data)
 }
+
+func TestDeleteFunc(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	cache := cache.New()
+	cache.Set("a:1", []byte("1"), 24*time.Hour)
+	cache.Set("a:2", []byte("2"), 24*time.Hour)
+	cache.Set("b:1", []byte("1"), 24*time.Hour)
+
+	cache.DeleteFunc(func(key string, data []byte) bool {
+		return string(data) == "1"
+	})
+
+	_, ok := cache.Get("a:1")
+	require.False(ok)
+	_, ok = cache.Get("b:1")
+	require.False(ok)
+	_, ok = cache.Get("a:2")
+	require.True(ok)
+
+	cache.Clear()
+	_, ok = cache.Get("a:2")
+	require.False(ok)
+}
+
+type countingMetrics struct {
+	hits   map[string]int
+	misses map[string]int
+}
+
+func (m *countingMetrics) CountCacheHit(cache string) {
+	m.hits[cache]++
+}
+
+func (m *countingMetrics) CountCacheMiss(cache string) {
+	m.misses[cache]++
+}
+
+func TestWithMetrics(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	metrics := &countingMetrics{hits: map[string]int{}, misses: map[string]int{}}
+	store := cache.New()
+	observed := store.WithMetrics("auth", metrics)
+	store.Set("key", []byte("data"), 24*time.Hour)
+
+	data, ok := observed.Get("key")
+	require.True(ok)
+	require.EqualValues("data", data)
+	_, ok = observed.Get("key2")
+	require.False(ok)
+	_, ok = store.Get("key2")
+	require.False(ok)
+
+	observed.Delete("key")
+	_, ok = store.Get("key")
+	require.False(ok)
+	require.EqualValues(map[string]int{"auth": 1}, metrics.hits)
+	require.EqualValues(map[string]int{"auth": 1}, metrics.misses)
+}
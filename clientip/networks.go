@@ -0,0 +1,51 @@
+package clientip
+
+import (
+	"net/netip"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type Networks []netip.Prefix
+
+// ParseNetworks разбирает список IP адресов и подсетей в формате CIDR
+func ParseNetworks(values []string) (Networks, error) {
+	networks := make(Networks, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if !strings.Contains(value, "/") {
+			addr, err := netip.ParseAddr(value)
+			if err != nil {
+				return nil, errors.WithMessagef(err, "parse ip '%s'", value)
+			}
+			networks = append(networks, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "parse cidr '%s'", value)
+		}
+		networks = append(networks, prefix.Masked())
+	}
+	return networks, nil
+}
+
+func (n Networks) Contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range n {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n Networks) ContainsString(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	return n.Contains(addr)
+}
@@ -0,0 +1,146 @@
+package clientip
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+const (
+	ForwardedSource     = "FORWARDED"
+	XForwardedForSource = "X_FORWARDED_FOR"
+	XRealIpSource       = "X_REAL_IP"
+)
+
+var (
+	// defaultSources только X-Forwarded-For,который дополняют все распространенные прокси,
+	// заголовки Forwarded и X-Real-IP прокси может передать от клиента без изменений
+	defaultSources = []string{XForwardedForSource} // nolint:gochecknoglobals
+)
+
+type Resolver struct {
+	trustedProxies Networks
+	sources        []string
+}
+
+func NewResolver(trustedProxies Networks, sources []string) Resolver {
+	if len(sources) == 0 {
+		sources = defaultSources
+	}
+	return Resolver{
+		trustedProxies: trustedProxies,
+		sources:        sources,
+	}
+}
+
+// Resolve возвращает IP адрес клиента и признак того, что запрос пришёл от доверенного прокси.
+// Заголовки учитываются только от доверенных прокси, цепочка адресов просматривается справа налево
+// до первого недоверенного адреса
+func (r Resolver) Resolve(req *http.Request) (string, bool) {
+	peer := PeerIp(req)
+	if !r.trustedProxies.ContainsString(peer) {
+		return peer, false
+	}
+
+	for _, source := range r.sources {
+		chain := r.chain(req.Header, source)
+		if len(chain) == 0 {
+			continue
+		}
+		for i := len(chain) - 1; i >= 0; i-- {
+			if !r.trustedProxies.Contains(chain[i]) {
+				return chain[i].String(), true
+			}
+		}
+		return chain[0].String(), true
+	}
+
+	return peer, true
+}
+
+func (r Resolver) chain(header http.Header, source string) []netip.Addr {
+	var values []string
+	switch source {
+	case ForwardedSource:
+		for _, element := range splitHeaderValues(header.Values("Forwarded")) {
+			values = append(values, forwardedFor(element))
+		}
+	case XForwardedForSource:
+		values = splitHeaderValues(header.Values("X-Forwarded-For"))
+	case XRealIpSource:
+		values = splitHeaderValues(header.Values("X-Real-Ip"))
+	}
+
+	chain := make([]netip.Addr, 0, len(values))
+	for _, value := range values {
+		addr, ok := parseNode(value)
+		if ok {
+			chain = append(chain, addr)
+		}
+	}
+	return chain
+}
+
+// PeerIp возвращает IP адрес непосредственного отправителя запроса
+func PeerIp(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// FormatForwardedNode форматирует адрес для параметра for заголовка Forwarded (RFC 7239)
+func FormatForwardedNode(ip string) string {
+	if strings.Contains(ip, ":") {
+		return `"[` + ip + `]"`
+	}
+	return ip
+}
+
+func splitHeaderValues(values []string) []string {
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		for part := range strings.SplitSeq(value, ",") {
+			part = strings.TrimSpace(part)
+			if part != "" {
+				result = append(result, part)
+			}
+		}
+	}
+	return result
+}
+
+func forwardedFor(element string) string {
+	for pair := range strings.SplitSeq(element, ";") {
+		key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if ok && strings.EqualFold(key, "for") {
+			return value
+		}
+	}
+	return ""
+}
+
+func parseNode(value string) (netip.Addr, bool) {
+	value = strings.Trim(strings.TrimSpace(value), `"`)
+	if value == "" {
+		return netip.Addr{}, false
+	}
+
+	if strings.HasPrefix(value, "[") {
+		end := strings.Index(value, "]")
+		if end < 0 {
+			return netip.Addr{}, false
+		}
+		value = value[1:end]
+	} else if host, _, err := net.SplitHostPort(value); err == nil {
+		value = host
+	}
+
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
@@ -0,0 +1,90 @@
+package clientip_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"isp-gate-service/clientip"
+)
+
+func TestResolve(t *testing.T) {
+	t.Parallel()
+
+	trusted, err := clientip.ParseNetworks([]string{"10.0.0.0/8", "192.168.1.1"})
+	require.NoError(t, err)
+
+	type testCase struct {
+		name          string
+		sources       []string
+		remoteAddr    string
+		header        http.Header
+		expectedIp    string
+		expectTrusted bool
+	}
+	testCases := []testCase{{
+		name:       "untrusted peer ignores headers",
+		remoteAddr: "203.0.113.5:1234",
+		header:     http.Header{"X-Forwarded-For": {"1.1.1.1"}},
+		expectedIp: "203.0.113.5",
+	}, {
+		name:          "rightmost untrusted address from x-forwarded-for",
+		remoteAddr:    "10.0.0.2:1234",
+		header:        http.Header{"X-Forwarded-For": {"6.6.6.6, 1.1.1.1", "10.1.1.1"}},
+		expectedIp:    "1.1.1.1",
+		expectTrusted: true,
+	}, {
+		name:       "forwarded is ignored by default",
+		remoteAddr: "192.168.1.1:1234",
+		header: http.Header{
+			"Forwarded":       {`for="[2001:db8::17]:4711";proto=https, for=10.0.0.3`},
+			"X-Forwarded-For": {"1.1.1.1"},
+		},
+		expectedIp:    "1.1.1.1",
+		expectTrusted: true,
+	}, {
+		name:       "forwarded has priority",
+		sources:    []string{clientip.ForwardedSource, clientip.XForwardedForSource},
+		remoteAddr: "192.168.1.1:1234",
+		header: http.Header{
+			"Forwarded":       {`for="[2001:db8::17]:4711";proto=https, for=10.0.0.3`},
+			"X-Forwarded-For": {"1.1.1.1"},
+		},
+		expectedIp:    "2001:db8::17",
+		expectTrusted: true,
+	}, {
+		name:          "x-real-ip is ignored by default",
+		remoteAddr:    "10.0.0.2:1234",
+		header:        http.Header{"X-Real-Ip": {"2.2.2.2"}},
+		expectedIp:    "10.0.0.2",
+		expectTrusted: true,
+	}, {
+		name:          "x-real-ip",
+		sources:       []string{clientip.XRealIpSource},
+		remoteAddr:    "10.0.0.2:1234",
+		header:        http.Header{"X-Real-Ip": {"2.2.2.2"}},
+		expectedIp:    "2.2.2.2",
+		expectTrusted: true,
+	}, {
+		name:          "trusted peer without headers",
+		remoteAddr:    "10.0.0.2:1234",
+		header:        http.Header{},
+		expectedIp:    "10.0.0.2",
+		expectTrusted: true,
+	}}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header = tt.header
+
+			ip, trustedPeer := clientip.NewResolver(trusted, tt.sources).Resolve(req)
+			require.EqualValues(t, tt.expectedIp, ip)
+			require.EqualValues(t, tt.expectTrusted, trustedPeer)
+		})
+	}
+}
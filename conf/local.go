@@ -7,7 +7,41 @@ const (
 )
 
 type Local struct {
-	Locations []Location
+	Locations     []Location
+	ProxyProtocol ProxyProtocol
+	InternalToken InternalTokenKey
+	Revocation    Revocation
+	Audit         Audit
+	BodyMasking   BodyMaskingKey
+}
+
+type Audit struct {
+	Enable     bool
+	Path       string `validate:"required_if=Enable true"`
+	MaxSizeMb  int
+	MaxDays    int
+	MaxBackups int
+	Compress   bool
+}
+
+type Revocation struct {
+	DenylistTtlInSec int
+	AdminPermission  string
+}
+
+type BodyMaskingKey struct {
+	HashKey string
+}
+
+type InternalTokenKey struct {
+	PrivateKeyPath string
+	KeyId          string
+}
+
+type ProxyProtocol struct {
+	Enable             bool
+	TrustedSources     []string
+	HeaderTimeoutInSec int
 }
 
 type Location struct {
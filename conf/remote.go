@@ -3,6 +3,7 @@ package conf
 
 import (
 	"reflect"
+	"time"
 
 	"github.com/txix-open/isp-kit/log"
 	"github.com/txix-open/isp-kit/rc/schema"
@@ -12,6 +13,28 @@ import (
 const (
 	HeaderTokenProviderType = "HEADER"
 	CookieTokenProviderType = "COOKIE"
+	BearerTokenProviderType = "BEARER"
+	QueryTokenProviderType  = "QUERY"
+	BasicTokenProviderType  = "BASIC"
+
+	DefaultErrorFormat     = "DEFAULT"
+	ProblemJsonErrorFormat = "PROBLEM_JSON"
+
+	SetHeaderAction    = "SET"
+	AddHeaderAction    = "ADD"
+	RemoveHeaderAction = "REMOVE"
+	RenameHeaderAction = "RENAME"
+
+	AllowPolicyEffect = "ALLOW"
+	DenyPolicyEffect  = "DENY"
+	AuditPolicyEffect = "AUDIT"
+
+	MaskBodyMaskingMode = "MASK"
+	HashBodyMaskingMode = "HASH"
+
+	HexRequestIdFormat  = "HEX"
+	UuidRequestIdFormat = "UUID"
+	UlidRequestIdFormat = "ULID"
 )
 
 func init() {
@@ -30,6 +53,121 @@ type Remote struct {
 	EnableClientRequestIdForwarding bool                         `schema:"Включить проброс requestId из заголовка запроса"`
 	ForwardReqIdClientSettings      []ForwardReqIdClientSettings `schema:"Настройки проброcа requestId для приложений"`
 	CustomAuth                      CustomAuth                   `schema:"Настройка кастомной аутентификации/авторизации"`
+	GrpcProxy                       GrpcProxy                    `schema:"Настройки проксирования в grpc"`
+	LocationSettings                []LocationSetting            `schema:"Настройки для отдельных location"`
+	ClientIp                        ClientIp                     `schema:"Настройки определения IP адреса клиента"`
+	ApplicationIpRules              []ApplicationIpRule          `schema:"Ограничения доступа приложений по IP адресу клиента,проверяются после аутентификации приложения"`
+	RequestSigning                  RequestSigning               `schema:"Настройки аутентификации приложения по подписи запроса"`
+	TokenExtraction                 TokenExtraction              `schema:"Настройки получения токенов приложения и администратора из запроса"`
+	InternalToken                   InternalToken                `schema:"Настройки внутреннего токена шлюза для upstream"`
+	AccessPolicy                    AccessPolicy                 `schema:"Политика доступа,проверяется после аутентификации,до авторизации приложения"`
+	AuthorizationPrefetch           AuthorizationPrefetch        `schema:"Загрузка полного списка разрешенных endpoint приложения одним запросом"`
+	Metrics                         Metrics                      `schema:"Настройки метрик"`
+	ServerTiming                    ServerTiming                 `schema:"Настройки передачи замеров этапов обработки запроса в заголовке Server-Timing"`
+	RequestId                       RequestId                    `schema:"Настройки формата requestId,создаваемого шлюзом"`
+}
+
+type RequestId struct {
+	Format string `validate:"omitempty,oneof=HEX UUID ULID" schema:"Формат,один из: HEX - 32 шестнадцатеричных символа,UUID - UUID версии 4,ULID - сортируемый по времени создания ULID,по умолчанию HEX"`
+	Prefix string `validate:"max=32" schema:"Префикс,добавляемый к requestId"`
+}
+
+type ServerTiming struct {
+	Enable         bool  `schema:"Передавать заголовок Server-Timing с длительностью определения endpoint,аутентификации,авторизации,ограничений и вызова upstream"`
+	ApplicationIds []int `schema:"Доверенные приложения,которым передается заголовок"`
+	AdminEnable    bool  `schema:"Передавать заголовок в ответах на запросы администраторов"`
+}
+
+type Metrics struct {
+	ApplicationIdsLimit int `validate:"omitempty,min=1" schema:"Максимальное количество приложений с отдельным значением метки application_id,остальные приложения учитываются с меткой other,по умолчанию 100"`
+}
+
+type AuthorizationPrefetch struct {
+	Enable               bool `schema:"Загружать список разрешенных endpoint приложения методом system/secure/get_application_permissions,разрешения и запреты определяются по списку без запроса system/secure/authorize,при недоступности метода используется проверка каждого endpoint"`
+	RefreshIntervalInSec int  `validate:"omitempty,min=1" schema:"Период обновления списка в фоне,в секундах,по умолчанию caching.authorizationDataInSec"`
+}
+
+type AccessPolicy struct {
+	Enable bool         `schema:"Включить проверку политики доступа"`
+	DryRun bool         `schema:"Только логировать решения DENY,не отклоняя запросы"`
+	Rules  []PolicyRule `schema:"Правила в порядке проверки,первое подходящее правило ALLOW или DENY завершает проверку,правила AUDIT только логируются"`
+}
+
+type PolicyRule struct {
+	Name      string `validate:"required" schema:"Название правила,указывается в логах"`
+	Effect    string `validate:"required,oneof=ALLOW DENY AUDIT" schema:"Действие,один из: ALLOW - завершить проверку политики,запрос проходит обычную авторизацию,DENY - отклонить запрос с кодом 403,AUDIT - залогировать запрос"`
+	Condition string `validate:"required" schema:"Условие на языке CEL,доступны переменные request (method,endpoint,pathSchema,moduleName,clientIp),app (authenticated,id,name,systemId,domainId,serviceId),user (authenticated,identity,roles,permissions,headers),admin (authenticated,id),now (timestamp),ошибка вычисления условия правила ALLOW или DENY отклоняет запрос"`
+}
+
+type InternalToken struct {
+	Enable     bool   `schema:"Передавать в upstream подписанный JWT с данными приложения,пользователя,администратора,endpoint и requestId,ключ подписи задается в локальной конфигурации internalToken.privateKeyPath,открытый ключ доступен на infra сервере по пути /.well-known/jwks.json"`
+	HeaderName string `schema:"Заголовок или ключ метаданных grpc,в котором передается токен,по умолчанию x-gate-token"`
+	TtlInSec   int    `validate:"omitempty,min=1" schema:"Время жизни токена,в секундах,по умолчанию 60"`
+	Issuer     string `schema:"Значение поля iss,по умолчанию isp-gate-service"`
+}
+
+type TokenExtraction struct {
+	ApplicationTokenProviders []string `schema:"Список названий методов получения токена приложения из customAuth.tokenProviders,используется первый провайдер, вернувший токен,по умолчанию заголовок или query параметр x-application-token,затем basic auth"`
+	AdminTokenProviders       []string `schema:"Список названий методов получения токена администратора из customAuth.tokenProviders,используется первый провайдер, вернувший токен,по умолчанию заголовок или query параметр x-auth-admin"`
+	ForbidQueryTokens         bool     `schema:"Запретить получение токенов приложения и администратора из query параметров"`
+}
+
+type RequestSigning struct {
+	Enable            bool     `schema:"Включить аутентификацию по подписи запроса,запрос с заголовком x-request-signature аутентифицируется по подписи HMAC-SHA256 вместо токена"`
+	MaxClockSkewInSec int      `validate:"omitempty,min=1" schema:"Допустимое расхождение x-request-timestamp с временем шлюза,в секундах,по умолчанию 300"`
+	SignedHeaders     []string `schema:"Заголовки запроса,включаемые в подпись в указанном порядке"`
+}
+
+type ApplicationIpRule struct {
+	ApplicationId int      `validate:"required" schema:"ID приложения"`
+	Allow         []string `schema:"Разрешенные IP адреса и подсети в формате CIDR,если список пуст - разрешены все,кроме запрещенных"`
+	Deny          []string `schema:"Запрещенные IP адреса и подсети в формате CIDR,имеют приоритет над разрешенными"`
+}
+
+type IpRule struct {
+	Allow []string `schema:"Разрешенные IP адреса и подсети в формате CIDR,если список пуст - разрешены все,кроме запрещенных"`
+	Deny  []string `schema:"Запрещенные IP адреса и подсети в формате CIDR,имеют приоритет над разрешенными"`
+}
+
+type ClientIp struct {
+	TrustedProxies []string `schema:"Доверенные прокси,IP адреса или подсети в формате CIDR,заголовки Forwarded,X-Forwarded-For,X-Real-IP учитываются только от доверенных прокси"`
+	Sources        []string `validate:"dive,oneof=FORWARDED X_FORWARDED_FOR X_REAL_IP" schema:"Источники IP адреса клиента в порядке приоритета,один из: FORWARDED X_FORWARDED_FOR X_REAL_IP,по умолчанию используется только X_FORWARDED_FOR,FORWARDED и X_REAL_IP следует указывать только если доверенные прокси перезаписывают эти заголовки"`
+}
+
+type LocationSetting struct {
+	PathPrefix          string              `validate:"required" schema:"Префикс пути location,должен совпадать с pathPrefix из локальной конфигурации"`
+	GrpcStatusMapping   []GrpcStatusMapping `schema:"Переопределение соответствия кодов grpc статусам HTTP ответа,применяется только для grpc location"`
+	RequestHeaderRules  []HeaderRule        `schema:"Правила преобразования заголовков запроса,применяются к запросу в upstream,для grpc location - к метаданным"`
+	ResponseHeaderRules []HeaderRule        `schema:"Правила преобразования заголовков ответа,применяются к ответу upstream,для ws location - к ответу на установку соединения,не применяются к ошибкам,сформированным шлюзом (аутентификация,ограничения доступа,недоступность upstream,неподдерживаемый путь)"`
+	Cors                *Cors               `schema:"Настройки CORS,при наличии настроек шлюз отвечает на preflight запросы до аутентификации и заменяет CORS заголовки upstream"`
+	IpRule              *IpRule             `schema:"Ограничения доступа к location по IP адресу клиента"`
+	TokenExtraction     *TokenExtraction    `schema:"Настройки получения токенов приложения и администратора для location,заменяют общие настройки tokenExtraction"`
+	ForwardCredentials  bool                `schema:"Передавать в upstream заголовки,query параметры и cookie,из которых получены токены приложения,администратора и пользователя,по умолчанию удаляются"`
+}
+
+type Cors struct {
+	AllowedOrigins   []string `validate:"required,min=1" schema:"Разрешенные источники,поддерживаются точное значение,'*',шаблон (например 'https://*.example.com') и регулярное выражение с префиксом '~' (например '~^https://(a|b)[.]example[.]com$')"`
+	AllowedMethods   []string `schema:"Разрешенные методы,по умолчанию GET HEAD POST PUT PATCH DELETE"`
+	AllowedHeaders   []string `schema:"Разрешенные заголовки запроса,если список пуст - разрешаются заголовки из Access-Control-Request-Headers"`
+	ExposedHeaders   []string `schema:"Заголовки ответа,доступные клиенту"`
+	AllowCredentials bool     `schema:"Разрешить передачу cookie и заголовков авторизации,не совместимо с источником '*'"`
+	MaxAgeInSec      int      `validate:"min=0" schema:"Время кеширования ответа на preflight запрос,в секундах"`
+}
+
+type HeaderRule struct {
+	Action  string `validate:"required,oneof=SET ADD REMOVE RENAME" schema:"Действие,один из: SET ADD REMOVE RENAME"`
+	Name    string `validate:"required" schema:"Название заголовка,для REMOVE поддерживается '*' в конце названия,например 'X-Debug-*'"`
+	NewName string `validate:"required_if=Action RENAME" schema:"Новое название заголовка,используется для RENAME"`
+	Value   string `schema:"Значение заголовка,используется для SET и ADD,поддерживаются подстановки {requestId} {applicationId} {applicationName} {userIdentity} {adminId} {clientIp}"`
+}
+
+type GrpcStatusMapping struct {
+	GrpcCode   string `validate:"required,oneof=OK Canceled Unknown InvalidArgument DeadlineExceeded NotFound AlreadyExists PermissionDenied ResourceExhausted FailedPrecondition Aborted OutOfRange Unimplemented Internal Unavailable DataLoss Unauthenticated" schema:"Код grpc,например Aborted"`
+	HttpStatus int    `validate:"required,min=100,max=599" schema:"Статус HTTP ответа"`
+}
+
+type GrpcProxy struct {
+	ResponseHeadersAllowlist []string `schema:"Разрешенные заголовки ответа,grpc сервис может установить заголовок HTTP ответа через метаданные ответа с префиксом 'x-http-' (например 'x-http-set-cookie'),статус ответа переопределяется через 'x-http-status',если список пуст - заголовки не устанавливаются"`
 }
 
 type ForwardReqIdClientSettings struct {
@@ -38,21 +176,50 @@ type ForwardReqIdClientSettings struct {
 }
 
 type Http struct {
-	MaxRequestBodySizeInMb int64 `validate:"required" schema:"Максимальная длинна тела запроса,в мегабайтах"`
-	ProxyTimeoutInSec      int   `validate:"required" schema:"Таймаут на проксирование,в секундах"`
+	MaxRequestBodySizeInMb int64  `validate:"required" schema:"Максимальная длинна тела запроса,в мегабайтах"`
+	ProxyTimeoutInSec      int    `validate:"required" schema:"Таймаут на проксирование,в секундах"`
+	ErrorFormat            string `validate:"omitempty,oneof=DEFAULT PROBLEM_JSON" schema:"Формат ошибок,один из: DEFAULT PROBLEM_JSON,PROBLEM_JSON - application/problem+json (RFC 7807),по умолчанию DEFAULT"`
 }
 
 type Logging struct {
-	LogLevel                        log.Level `schemaGen:"logLevel" schema:"Уровень логирования,логирование запросов осуществляется на уровне debug"`
-	RequestLogEnable                bool      `schema:"Включить логирование запросов"`
-	BodyLogEnable                   bool      `schema:"Включить логирование тел запросов и ответов,должно быть включено логирование запросов"`
-	SkipBodyLoggingEndpointPrefixes []string  `schema:"Выключить логирование тел запросов и ответов для путей имеющих префикс из списка,'/' в начале игнорируется"`
-	EnableForceUnescapingUnicode    bool      `schema:"Включить перевод тел запроса из unicode в utf-8, должно быть включено логирование тел запросов и ответов"`
+	LogLevel                        log.Level         `schemaGen:"logLevel" schema:"Уровень логирования,логирование запросов осуществляется на уровне debug"`
+	RequestLogEnable                bool              `schema:"Включить логирование запросов"`
+	BodyLogEnable                   bool              `schema:"Включить логирование тел запросов и ответов,должно быть включено логирование запросов"`
+	SkipBodyLoggingEndpointPrefixes []string          `schema:"Выключить логирование тел запросов и ответов для путей имеющих префикс из списка,'/' в начале игнорируется"`
+	EnableForceUnescapingUnicode    bool              `schema:"Включить перевод тел запроса из unicode в utf-8, должно быть включено логирование тел запросов и ответов"`
+	AuditRequestBodyHashEnable      bool              `schema:"Включить запись sha256 хеша тела запроса в журнал аудита,остаток тела,не прочитанный upstream,дочитывается не больше 1 МБ,для тела большего размера хеш не записывается и устанавливается requestBodyHashUnknown,журнал аудита включается в локальной конфигурации"`
+	BodyMasking                     []BodyMaskingRule `schema:"Правила маскирования чувствительных данных в логируемых телах запросов и ответов,применяются по порядку"`
+	MaxBodyLogBytes                 int               `validate:"omitempty,min=1" schema:"Максимальный размер логируемой части тела запроса или ответа,в байтах,остаток тела отбрасывается с отметкой об обрезке,по умолчанию 65536"`
+	BodyLogContentTypes             []string          `schema:"Типы содержимого,тела с которыми логируются как текст,* соответствует любой подстроке,по умолчанию application/json,application/*+json,application/xml,application/*+xml,text/*,application/x-www-form-urlencoded"`
+	BinaryBodyLogMode               string            `validate:"omitempty,oneof=NONE BASE64 HASH" schema:"Логирование тел с остальными типами содержимого,один из: NONE - только тип и размер,BASE64 - логируемая часть тела в base64,HASH - sha256 хеш всего тела,по умолчанию HASH"`
+	Overrides                       []LoggingOverride `schema:"Переопределение настроек логирования для отдельных приложений,пользователей,endpoint и location,применяется первое подходящее действующее правило"`
+	SlowRequestThresholdInMs        int               `validate:"omitempty,min=1" schema:"Порог длительности запроса,при превышении которого запрос логируется с замерами этапов обработки,в миллисекундах,отключено при значении 0"`
+}
+
+type LoggingOverride struct {
+	ApplicationId    int       `schema:"Идентификатор приложения,0 - любое приложение"`
+	UserIdentity     string    `schema:"Идентификатор пользователя,пусто - любой пользователь"`
+	EndpointPrefix   string    `schema:"Префикс endpoint,'/' в начале игнорируется,пусто - любой endpoint"`
+	Location         string    `schema:"Префикс пути location (pathPrefix),пусто - любой location"`
+	RequestLogEnable bool      `schema:"Включить логирование запросов"`
+	BodyLogEnable    bool      `schema:"Включить логирование тел запросов и ответов,не применяется к ws,не учитывает skipBodyLoggingEndpointPrefixes"`
+	LogLevel         log.Level `schemaGen:"logLevel" schema:"Уровень,на котором логируются успешные запросы,по умолчанию info,должен быть не ниже logging.logLevel,иначе успешные запросы не логируются"`
+	SampleRate       float64   `validate:"omitempty,gt=0,lte=1" schema:"Доля логируемых успешных запросов от 0 до 1,запросы с ошибками логируются всегда,по умолчанию 1"`
+	ExpireAt         time.Time `schema:"Время окончания действия правила в формате RFC 3339,после него правило не применяется,по умолчанию действует бессрочно"`
+}
+
+type BodyMaskingRule struct {
+	EndpointPrefixes []string `schema:"Префиксы endpoint,к которым применяется правило,'/' в начале игнорируется,если не указаны - правило применяется ко всем endpoint"`
+	Fields           []string `schema:"Поля JSON и тел application/x-www-form-urlencoded,значения которых маскируются целиком,название поля (password) ищется на любой глубине,путь через точку (*.passport.number) сравнивается от корня,* - любое поле,элементы массивов не учитываются в пути"`
+	Patterns         []string `schema:"Регулярные выражения для поиска в строковых значениях JSON и в телах не в формате JSON (номера карт,телефоны,e-mail)"`
+	Mode             string   `validate:"omitempty,oneof=MASK HASH" schema:"Способ замены,один из: MASK - заменить на ***,HASH - заменить на HMAC-SHA256 значения с ключом из локальной конфигурации bodyMasking.hashKey,по умолчанию MASK"`
 }
 
 type Caching struct {
-	AuthenticationDataInSec int `validate:"required" schema:"Время кеширования данных аутентификации,в секундах"`
-	AuthorizationDataInSec  int `validate:"required" schema:"Время кеширования данных авторизации,в секундах"`
+	AuthenticationDataInSec              int `validate:"required" schema:"Время кеширования данных аутентификации,в секундах"`
+	AuthorizationDataInSec               int `validate:"required" schema:"Время кеширования данных авторизации,в секундах"`
+	AdminAuthenticationDataInSec         int `schema:"Время кеширования результата аутентификации администратора,в секундах,ограничивается expiresAt из ответа msp-admin-service,отключено при значениях <=0"`
+	AdminNegativeAuthenticationDataInSec int `schema:"Время кеширования отказа в аутентификации администратора,в секундах,отключено при значениях <=0"`
 }
 
 type DailyLimit struct {
@@ -77,9 +244,19 @@ type AuthProvider struct {
 
 type TokenProvider struct {
 	Name           string               `schema:"Название метода получения токена из запроса,должно быть уникальным" validate:"required"`
-	Type           string               `schema:"Тип метода получения токена,один из: HEADER COOKIE" validate:"required,oneof=HEADER COOKIE"`
+	Type           string               `schema:"Тип метода получения токена,один из: HEADER COOKIE BEARER QUERY BASIC,BASIC - пароль из basic auth,имя пользователя сверяется с названием приложения" validate:"required,oneof=HEADER COOKIE BEARER QUERY BASIC"`
 	HeaderProvider *HeaderTokenProvider `schema:"Настройки для получения токена из заголовка"`
 	CookieProvider *CookieTokenProvider `schema:"Настройки для получения токена из cookie"`
+	BearerProvider *BearerTokenProvider `schema:"Настройки для получения токена из заголовка со схемой Bearer"`
+	QueryProvider  *QueryTokenProvider  `schema:"Настройки для получения токена из query параметра"`
+}
+
+type BearerTokenProvider struct {
+	HeaderName string `schema:"Название заголовка,по умолчанию Authorization"`
+}
+
+type QueryTokenProvider struct {
+	ParamName string `schema:"Название query параметра,из которого берётся токен,регистр не учитывается" validate:"required"`
 }
 
 type HeaderTokenProvider struct {
@@ -92,9 +269,31 @@ type CookieTokenProvider struct {
 }
 
 type UserAuthSetting struct {
-	ModuleNameList       []string `schema:"Название модулей,для которых настраивается аутентификация/авторизация,название модулей должно быть уникальным" validate:"required"`
-	TokenProviders       []string `schema:"Список названий методов получения токена из запроса,используется первый провайдер, вернувший токен"`
-	AuthenticateEndpoint string   `schema:"Endpoint для аутентификации пользователя,вызывается через isp-router-service" validate:"required"`
-	CacheDataInSec       int      `schema:"Время кеширования данных аутентификации/авторизации пользователя,отключен при значениях <=0,в секундах"`
-	SkipAppAuth          bool     `schema:"Пропустить аутентификацию и авторизацию приложения"`
+	ModuleNameList       []string       `schema:"Название модулей,для которых настраивается аутентификация/авторизация,название модулей должно быть уникальным" validate:"required"`
+	TokenProviders       []string       `schema:"Список названий методов получения токена из запроса,используется первый провайдер, вернувший токен"`
+	AuthenticateEndpoint string         `schema:"Endpoint для аутентификации пользователя,вызывается через isp-router-service,не используется при настроенной introspection" validate:"required_without=Introspection"`
+	Introspection        *Introspection `schema:"Настройки аутентификации пользователя через OAuth2 token introspection (RFC 7662)"`
+	CacheDataInSec       int            `schema:"Максимальное время кеширования данных аутентификации/авторизации пользователя,отключен при значениях <=0,в секундах,время также ограничивается expiresAt и cacheTtlSec из ответа аутентификации,для introspection по умолчанию кешируется до истечения токена"`
+	CacheJitterPercent   int            `validate:"min=0,max=50" schema:"Случайное уменьшение времени кеширования,в процентах,чтобы записи не истекали одновременно"`
+	RefreshAheadPercent  int            `validate:"min=0,max=90" schema:"Обновление данных аутентификации в фоне,когда осталось указанное количество процентов времени кеширования,отключено при 0"`
+	SkipAppAuth          bool           `schema:"Пропустить аутентификацию и авторизацию приложения"`
+}
+
+type Introspection struct {
+	Endpoint         string                     `schema:"URL introspection endpoint" validate:"required,url"`
+	ClientId         string                     `schema:"Идентификатор клиента,передается через basic auth" validate:"required"`
+	ClientSecret     string                     `schema:"Секрет клиента,передается через basic auth" validate:"required"`
+	TokenTypeHint    string                     `schema:"Значение параметра token_type_hint,например access_token"`
+	RequiredScopes   []string                   `schema:"Обязательные scope,токен без любого из них не проходит аутентификацию"`
+	IdentityClaim    string                     `schema:"Поле ответа с идентификатором пользователя,по умолчанию sub"`
+	IdentityHeader   string                     `schema:"Заголовок,в котором идентификатор пользователя передается в upstream" validate:"required"`
+	ClaimHeaders     []IntrospectionClaimHeader `schema:"Передача полей ответа introspection в заголовках upstream"`
+	RolesClaim       string                     `schema:"Поле ответа с ролями пользователя,список или строка через пробел"`
+	PermissionsClaim string                     `schema:"Поле ответа с разрешениями пользователя,список или строка через пробел,по умолчанию scope"`
+	TimeoutInSec     int                        `schema:"Таймаут запроса,в секундах,по умолчанию 5"`
+}
+
+type IntrospectionClaimHeader struct {
+	Claim  string `schema:"Поле ответа introspection,например scope или client_id" validate:"required"`
+	Header string `schema:"Заголовок,для grpc upstream передаются только заголовки с префиксом x-" validate:"required"`
 }
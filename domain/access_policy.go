@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"time"
+)
+
+type AccessPolicyRequest struct {
+	Method     string
+	Endpoint   string
+	PathSchema string
+	ModuleName string
+	ClientIp   string
+	App        *AppAuthData
+	User       *UserAuthData
+	Admin      bool
+	AdminId    int
+	Time       time.Time
+}
+
+type AccessPolicyDecision struct {
+	Deny bool
+	// Правило,завершившее проверку
+	Rule string
+	// Сработавшие правила AUDIT
+	AuditRules []string
+	// Ошибки вычисления условий,ключ - название правила
+	Errors map[string]error
+}
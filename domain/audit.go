@@ -0,0 +1,29 @@
+package domain
+
+import (
+	"time"
+)
+
+const (
+	AuditAuthorizationBypassEvent      = "authorization_bypass"
+	AuditAuthorizationDeniedEvent      = "authorization_denied"
+	AuditAdminAuthorizationDeniedEvent = "admin_authorization_denied"
+	AuditUserAuthorizationDeniedEvent  = "user_authorization_denied"
+	AuditAccessPolicyDeniedEvent       = "access_policy_denied"
+)
+
+type AuditRecord struct {
+	Time                   time.Time `json:"time"`
+	RequestId              string    `json:"requestId"`
+	HttpMethod             string    `json:"httpMethod"`
+	Endpoint               string    `json:"endpoint"`
+	Inner                  bool      `json:"inner"`
+	StatusCode             int       `json:"statusCode"`
+	ClientIp               string    `json:"clientIp"`
+	AdminId                int       `json:"adminId,omitempty"`
+	ApplicationId          int       `json:"applicationId,omitempty"`
+	UserIdentity           string    `json:"userIdentity,omitempty"`
+	Events                 []string  `json:"events,omitempty"`
+	RequestBodyHash        string    `json:"requestBodyHash,omitempty"`
+	RequestBodyHashUnknown bool      `json:"requestBodyHashUnknown,omitempty"`
+}
@@ -1,5 +1,9 @@
 package domain
 
+import (
+	"time"
+)
+
 type AuthenticateAppResponse struct {
 	Authenticated bool
 	ErrorReason   string
@@ -35,4 +39,14 @@ type UserAuthData struct {
 	Identity       string
 	IdentityHeader string
 	ExtraHeaders   map[string][]string
+	Roles          []string
+	Permissions    []string
+}
+
+type SignedRequest struct {
+	ApplicationId int
+	Timestamp     time.Time
+	Nonce         string
+	Signature     string
+	StringToSign  string
 }
@@ -10,6 +10,10 @@ type EndpointMeta struct {
 
 	ModuleName              string
 	RequiredAdminPermission string
+	// Все перечисленные разрешения должны быть у пользователя
+	RequiredUserPermissions []string
+	// У пользователя должна быть хотя бы одна из перечисленных ролей
+	RequiredUserRoles []string
 	// Объявляемый сервисом метод
 	PathSchema string
 	// Вызываемый метод
@@ -19,6 +23,11 @@ type EndpointMeta struct {
 	NormalizedEndpoint string
 }
 
+const (
+	RequiredUserPermissionsExtraKey = "reqUserPerms"
+	RequiredUserRolesExtraKey       = "reqUserRoles"
+)
+
 type endpointMetaKey struct{}
 
 func (m EndpointMeta) ToContext(ctx context.Context) context.Context {
@@ -0,0 +1,63 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	anyHttpMethod    = "*"
+	endpointWildcard = "*"
+)
+
+type ApplicationPermissions struct {
+	// nil,если список разрешений не удалось загрузить
+	Endpoints *EndpointPermissionSet
+	LoadedAt  time.Time
+}
+
+// EndpointPermissionSet список разрешенных endpoint приложения,
+// endpoint с '*' в конце разрешает все endpoint с этим префиксом
+type EndpointPermissionSet struct {
+	exact    map[string]struct{}
+	prefixes map[string][]string
+}
+
+func NewEndpointPermissionSet() *EndpointPermissionSet {
+	return &EndpointPermissionSet{
+		exact:    make(map[string]struct{}),
+		prefixes: make(map[string][]string),
+	}
+}
+
+func (s *EndpointPermissionSet) Add(httpMethod string, endpoint string) {
+	httpMethod = strings.ToUpper(httpMethod)
+	if httpMethod == "" {
+		httpMethod = anyHttpMethod
+	}
+	endpoint = strings.TrimPrefix(endpoint, "/")
+
+	prefix, isPrefix := strings.CutSuffix(endpoint, endpointWildcard)
+	if isPrefix {
+		s.prefixes[httpMethod] = append(s.prefixes[httpMethod], prefix)
+		return
+	}
+	s.exact[httpMethod+" "+endpoint] = struct{}{}
+}
+
+func (s *EndpointPermissionSet) Allowed(httpMethod string, endpoint string) bool {
+	httpMethod = strings.ToUpper(httpMethod)
+	endpoint = strings.TrimPrefix(endpoint, "/")
+	for _, method := range []string{httpMethod, anyHttpMethod} {
+		_, ok := s.exact[method+" "+endpoint]
+		if ok {
+			return true
+		}
+		for _, prefix := range s.prefixes[method] {
+			if strings.HasPrefix(endpoint, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,11 @@
+package domain
+
+const (
+	IpDeniedByLocationReason    = "location_ip_rule"
+	IpDeniedByApplicationReason = "application_ip_rule"
+)
+
+type IpAccessResult struct {
+	Allow  bool
+	Reason string
+}
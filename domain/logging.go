@@ -0,0 +1,22 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/txix-open/isp-kit/log"
+)
+
+type LoggingOverrideRequest struct {
+	Location      string
+	Endpoint      string
+	ApplicationId int
+	UserIdentity  string
+	Time          time.Time
+}
+
+type LoggingOverride struct {
+	RequestLogEnable bool
+	BodyLogEnable    bool
+	Level            log.Level
+	SampleRate       float64
+}
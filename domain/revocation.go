@@ -0,0 +1,5 @@
+package domain
+
+const (
+	RevocationPath = "/gate/revoke"
+)
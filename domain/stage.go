@@ -0,0 +1,10 @@
+package domain
+
+// Этапы обработки запроса,на которых запрос может быть отклонен
+const (
+	AuthStage     = "auth"
+	AuthzStage    = "authz"
+	ThrottleStage = "throttle"
+	QuotaStage    = "quota"
+	ProxyStage    = "proxy"
+)
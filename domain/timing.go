@@ -0,0 +1,24 @@
+package domain
+
+import (
+	"time"
+)
+
+// Названия замеров этапов обработки запроса в заголовке Server-Timing и журнале медленных запросов
+const (
+	ResolveTiming       = "resolve"
+	AppAuthTiming       = "app_auth"
+	UserAuthTiming      = "user_auth"
+	AdminAuthTiming     = "admin_auth"
+	AuthzTiming         = "authz"
+	ThrottleTiming      = "throttle"
+	QuotaTiming         = "quota"
+	InternalTokenTiming = "internal_token"
+	UpstreamTiming      = "upstream"
+	TotalTiming         = "total"
+)
+
+type StageTiming struct {
+	Name     string
+	Duration time.Duration
+}
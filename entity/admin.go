@@ -1,7 +1,12 @@
 package entity
 
+import (
+	"time"
+)
+
 type AdminAuthenticateResponse struct {
 	Authenticated bool
 	ErrorReason   string
 	AdminId       int
+	ExpiresAt     *time.Time
 }
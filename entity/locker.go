@@ -1,6 +1,7 @@
 package entity
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -23,3 +24,32 @@ type RateLimiterResponse struct {
 	Remaining  int
 	RetryAfter time.Duration
 }
+
+type SetIfAbsentRequest struct {
+	Key string
+	Ttl time.Duration
+}
+
+type SetIfAbsentResponse struct {
+	Set bool
+}
+
+type PublishRequest struct {
+	Channel string
+	Data    json.RawMessage
+}
+
+type PollRequest struct {
+	Channel string
+	AfterId int64
+}
+
+type PollResponse struct {
+	Messages []PubSubMessage
+	LastId   int64
+}
+
+type PubSubMessage struct {
+	Id   int64
+	Data json.RawMessage
+}
@@ -0,0 +1,14 @@
+package entity
+
+type RevocationRequest struct {
+	Token         string
+	ApplicationId int
+	UserIdentity  string
+	AdminToken    string
+	AdminId       int
+	All           bool
+}
+
+type RevocationResponse struct {
+	Broadcast bool
+}
@@ -10,6 +10,16 @@ type AuthenticateResponse struct {
 	AuthData      *AppAuthData
 }
 
+type ApplicationSecretRequest struct {
+	ApplicationId int
+}
+
+type ApplicationSecretResponse struct {
+	Found    bool
+	Secret   string
+	AuthData *AppAuthData
+}
+
 type AuthorizeRequest struct {
 	ApplicationId int
 	HttpMethod    string
@@ -20,6 +30,19 @@ type AuthorizeResponse struct {
 	Authorized bool
 }
 
+type ApplicationPermissionsRequest struct {
+	ApplicationId int
+}
+
+type ApplicationPermissionsResponse struct {
+	Endpoints []EndpointPermission
+}
+
+type EndpointPermission struct {
+	HttpMethod string
+	Endpoint   string
+}
+
 type AdminAuthorizeRequest struct {
 	AdminId    int
 	Permission string
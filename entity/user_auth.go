@@ -1,5 +1,9 @@
 package entity
 
+import (
+	"time"
+)
+
 type UserAuthenticateRequest struct {
 	Token string
 }
@@ -8,10 +12,19 @@ type UserAuthenticateResponse struct {
 	Authenticated bool
 	ErrorReason   string
 	AuthData      *UserAuthData
+	ExpiresAt     *time.Time
+	CacheTtlSec   int
+}
+
+type CachedUserAuthData struct {
+	AuthData  UserAuthData
+	RefreshAt time.Time
 }
 
 type UserAuthData struct {
 	Identity       string
 	IdentityHeader string
 	ExtraHeaders   map[string][]string
+	Roles          []string
+	Permissions    []string
 }
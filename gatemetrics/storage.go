@@ -0,0 +1,164 @@
+package gatemetrics
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/txix-open/isp-kit/metrics"
+)
+
+const (
+	noneLabel  = "none"
+	otherLabel = "other"
+)
+
+// nolint:gochecknoglobals,mnd
+var (
+	durationBuckets = prometheus.ExponentialBuckets(1, 2, 16)
+)
+
+type Request struct {
+	Location      string
+	TargetModule  string
+	ApplicationId int
+	StatusCode    int
+	Stage         string
+	Duration      time.Duration
+}
+
+type Storage struct {
+	ipAccessDenied   *prometheus.CounterVec
+	requests         *prometheus.CounterVec
+	requestDuration  *prometheus.HistogramVec
+	unknownEndpoints *prometheus.CounterVec
+	cacheRequests    *prometheus.CounterVec
+	lockDuration     *prometheus.HistogramVec
+	upstreamHosts    *prometheus.GaugeVec
+	upstreamUp       *prometheus.GaugeVec
+
+	applicationIdsLock *sync.Mutex
+	applicationIds     map[int]bool
+}
+
+func NewStorage(reg *metrics.Registry) *Storage {
+	return &Storage{
+		ipAccessDenied: metrics.GetOrRegister(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Subsystem: "gate",
+			Name:      "ip_access_denied_count",
+			Help:      "Counter of requests denied by ip access rules",
+		}, []string{"reason"})),
+		requests: metrics.GetOrRegister(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Subsystem: "gate",
+			Name:      "request_count",
+			Help:      "Counter of requests by the stage which produced the response",
+		}, []string{"location", "target_module", "application_id", "status_class", "stage"})),
+		requestDuration: metrics.GetOrRegister(reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Subsystem: "gate",
+			Name:      "request_duration_ms",
+			Help:      "The latency of the requests by the stage which produced the response",
+			Buckets:   durationBuckets,
+		}, []string{"location", "target_module", "application_id", "status_class", "stage"})),
+		unknownEndpoints: metrics.GetOrRegister(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Subsystem: "gate",
+			Name:      "unknown_endpoint_count",
+			Help:      "Counter of requests to unknown endpoints",
+		}, []string{"location"})),
+		cacheRequests: metrics.GetOrRegister(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Subsystem: "gate",
+			Name:      "cache_request_count",
+			Help:      "Counter of cache lookups by result",
+		}, []string{"cache", "result"})),
+		lockDuration: metrics.GetOrRegister(reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Subsystem: "gate",
+			Name:      "lock_request_duration_ms",
+			Help:      "The latency of isp-lock-service calls",
+			Buckets:   durationBuckets,
+		}, []string{"operation", "result"})),
+		upstreamHosts: metrics.GetOrRegister(reg, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Subsystem: "gate",
+			Name:      "upstream_hosts",
+			Help:      "The number of known hosts of the module",
+		}, []string{"module"})),
+		upstreamUp: metrics.GetOrRegister(reg, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Subsystem: "gate",
+			Name:      "upstream_up",
+			Help:      "1 if the module has at least one known host",
+		}, []string{"module"})),
+		applicationIdsLock: &sync.Mutex{},
+		applicationIds:     make(map[int]bool),
+	}
+}
+
+func (s *Storage) CountIpAccessDenied(reason string) {
+	s.ipAccessDenied.WithLabelValues(reason).Inc()
+}
+
+// ObserveRequest учитывает запрос,метка application_id выставляется только первым applicationIdsLimit приложениям,
+// остальные учитываются с меткой other
+func (s *Storage) ObserveRequest(req Request, applicationIdsLimit int) {
+	stage := req.Stage
+	if stage == "" {
+		stage = noneLabel
+	}
+	labels := []string{
+		req.Location,
+		req.TargetModule,
+		s.applicationIdLabel(req.ApplicationId, applicationIdsLimit),
+		statusClass(req.StatusCode),
+		stage,
+	}
+	s.requests.WithLabelValues(labels...).Inc()
+	s.requestDuration.WithLabelValues(labels...).Observe(metrics.Milliseconds(req.Duration))
+}
+
+func (s *Storage) CountUnknownEndpoint(location string) {
+	s.unknownEndpoints.WithLabelValues(location).Inc()
+}
+
+func (s *Storage) CountCacheHit(cache string) {
+	s.cacheRequests.WithLabelValues(cache, "hit").Inc()
+}
+
+func (s *Storage) CountCacheMiss(cache string) {
+	s.cacheRequests.WithLabelValues(cache, "miss").Inc()
+}
+
+func (s *Storage) ObserveLockDuration(operation string, duration time.Duration, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	s.lockDuration.WithLabelValues(operation, result).Observe(metrics.Milliseconds(duration))
+}
+
+func (s *Storage) SetUpstreamHosts(module string, hosts int) {
+	s.upstreamHosts.WithLabelValues(module).Set(float64(hosts))
+	up := 0.0
+	if hosts > 0 {
+		up = 1
+	}
+	s.upstreamUp.WithLabelValues(module).Set(up)
+}
+
+func (s *Storage) applicationIdLabel(applicationId int, limit int) string {
+	if applicationId == 0 {
+		return noneLabel
+	}
+
+	s.applicationIdsLock.Lock()
+	defer s.applicationIdsLock.Unlock()
+
+	if !s.applicationIds[applicationId] {
+		if len(s.applicationIds) >= limit {
+			return otherLabel
+		}
+		s.applicationIds[applicationId] = true
+	}
+	return strconv.Itoa(applicationId)
+}
+
+func statusClass(statusCode int) string {
+	return strconv.Itoa(statusCode/100) + "xx" // nolint:mnd
+}
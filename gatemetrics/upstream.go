@@ -0,0 +1,27 @@
+package gatemetrics
+
+import (
+	"github.com/txix-open/isp-kit/cluster"
+)
+
+type hostsUpgrader struct {
+	module   string
+	upgrader cluster.HostsUpgrader
+	storage  *Storage
+}
+
+// ObserveHosts обновляет метрики доступности модуля при каждом изменении списка его адресов
+// nolint:ireturn
+func ObserveHosts(storage *Storage, module string, upgrader cluster.HostsUpgrader) cluster.HostsUpgrader {
+	storage.SetUpstreamHosts(module, 0)
+	return hostsUpgrader{
+		module:   module,
+		upgrader: upgrader,
+		storage:  storage,
+	}
+}
+
+func (u hostsUpgrader) Upgrade(hosts []string) {
+	u.upgrader.Upgrade(hosts)
+	u.storage.SetUpstreamHosts(u.module, len(hosts))
+}
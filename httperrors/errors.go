@@ -6,6 +6,11 @@ import (
 	"github.com/txix-open/isp-kit/json"
 )
 
+const (
+	problemJsonContentType = "application/problem+json"
+	defaultProblemType     = "about:blank"
+)
+
 type HttpError struct {
 	statusCode  int
 	userMessage string
@@ -25,7 +30,7 @@ func (e *HttpError) Error() string {
 	return e.err.Error()
 }
 
-func (e *HttpError) WriteError(w http.ResponseWriter) error {
+func (e *HttpError) WriteError(w http.ResponseWriter, requestId string) error {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(e.statusCode)
 	data := map[string]any{
@@ -33,6 +38,30 @@ func (e *HttpError) WriteError(w http.ResponseWriter) error {
 		"errorMessage": e.userMessage,
 		"details":      e.details,
 	}
+	if requestId != "" {
+		data["requestId"] = requestId
+	}
+	return json.NewEncoder(w).Encode(data)
+}
+
+// WriteProblem пишет ошибку в формате application/problem+json (RFC 7807),
+// requestId передается в instance и в поле расширения requestId
+func (e *HttpError) WriteProblem(w http.ResponseWriter, requestId string) error {
+	w.Header().Set("Content-Type", problemJsonContentType)
+	w.WriteHeader(e.statusCode)
+	data := map[string]any{
+		"type":   defaultProblemType,
+		"title":  http.StatusText(e.statusCode),
+		"status": e.statusCode,
+		"detail": e.userMessage,
+	}
+	if requestId != "" {
+		data["instance"] = requestId
+		data["requestId"] = requestId
+	}
+	if len(e.details) > 0 {
+		data["details"] = e.details
+	}
 	return json.NewEncoder(w).Encode(data)
 }
 
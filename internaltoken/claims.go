@@ -0,0 +1,32 @@
+package internaltoken
+
+type Claims struct {
+	Issuer      string             `json:"iss"`
+	Audience    string             `json:"aud"`
+	IssuedAt    int64              `json:"iat"`
+	ExpiresAt   int64              `json:"exp"`
+	RequestId   string             `json:"requestId"`
+	HttpMethod  string             `json:"httpMethod"`
+	Endpoint    string             `json:"endpoint"`
+	Application *ApplicationClaims `json:"application,omitempty"`
+	User        *UserClaims        `json:"user,omitempty"`
+	Admin       *AdminClaims       `json:"admin,omitempty"`
+}
+
+type ApplicationClaims struct {
+	Id        int    `json:"id"`
+	Name      string `json:"name"`
+	SystemId  int    `json:"systemId"`
+	DomainId  int    `json:"domainId"`
+	ServiceId int    `json:"serviceId"`
+}
+
+type UserClaims struct {
+	Identity    string   `json:"identity"`
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+}
+
+type AdminClaims struct {
+	Id int `json:"id"`
+}
@@ -0,0 +1,20 @@
+package internaltoken
+
+import (
+	"net/http"
+
+	"github.com/txix-open/isp-kit/json"
+)
+
+const (
+	JwksPath = "/.well-known/jwks.json"
+)
+
+// JwksHandler отдает открытый ключ подписи в формате JWKS (RFC 7517)
+func JwksHandler(signer *Signer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		_ = json.NewEncoder(w).Encode(signer.Jwks())
+	})
+}
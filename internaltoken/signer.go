@@ -0,0 +1,143 @@
+package internaltoken
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/txix-open/isp-kit/json"
+)
+
+const (
+	rs256Algorithm = "RS256"
+	edDsaAlgorithm = "EdDSA"
+)
+
+type header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+	KeyId     string `json:"kid"`
+}
+
+type Jwk struct {
+	KeyType   string `json:"kty"`
+	KeyId     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+type Jwks struct {
+	Keys []Jwk `json:"keys"`
+}
+
+// Signer подписывает JWT ключом RSA (RS256) или Ed25519 (EdDSA)
+type Signer struct {
+	key       crypto.Signer
+	keyId     string
+	algorithm string
+	header    string
+}
+
+func NewSigner(key crypto.Signer, keyId string) (*Signer, error) {
+	var algorithm string
+	switch key.(type) {
+	case *rsa.PrivateKey:
+		algorithm = rs256Algorithm
+	case ed25519.PrivateKey:
+		algorithm = edDsaAlgorithm
+	default:
+		return nil, errors.Errorf("unsupported key type %T, expected RSA or Ed25519", key)
+	}
+
+	encodedHeader, err := json.Marshal(header{Algorithm: algorithm, Type: "JWT", KeyId: keyId})
+	if err != nil {
+		return nil, errors.WithMessage(err, "json marshal header")
+	}
+	return &Signer{
+		key:       key,
+		keyId:     keyId,
+		algorithm: algorithm,
+		header:    base64.RawURLEncoding.EncodeToString(encodedHeader),
+	}, nil
+}
+
+// LoadSigner читает закрытый ключ в формате PEM (PKCS#8 или PKCS#1 для RSA)
+func LoadSigner(privateKeyPath string, keyId string) (*Signer, error) {
+	data, err := os.ReadFile(privateKeyPath)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "read private key '%s'", privateKeyPath)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.Errorf("private key '%s' is not PEM encoded", privateKeyPath)
+	}
+
+	var key any
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, errors.WithMessagef(err, "parse private key '%s'", privateKeyPath)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.Errorf("private key '%s' can't be used for signing", privateKeyPath)
+	}
+	return NewSigner(signer, keyId)
+}
+
+func (s *Signer) Sign(claims any) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", errors.WithMessage(err, "json marshal claims")
+	}
+	signingInput := s.header + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	var signature []byte
+	switch s.algorithm {
+	case rs256Algorithm:
+		hash := sha256.Sum256([]byte(signingInput))
+		signature, err = s.key.Sign(rand.Reader, hash[:], crypto.SHA256)
+	default:
+		signature, err = s.key.Sign(rand.Reader, []byte(signingInput), crypto.Hash(0))
+	}
+	if err != nil {
+		return "", errors.WithMessage(err, "sign")
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func (s *Signer) Jwks() Jwks {
+	jwk := Jwk{
+		KeyId:     s.keyId,
+		Use:       "sig",
+		Algorithm: s.algorithm,
+	}
+	switch publicKey := s.key.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(publicKey)
+	}
+	return Jwks{Keys: []Jwk{jwk}}
+}
@@ -0,0 +1,78 @@
+package internaltoken_test
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/txix-open/isp-kit/json"
+	"isp-gate-service/internaltoken"
+)
+
+func TestSigner_Ed25519(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(err)
+	signer, err := internaltoken.NewSigner(privateKey, "key-1")
+	require.NoError(err)
+
+	token, err := signer.Sign(internaltoken.Claims{Issuer: "gate", RequestId: "request"})
+	require.NoError(err)
+	signingInput, signature, claims := splitToken(require, token)
+
+	jwk := signer.Jwks().Keys[0]
+	require.EqualValues("OKP", jwk.KeyType)
+	require.EqualValues("EdDSA", jwk.Algorithm)
+	require.EqualValues("key-1", jwk.KeyId)
+	publicKey, err := base64.RawURLEncoding.DecodeString(jwk.X)
+	require.NoError(err)
+	require.True(ed25519.Verify(publicKey, []byte(signingInput), signature))
+	require.EqualValues("gate", claims.Issuer)
+	require.EqualValues("request", claims.RequestId)
+}
+
+func TestSigner_Rsa(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(err)
+	signer, err := internaltoken.NewSigner(privateKey, "key-2")
+	require.NoError(err)
+
+	token, err := signer.Sign(internaltoken.Claims{Issuer: "gate"})
+	require.NoError(err)
+	signingInput, signature, _ := splitToken(require, token)
+
+	jwk := signer.Jwks().Keys[0]
+	require.EqualValues("RSA", jwk.KeyType)
+	require.EqualValues("RS256", jwk.Algorithm)
+	n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+	require.NoError(err)
+	e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+	require.NoError(err)
+	publicKey := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	hash := sha256.Sum256([]byte(signingInput))
+	require.NoError(rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hash[:], signature))
+}
+
+func splitToken(require *require.Assertions, token string) (string, []byte, internaltoken.Claims) {
+	parts := strings.Split(token, ".")
+	require.Len(parts, 3)
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	require.NoError(err)
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	require.NoError(err)
+	claims := internaltoken.Claims{}
+	require.NoError(json.Unmarshal(payload, &claims))
+	return parts[0] + "." + parts[1], signature, claims
+}
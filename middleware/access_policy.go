@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"isp-gate-service/domain"
+	"isp-gate-service/httperrors"
+	"isp-gate-service/request"
+
+	"github.com/pkg/errors"
+	"github.com/txix-open/isp-kit/log"
+)
+
+type AccessPolicyEvaluator interface {
+	Enabled() bool
+	Evaluate(req domain.AccessPolicyRequest) domain.AccessPolicyDecision
+}
+
+// AccessPolicy проверяет запрос по правилам политики доступа,
+// должен выполняться после аутентификации приложения,пользователя и администратора
+func AccessPolicy(policy AccessPolicyEvaluator, dryRun bool, logger log.Logger) Middleware {
+	return func(next Handler) Handler {
+		if !policy.Enabled() {
+			return next
+		}
+		return HandlerFunc(func(ctx *request.Context) error {
+			policyRequest := accessPolicyRequest(ctx)
+			decision := policy.Evaluate(policyRequest)
+
+			fields := []log.Field{
+				log.String("endpoint", policyRequest.Endpoint),
+				log.String("clientIp", policyRequest.ClientIp),
+			}
+			if policyRequest.App != nil {
+				fields = append(fields, log.Int("applicationId", policyRequest.App.ApplicationId))
+			}
+			if policyRequest.User != nil {
+				fields = append(fields, log.String("userIdentity", policyRequest.User.Identity))
+			}
+			for rule, err := range decision.Errors {
+				logger.Error(ctx.Context(), errors.WithMessagef(err, "access policy: rule '%s'", rule), fields...)
+			}
+			for _, rule := range decision.AuditRules {
+				logger.Info(ctx.Context(), "access policy: audit", append(fields, log.String("rule", rule))...)
+			}
+			if !decision.Deny {
+				return next.Handle(ctx)
+			}
+
+			if dryRun {
+				logger.Warn(ctx.Context(), "access policy: dry run, request would be denied",
+					append(fields, log.String("rule", decision.Rule))...)
+				return next.Handle(ctx)
+			}
+			logger.Warn(ctx.Context(), "access policy: request denied", append(fields, log.String("rule", decision.Rule))...)
+			ctx.AddAuditEvent(domain.AuditAccessPolicyDeniedEvent)
+			return httperrors.New(
+				http.StatusForbidden,
+				"access denied",
+				errors.Errorf("access policy: request is denied by rule '%s'", decision.Rule),
+			)
+		})
+	}
+}
+
+func accessPolicyRequest(ctx *request.Context) domain.AccessPolicyRequest {
+	endpointMeta := ctx.EndpointMeta()
+	req := domain.AccessPolicyRequest{
+		Method:     ctx.Request().Method,
+		Endpoint:   endpointMeta.NormalizedEndpoint,
+		PathSchema: endpointMeta.PathSchema,
+		ModuleName: endpointMeta.ModuleName,
+		ClientIp:   ctx.ClientIp(),
+		Admin:      ctx.IsAdminAuthenticated(),
+		AdminId:    ctx.AdminId(),
+		Time:       time.Now(),
+	}
+	appAuthData, err := ctx.GetAuthData()
+	if err == nil {
+		req.App = &appAuthData
+	}
+	userAuthData, err := ctx.GetUserAuthData()
+	if err == nil {
+		req.User = &userAuthData
+	}
+	return req
+}
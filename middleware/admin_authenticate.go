@@ -11,18 +11,17 @@ import (
 	"github.com/pkg/errors"
 )
 
-const (
-	adminAuthHeader = "x-auth-admin"
-)
-
 type AdminAuthenticator interface {
 	AdminAuthenticate(ctx context.Context, token string) (*domain.AdminAuthenticateResponse, error)
 }
 
-func AdminAuthenticate(auth AdminAuthenticator) Middleware {
+func AdminAuthenticate(auth AdminAuthenticator, tokenProviders []TokenProvider) Middleware {
 	return func(next Handler) Handler {
 		return HandlerFunc(func(ctx *request.Context) error {
-			adminToken := ctx.Param(adminAuthHeader)
+			adminToken, err := extractAdminToken(ctx, tokenProviders)
+			if err != nil {
+				return err
+			}
 			if adminToken == "" {
 				return next.Handle(ctx)
 			}
@@ -46,3 +45,21 @@ func AdminAuthenticate(auth AdminAuthenticator) Middleware {
 		})
 	}
 }
+
+func extractAdminToken(ctx *request.Context, tokenProviders []TokenProvider) (string, error) {
+	for _, provider := range tokenProviders {
+		token, err := provider.ExtractToken(ctx)
+		if err != nil {
+			return "", httperrors.New(
+				http.StatusUnauthorized,
+				"invalid admin token",
+				errors.WithMessagef(err, "admin authenticate: extract token by '%s'", provider.GetName()),
+			)
+		}
+		if token != "" {
+			consumeCredentials(ctx, tokenProviders)
+			return token, nil
+		}
+	}
+	return "", nil
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"net/http"
 
-	"github.com/pkg/errors"
+	"isp-gate-service/domain"
 	"isp-gate-service/httperrors"
 	"isp-gate-service/request"
+
+	"github.com/pkg/errors"
 )
 
 type AdminAuthorizer interface {
@@ -24,6 +26,7 @@ func AdminAuthorize(authorizer AdminAuthorizer) Middleware {
 			}
 
 			if !ctx.IsAdminAuthenticated() {
+				ctx.AddAuditEvent(domain.AuditAdminAuthorizationDeniedEvent)
 				return httperrors.New(
 					http.StatusForbidden,
 					"admin authentication required",
@@ -42,6 +45,7 @@ func AdminAuthorize(authorizer AdminAuthorizer) Middleware {
 				return errors.WithMessage(err, "admin authorization: authorize")
 			}
 			if !ok {
+				ctx.AddAuditEvent(domain.AuditAdminAuthorizationDeniedEvent)
 				return httperrors.New(
 					http.StatusForbidden,
 					"endpoint is not allowed",
@@ -0,0 +1,160 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"sync"
+	"time"
+
+	"isp-gate-service/domain"
+	"isp-gate-service/request"
+
+	"github.com/pkg/errors"
+	"github.com/txix-open/isp-kit/log"
+	"github.com/txix-open/isp-kit/requestid"
+)
+
+const (
+	// auditBodyDrainLimit остаток тела,не прочитанный upstream,дочитывается для хеша не больше этого размера,
+	// тело отклоненного до проксирования запроса не должно читаться целиком
+	auditBodyDrainLimit = 1 << 20
+)
+
+type AuditWriter interface {
+	Write(ctx context.Context, record domain.AuditRecord) error
+}
+
+// Audit записывает в журнал аудита запросы с аутентификацией администратора,
+// запросы к inner endpoint и запросы с обходом или отказом в авторизации
+func Audit(writer AuditWriter, hashRequestBody bool, logger log.Logger) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx *request.Context) error {
+			if writer == nil {
+				return next.Handle(ctx)
+			}
+
+			r := ctx.Request()
+			var requestBody *hashingBody
+			if hashRequestBody && r.Body != nil {
+				requestBody = newHashingBody(r.Body)
+				r.Body = requestBody
+				defer requestBody.close()
+			}
+
+			statusWriter := &writerWrapper{ResponseWriter: ctx.ResponseWriter()}
+			ctx.SetResponseWriter(statusWriter)
+
+			err := next.Handle(ctx)
+
+			endpointMeta := ctx.EndpointMeta()
+			events := ctx.AuditEvents()
+			if !ctx.IsAdminAuthenticated() && !endpointMeta.Inner && len(events) == 0 {
+				return err
+			}
+
+			authData, _ := ctx.GetAuthData()
+			userAuthData, _ := ctx.GetUserAuthData()
+			record := domain.AuditRecord{
+				Time:          time.Now().UTC(),
+				RequestId:     requestid.FromContext(ctx.Context()),
+				HttpMethod:    r.Method,
+				Endpoint:      endpointMeta.NormalizedEndpoint,
+				Inner:         endpointMeta.Inner,
+				StatusCode:    statusWriter.StatusCode(),
+				ClientIp:      ctx.ClientIp(),
+				AdminId:       ctx.AdminId(),
+				ApplicationId: authData.ApplicationId,
+				UserIdentity:  userAuthData.Identity,
+				Events:        events,
+			}
+			if requestBody != nil {
+				bodyHash, complete, hashErr := requestBody.sum()
+				if hashErr != nil {
+					logger.Error(ctx.Context(), errors.WithMessage(hashErr, "audit: read request body"))
+				}
+				record.RequestBodyHash = bodyHash
+				record.RequestBodyHashUnknown = !complete
+			}
+
+			writeErr := writer.Write(ctx.Context(), record)
+			if writeErr != nil {
+				logger.Error(ctx.Context(), errors.WithMessage(writeErr, "audit: write record"))
+			}
+
+			return err
+		})
+	}
+}
+
+// hashingBody считает хеш тела запроса по мере чтения,
+// закрытие откладывается до записи в журнал, чтобы дочитать непрочитанный остаток,
+// транспорт может читать тело в своей горутине и после получения ответа
+type hashingBody struct {
+	io.ReadCloser
+
+	lock *sync.Mutex
+	hash hash.Hash
+	size int64
+}
+
+func newHashingBody(body io.ReadCloser) *hashingBody {
+	return &hashingBody{
+		ReadCloser: body,
+		lock:       &sync.Mutex{},
+		hash:       sha256.New(),
+	}
+}
+
+func (b *hashingBody) Read(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.read(p)
+}
+
+func (b *hashingBody) read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.hash.Write(p[:n])
+	b.size += int64(n)
+	return n, err // nolint:wrapcheck
+}
+
+func (b *hashingBody) Close() error {
+	return nil
+}
+
+// sum возвращает хеш тела и false,если тело не удалось прочитать до конца
+func (b *hashingBody) sum() (string, bool, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	buf := make([]byte, 32*1024) // nolint:mnd
+	drained := 0
+	for {
+		if drained > auditBodyDrainLimit {
+			return "", false, nil
+		}
+		n, err := b.read(buf)
+		drained += n
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", false, errors.WithMessage(err, "read body")
+		}
+	}
+	if b.size == 0 {
+		return "", true, nil
+	}
+	return hex.EncodeToString(b.hash.Sum(nil)), true, nil
+}
+
+func (b *hashingBody) close() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	_ = b.ReadCloser.Close()
+}
@@ -3,6 +3,7 @@ package middleware
 import (
 	"context"
 	"net/http"
+	"strings"
 
 	"isp-gate-service/domain"
 	"isp-gate-service/httperrors"
@@ -11,22 +12,28 @@ import (
 	"github.com/pkg/errors"
 )
 
-const (
-	applicationTokenHeader = "x-application-token"
-)
-
 type Authenticator interface {
 	Authenticate(ctx context.Context, token string) (*domain.AuthenticateAppResponse, error)
 }
 
-func Authenticate(authenticator Authenticator) Middleware {
+type TokenProvider interface {
+	GetName() string
+	CredentialSource() request.Credential
+	ExtractToken(ctx *request.Context) (string, error)
+}
+
+type credentialsProvider interface {
+	ExtractCredentials(ctx *request.Context) (string, string, bool)
+}
+
+func Authenticate(authenticator Authenticator, tokenProviders []TokenProvider) Middleware {
 	return func(next Handler) Handler {
 		return HandlerFunc(func(ctx *request.Context) error {
-			if ctx.SkipAppAuth() {
+			if ctx.SkipAppAuth() || ctx.IsAuthenticated() {
 				return next.Handle(ctx)
 			}
 
-			token, appName, err := extractToken(ctx)
+			token, appName, err := extractToken(ctx, tokenProviders)
 			if err != nil {
 				return err
 			}
@@ -65,26 +72,58 @@ func Authenticate(authenticator Authenticator) Middleware {
 	}
 }
 
-func extractToken(ctx *request.Context) (string, string, error) {
-	var (
-		appName string
-		token   string
-		ok      bool
-	)
+func extractToken(ctx *request.Context, tokenProviders []TokenProvider) (string, string, error) {
+	for _, provider := range tokenProviders {
+		credentials, isCredentials := provider.(credentialsProvider)
+		if isCredentials {
+			appName, token, ok := credentials.ExtractCredentials(ctx)
+			if !ok {
+				continue
+			}
+			if appName == "" {
+				return "", "", httperrors.New(
+					http.StatusUnauthorized,
+					"application name required",
+					errors.New("authenticate: application name required on basic auth"),
+				)
+			}
+			token = strings.TrimSpace(token)
+			if token != "" {
+				consumeCredentials(ctx, tokenProviders)
+				return token, appName, nil
+			}
+			continue
+		}
 
-	token = ctx.Param(applicationTokenHeader)
-	if token != "" {
-		return token, "", nil
+		token, err := provider.ExtractToken(ctx)
+		if err != nil {
+			return "", "", httperrors.New(
+				http.StatusUnauthorized,
+				"invalid application token",
+				errors.WithMessagef(err, "authenticate: extract token by '%s'", provider.GetName()),
+			)
+		}
+		if token != "" {
+			consumeCredentials(ctx, tokenProviders)
+			return token, "", nil
+		}
 	}
 
-	appName, token, ok = ctx.Request().BasicAuth()
-	if ok && appName == "" {
-		return "", "", httperrors.New(
-			http.StatusUnauthorized,
-			"application name required",
-			errors.New("authenticate: application name required on basic auth"),
-		)
-	}
+	return "", "", nil
+}
 
-	return token, appName, nil
+// consumeCredentials помечает для удаления источники всех провайдеров токена,
+// копия токена в другом источнике не должна попасть в upstream,
+// заголовок Authorization без схемы Basic не относится к учетным данным приложения и не удаляется
+func consumeCredentials(ctx *request.Context, tokenProviders []TokenProvider) {
+	for _, provider := range tokenProviders {
+		credentials, isCredentials := provider.(credentialsProvider)
+		if isCredentials {
+			_, _, ok := credentials.ExtractCredentials(ctx)
+			if !ok {
+				continue
+			}
+		}
+		ctx.ConsumeCredential(provider.CredentialSource())
+	}
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 
+	"isp-gate-service/domain"
 	"isp-gate-service/httperrors"
 	"isp-gate-service/request"
 
@@ -45,9 +46,11 @@ func Authorize(authorizer Authorizer, logger log.Logger) Middleware {
 					log.String("applicationName", authData.AppName),
 					log.Int("adminId", ctx.AdminId()),
 				)
+				ctx.AddAuditEvent(domain.AuditAuthorizationBypassEvent)
 				return next.Handle(ctx)
 			}
 			if !ok {
+				ctx.AddAuditEvent(domain.AuditAuthorizationDeniedEvent)
 				return httperrors.New(
 					http.StatusForbidden,
 					"endpoint is not allowed",
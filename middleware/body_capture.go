@@ -0,0 +1,234 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	NoneBinaryBodyLogMode   = "NONE"
+	Base64BinaryBodyLogMode = "BASE64"
+	HashBinaryBodyLogMode   = "HASH"
+)
+
+type BodyLogConfig struct {
+	MaxBytes     int
+	ContentTypes []string
+	BinaryMode   string
+}
+
+// bodyCapture сохраняет не больше limit байт тела,для бинарного содержимого в режиме HASH
+// хеш считается по всему потоку без сохранения тела в памяти
+type bodyCapture struct {
+	config      BodyLogConfig
+	contentType string
+	classified  bool
+	binary      bool
+	buf         bytes.Buffer
+	total       int64
+	complete    bool
+	hasher      hash.Hash
+}
+
+func newBodyCapture(config BodyLogConfig) *bodyCapture {
+	return &bodyCapture{
+		config: config,
+	}
+}
+
+func (c *bodyCapture) write(contentType string, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	if !c.classified {
+		c.classify(contentType, data)
+	}
+
+	c.total += int64(len(data))
+	if c.hasher != nil {
+		_, _ = c.hasher.Write(data)
+	}
+	remaining := c.config.MaxBytes - c.buf.Len()
+	if remaining > 0 {
+		c.buf.Write(data[:min(remaining, len(data))])
+	}
+}
+
+func (c *bodyCapture) classify(contentType string, data []byte) {
+	c.classified = true
+	c.contentType = contentType
+	if c.contentType == "" {
+		c.contentType = http.DetectContentType(data)
+	}
+	c.binary = !matchContentType(c.config.ContentTypes, c.contentType)
+	if c.binary && c.config.BinaryMode == HashBinaryBodyLogMode {
+		c.hasher = sha256.New()
+	}
+}
+
+func (c *bodyCapture) truncated() bool {
+	return c.total > int64(c.buf.Len()) || !c.complete
+}
+
+// text возвращает сохраненную часть текстового тела,false для бинарного содержимого
+func (c *bodyCapture) text() ([]byte, bool) {
+	return c.buf.Bytes(), !c.binary
+}
+
+func (c *bodyCapture) truncationMarker(expectedSize int64) string {
+	if !c.truncated() {
+		return ""
+	}
+	total := max(c.total, expectedSize)
+	if total > int64(c.buf.Len()) {
+		return fmt.Sprintf("...[truncated, %d of %d bytes]", c.buf.Len(), total)
+	}
+	return "...[truncated]"
+}
+
+func (c *bodyCapture) binarySummary(expectedSize int64) string {
+	total := max(c.total, expectedSize)
+	summary := fmt.Sprintf("[binary body: %s, %d bytes", c.contentType, total)
+	switch c.config.BinaryMode {
+	case Base64BinaryBodyLogMode:
+		summary += ", base64: " + base64.StdEncoding.EncodeToString(c.buf.Bytes())
+		if c.total > int64(c.buf.Len()) {
+			summary += "..."
+		}
+	case HashBinaryBodyLogMode:
+		if c.complete {
+			summary += ", sha256: " + hex.EncodeToString(c.hasher.Sum(nil))
+		}
+	}
+	return summary + "]"
+}
+
+// matchContentType сравнивает media type без параметров,* в шаблоне соответствует любой подстроке
+func matchContentType(patterns []string, contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(contentType))
+	}
+	for _, pattern := range patterns {
+		prefix, suffix, wildcard := strings.Cut(strings.ToLower(pattern), "*")
+		if !wildcard && mediaType == prefix {
+			return true
+		}
+		if wildcard && len(mediaType) >= len(prefix)+len(suffix) &&
+			strings.HasPrefix(mediaType, prefix) && strings.HasSuffix(mediaType, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestBodyCapture транспорт может читать тело в своей горутине и после получения ответа,
+// после drain прочитанные данные в capture не попадают
+type requestBodyCapture struct {
+	body        io.ReadCloser
+	contentType string
+	capture     *bodyCapture
+	lock        *sync.Mutex
+	drained     bool
+}
+
+func newRequestBodyCapture(body io.ReadCloser, contentType string, capture *bodyCapture) *requestBodyCapture {
+	return &requestBodyCapture{
+		body:        body,
+		contentType: contentType,
+		capture:     capture,
+		lock:        &sync.Mutex{},
+	}
+}
+
+func (r *requestBodyCapture) Read(p []byte) (int, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.read(p)
+}
+
+func (r *requestBodyCapture) read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	if r.drained {
+		return n, err // nolint:wrapcheck
+	}
+	r.capture.write(r.contentType, p[:n])
+	if errors.Is(err, io.EOF) {
+		r.capture.complete = true
+	}
+	return n, err // nolint:wrapcheck
+}
+
+func (r *requestBodyCapture) Close() error {
+	return r.body.Close()
+}
+
+// drain дочитывает тело,которое не было прочитано upstream,но не больше лимита логирования
+func (r *requestBodyCapture) drain() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	remaining := int64(r.capture.config.MaxBytes - r.capture.buf.Len())
+	if !r.capture.complete && remaining >= 0 {
+		// лишний байт показывает,что тело длиннее лимита
+		_, _ = io.Copy(io.Discard, io.LimitReader(readerFunc(r.read), remaining+1))
+	}
+	r.drained = true
+}
+
+type readerFunc func(p []byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) {
+	return f(p)
+}
+
+type responseBodyCapture struct {
+	http.ResponseWriter
+
+	statusCode int
+	capture    *bodyCapture
+}
+
+func (w *responseBodyCapture) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *responseBodyCapture) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	upstream, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("responseBodyCapture: upstream writer doesn't implement Hijack")
+	}
+	return upstream.Hijack()
+}
+
+func (w *responseBodyCapture) StatusCode() int {
+	if w.statusCode == 0 {
+		return http.StatusOK
+	}
+	return w.statusCode
+}
+
+func (w *responseBodyCapture) WriteHeader(statusCode int) {
+	w.statusCode = statusCode
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *responseBodyCapture) Write(data []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(data)
+	w.capture.write(w.Header().Get("Content-Type"), data[:n])
+	return n, err // nolint:wrapcheck
+}
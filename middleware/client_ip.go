@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"net/http"
+
+	"isp-gate-service/request"
+)
+
+type ClientIpResolver interface {
+	Resolve(req *http.Request) (string, bool)
+}
+
+func ClientIp(resolver ClientIpResolver) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx *request.Context) error {
+			clientIp, trustedPeer := resolver.Resolve(ctx.Request())
+			ctx.SetClientIp(clientIp, trustedPeer)
+			return next.Handle(ctx)
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package middleware
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	regexpOriginPrefix = "~"
+)
+
+var (
+	defaultCorsMethods = []string{ // nolint:gochecknoglobals
+		http.MethodGet,
+		http.MethodHead,
+		http.MethodPost,
+		http.MethodPut,
+		http.MethodPatch,
+		http.MethodDelete,
+	}
+)
+
+type CorsConfig struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAgeInSec      int
+}
+
+type corsPolicy struct {
+	allowAllOrigins  bool
+	exactOrigins     map[string]bool
+	originPatterns   []*regexp.Regexp
+	allowedMethods   map[string]bool
+	methods          string
+	allowedHeaders   string
+	exposedHeaders   string
+	allowCredentials bool
+	maxAge           string
+}
+
+// Cors отвечает на preflight запросы до аутентификации и разрешения endpoint,
+// добавляет CORS заголовки к остальным ответам, заменяя заголовки upstream,
+// для неразрешенного Origin заголовки upstream удаляются без добавления разрешающих
+func Cors(cfg CorsConfig, next http.Handler) (http.Handler, error) {
+	policy, err := newCorsPolicy(cfg)
+	if err != nil {
+		return nil, errors.WithMessage(err, "cors: new policy")
+	}
+
+	return http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		origin := req.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(writer, req)
+			return
+		}
+
+		isPreflight := req.Method == http.MethodOptions && req.Header.Get("Access-Control-Request-Method") != ""
+		allowed := policy.isOriginAllowed(origin)
+		if isPreflight {
+			policy.handlePreflight(writer, req, origin, allowed)
+			return
+		}
+
+		next.ServeHTTP(&corsWriter{ResponseWriter: writer, policy: policy, origin: origin, allowed: allowed}, req)
+	}), nil
+}
+
+func newCorsPolicy(cfg CorsConfig) (*corsPolicy, error) {
+	policy := &corsPolicy{
+		exactOrigins:     make(map[string]bool),
+		allowedMethods:   make(map[string]bool),
+		allowedHeaders:   strings.Join(cfg.AllowedHeaders, ", "),
+		exposedHeaders:   strings.Join(cfg.ExposedHeaders, ", "),
+		allowCredentials: cfg.AllowCredentials,
+	}
+	if cfg.MaxAgeInSec > 0 {
+		policy.maxAge = strconv.Itoa(cfg.MaxAgeInSec)
+	}
+
+	for _, origin := range cfg.AllowedOrigins {
+		switch {
+		case origin == "*":
+			policy.allowAllOrigins = true
+		case strings.HasPrefix(origin, regexpOriginPrefix):
+			pattern, err := regexp.Compile(strings.TrimPrefix(origin, regexpOriginPrefix))
+			if err != nil {
+				return nil, errors.WithMessagef(err, "compile origin regexp '%s'", origin)
+			}
+			policy.originPatterns = append(policy.originPatterns, pattern)
+		case strings.Contains(origin, "*"):
+			quoted := strings.ReplaceAll(regexp.QuoteMeta(strings.ToLower(origin)), `\*`, `[^/]*`)
+			policy.originPatterns = append(policy.originPatterns, regexp.MustCompile("^"+quoted+"$"))
+		default:
+			policy.exactOrigins[strings.ToLower(origin)] = true
+		}
+	}
+
+	// браузер не принимает '*' для запросов с учетными данными,а подстановка Origin разрешила бы их любому сайту
+	if policy.allowAllOrigins && policy.allowCredentials {
+		return nil, errors.New("allowed origin '*' can't be used with allow credentials")
+	}
+
+	configuredMethods := cfg.AllowedMethods
+	if len(configuredMethods) == 0 {
+		configuredMethods = defaultCorsMethods
+	}
+	methods := make([]string, 0, len(configuredMethods))
+	for _, method := range configuredMethods {
+		method = strings.ToUpper(strings.TrimSpace(method))
+		policy.allowedMethods[method] = true
+		methods = append(methods, method)
+	}
+	policy.methods = strings.Join(methods, ", ")
+
+	return policy, nil
+}
+
+func (p *corsPolicy) isOriginAllowed(origin string) bool {
+	if p.allowAllOrigins {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if p.exactOrigins[origin] {
+		return true
+	}
+	for _, pattern := range p.originPatterns {
+		if pattern.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *corsPolicy) handlePreflight(w http.ResponseWriter, req *http.Request, origin string, allowed bool) {
+	header := w.Header()
+	header.Add("Vary", "Origin")
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+
+	requestedMethod := strings.ToUpper(req.Header.Get("Access-Control-Request-Method"))
+	if !allowed || !p.allowedMethods[requestedMethod] {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	p.writeOriginHeaders(header, origin)
+	header.Set("Access-Control-Allow-Methods", p.methods)
+	allowedHeaders := p.allowedHeaders
+	if allowedHeaders == "" {
+		allowedHeaders = req.Header.Get("Access-Control-Request-Headers")
+	}
+	if allowedHeaders != "" {
+		header.Set("Access-Control-Allow-Headers", allowedHeaders)
+	}
+	if p.maxAge != "" {
+		header.Set("Access-Control-Max-Age", p.maxAge)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (p *corsPolicy) writeOriginHeaders(header http.Header, origin string) {
+	if p.allowAllOrigins {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+	if p.allowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+type corsWriter struct {
+	http.ResponseWriter
+
+	policy      *corsPolicy
+	origin      string
+	allowed     bool
+	wroteHeader bool
+}
+
+func (w *corsWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.decorate()
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *corsWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *corsWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *corsWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	upstream, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("corsWriter: upstream writer doesn't implement Hijack")
+	}
+	return upstream.Hijack()
+}
+
+func (w *corsWriter) decorate() {
+	header := w.Header()
+	for key := range header {
+		if strings.HasPrefix(key, "Access-Control-") {
+			delete(header, key)
+		}
+	}
+	header.Add("Vary", "Origin")
+	if !w.allowed {
+		return
+	}
+	w.policy.writeOriginHeaders(header, w.origin)
+	if w.policy.exposedHeaders != "" {
+		header.Set("Access-Control-Expose-Headers", w.policy.exposedHeaders)
+	}
+}
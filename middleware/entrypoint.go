@@ -2,11 +2,14 @@ package middleware
 
 import (
 	"isp-gate-service/domain"
+	"isp-gate-service/httperrors"
 	"isp-gate-service/request"
 	"net/http"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/txix-open/isp-kit/log"
+	"github.com/txix-open/isp-kit/requestid"
 )
 
 type EntryPointConfig struct {
@@ -14,6 +17,7 @@ type EntryPointConfig struct {
 	WithPrefix             bool
 	ErrorOnUnknownEndpoint bool
 	WithLendingSlash       bool
+	ProblemJsonErrors      bool
 }
 
 type EndpointResolver interface {
@@ -21,21 +25,31 @@ type EndpointResolver interface {
 	GetPaths(path string, cfg EntryPointConfig) (string, string)
 }
 
+type EntrypointMetrics interface {
+	CountUnknownEndpoint(location string)
+}
+
 func Entrypoint(
 	maxReqBodySize int64,
 	next Handler,
 	cfg EntryPointConfig,
 	entryPointResolver EndpointResolver,
+	requestIds RequestIdGenerator,
+	metrics EntrypointMetrics,
 	logger log.Logger,
 ) http.Handler {
 	return http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
 		req.Body = http.MaxBytesReader(writer, req.Body, maxReqBodySize)
 
+		startedAt := time.Now()
 		endpoint, err := entryPointResolver.ResolveEndpoint(req.Method, req.URL.Path, cfg)
 		if err != nil {
+			metrics.CountUnknownEndpoint(cfg.PathPrefix)
+			requestId := requestIds.Next()
+			context := log.ToContext(req.Context(), log.String(requestid.LogKey, requestId))
 			lookupPath, endpoint := entryPointResolver.GetPaths(req.URL.Path, cfg)
 			logger.Warn(
-				req.Context(),
+				context,
 				"call unknown method",
 				log.String("pathPrefix", cfg.PathPrefix),
 				log.String("httpMethod", req.Method),
@@ -44,11 +58,21 @@ func Entrypoint(
 				log.String("enpoint", endpoint),
 			)
 
-			http.Error(writer, err.Error(), http.StatusNotImplemented)
+			writer.Header().Set(requestid.Header, requestId)
+			httpErr := httperrors.New(http.StatusNotImplemented, err.Error(), err)
+			if cfg.ProblemJsonErrors {
+				err = httpErr.WriteProblem(writer, requestId)
+			} else {
+				err = httpErr.WriteError(writer, requestId)
+			}
+			if err != nil {
+				logger.Error(context, errors.WithMessage(err, "write unknown endpoint error"))
+			}
 			return
 		}
 
 		ctx := request.NewContext(req, writer, endpoint)
+		ctx.AddTiming(domain.ResolveTiming, time.Since(startedAt))
 
 		err = next.Handle(ctx)
 		if err != nil {
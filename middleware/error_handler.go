@@ -4,15 +4,17 @@ import (
 	"net/http"
 
 	"github.com/txix-open/isp-kit/log"
+	"github.com/txix-open/isp-kit/requestid"
 	"isp-gate-service/httperrors"
 	"isp-gate-service/request"
 )
 
 type HttpError interface {
-	WriteError(w http.ResponseWriter) error
+	WriteError(w http.ResponseWriter, requestId string) error
+	WriteProblem(w http.ResponseWriter, requestId string) error
 }
 
-func ErrorHandler(logger log.Logger) Middleware {
+func ErrorHandler(logger log.Logger, problemJson bool) Middleware {
 	return func(next Handler) Handler {
 		return HandlerFunc(func(ctx *request.Context) error {
 			err := next.Handle(ctx)
@@ -23,13 +25,14 @@ func ErrorHandler(logger log.Logger) Middleware {
 			logger.Error(ctx.Context(), err)
 
 			httpErr, ok := err.(HttpError)
-			if ok {
-				return httpErr.WriteError(ctx.ResponseWriter())
+			if !ok {
+				httpErr = httperrors.New(http.StatusInternalServerError, "internal service error", err)
 			}
-
-			return httperrors.
-				New(http.StatusInternalServerError, "internal service error", err).
-				WriteError(ctx.ResponseWriter())
+			requestId := requestid.FromContext(ctx.Context())
+			if problemJson {
+				return httpErr.WriteProblem(ctx.ResponseWriter(), requestId)
+			}
+			return httpErr.WriteError(ctx.ResponseWriter(), requestId)
 		})
 	}
 }
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"time"
+
+	"isp-gate-service/internaltoken"
+	"isp-gate-service/request"
+
+	"github.com/pkg/errors"
+	"github.com/txix-open/isp-kit/requestid"
+)
+
+type InternalTokenSigner interface {
+	Sign(claims any) (string, error)
+}
+
+type InternalTokenConfig struct {
+	Enable     bool
+	HeaderName string
+	Issuer     string
+	Audience   string
+	Ttl        time.Duration
+}
+
+// InternalToken выпускает подписанный токен с данными аутентификации для передачи в upstream,
+// одноименный заголовок входящего запроса удаляется всегда, даже если выпуск токена выключен
+func InternalToken(signer InternalTokenSigner, cfg InternalTokenConfig) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx *request.Context) error {
+			ctx.Request().Header.Del(cfg.HeaderName)
+			if !cfg.Enable {
+				return next.Handle(ctx)
+			}
+
+			now := time.Now()
+			claims := internaltoken.Claims{
+				Issuer:     cfg.Issuer,
+				Audience:   cfg.Audience,
+				IssuedAt:   now.Unix(),
+				ExpiresAt:  now.Add(cfg.Ttl).Unix(),
+				RequestId:  requestid.FromContext(ctx.Context()),
+				HttpMethod: ctx.Request().Method,
+				Endpoint:   ctx.EndpointMeta().Endpoint,
+			}
+			appAuthData, err := ctx.GetAuthData()
+			if err == nil {
+				claims.Application = &internaltoken.ApplicationClaims{
+					Id:        appAuthData.ApplicationId,
+					Name:      appAuthData.AppName,
+					SystemId:  appAuthData.SystemId,
+					DomainId:  appAuthData.DomainId,
+					ServiceId: appAuthData.ServiceId,
+				}
+			}
+			userAuthData, err := ctx.GetUserAuthData()
+			if err == nil {
+				claims.User = &internaltoken.UserClaims{
+					Identity:    userAuthData.Identity,
+					Roles:       userAuthData.Roles,
+					Permissions: userAuthData.Permissions,
+				}
+			}
+			if ctx.IsAdminAuthenticated() {
+				claims.Admin = &internaltoken.AdminClaims{Id: ctx.AdminId()}
+			}
+
+			token, err := signer.Sign(claims)
+			if err != nil {
+				return errors.WithMessage(err, "internal token: sign")
+			}
+			ctx.SetInternalToken(cfg.HeaderName, token)
+
+			return next.Handle(ctx)
+		})
+	}
+}
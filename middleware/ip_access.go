@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http"
+
+	"isp-gate-service/domain"
+	"isp-gate-service/httperrors"
+	"isp-gate-service/request"
+
+	"github.com/pkg/errors"
+	"github.com/txix-open/isp-kit/log"
+)
+
+type IpAccessChecker interface {
+	CheckLocation(clientIp string) domain.IpAccessResult
+	CheckApplication(applicationId int, clientIp string) domain.IpAccessResult
+}
+
+type IpAccessMetrics interface {
+	CountIpAccessDenied(reason string)
+}
+
+// IpAccess проверяет IP адрес клиента по правилам location и приложения,
+// должен выполняться после аутентификации приложения
+func IpAccess(checker IpAccessChecker, metrics IpAccessMetrics, logger log.Logger) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx *request.Context) error {
+			clientIp := ctx.ClientIp()
+			applicationId := 0
+			result := checker.CheckLocation(clientIp)
+			if result.Allow && !ctx.SkipAppAuth() {
+				authData, err := ctx.GetAuthData()
+				if err == nil {
+					applicationId = authData.ApplicationId
+					result = checker.CheckApplication(applicationId, clientIp)
+				}
+			}
+			if result.Allow {
+				return next.Handle(ctx)
+			}
+
+			metrics.CountIpAccessDenied(result.Reason)
+			logger.Warn(
+				ctx.Context(),
+				"ip access: request denied",
+				log.String("reason", result.Reason),
+				log.String("clientIp", clientIp),
+				log.Int("applicationId", applicationId),
+			)
+			return httperrors.New(
+				http.StatusForbidden,
+				"access denied",
+				errors.Errorf("ip access: client ip '%s' is denied by %s", clientIp, result.Reason),
+			)
+		})
+	}
+}
@@ -3,16 +3,18 @@ package middleware
 import (
 	"bufio"
 	"bytes"
-	"io"
+	"context"
+	"math/rand/v2"
 	"net"
 	"net/http"
 	"strings"
+	"time"
 
+	"isp-gate-service/domain"
 	"isp-gate-service/helpers"
 	"isp-gate-service/request"
 
 	"github.com/pkg/errors"
-	"github.com/txix-open/isp-kit/http/endpoint/buffer"
 	"github.com/txix-open/isp-kit/log"
 )
 
@@ -20,6 +22,10 @@ var (
 	unicodeEscapePrefix = []byte("\\u") // nolint:gochecknoglobals
 )
 
+type BodyMasker interface {
+	Mask(endpoint string, body []byte) []byte
+}
+
 type scSource interface {
 	StatusCode() int
 }
@@ -30,6 +36,10 @@ type writerWrapper struct {
 	statusCode int
 }
 
+func (w *writerWrapper) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 func (w *writerWrapper) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	upstream, ok := w.ResponseWriter.(http.Hijacker)
 	if !ok {
@@ -50,50 +60,65 @@ func (w *writerWrapper) WriteHeader(statusCode int) {
 	w.ResponseWriter.WriteHeader(statusCode)
 }
 
-func Logger( // nolint:gocognit,funlen
+type LoggerConfig struct {
+	Location                        string
+	RequestLogEnable                bool
+	BodyLogEnable                   bool
+	BodyLogSupported                bool
+	SkipBodyLoggingEndpointPrefixes []string
+	EnableForceUnescapingUnicode    bool
+	Body                            BodyLogConfig
+}
+
+type LoggingOverrides interface {
+	Enabled() bool
+	BodyLogPossible(location string, endpoint string, now time.Time) bool
+	Find(req domain.LoggingOverrideRequest) (domain.LoggingOverride, bool)
+}
+
+func Logger( // nolint:gocognit,funlen,cyclop
 	logger log.Logger,
-	enableRequestLogging bool,
-	enableBodyLogging bool,
-	skipBodyLoggingEndpointPrefixes []string,
-	enableForceUnescapingUnicode bool,
+	cfg LoggerConfig,
+	bodyMasker BodyMasker,
+	overrides LoggingOverrides,
 ) Middleware {
 	return func(next Handler) Handler {
 		return HandlerFunc(func(ctx *request.Context) error {
-			if !enableRequestLogging {
+			if !cfg.RequestLogEnable && !overrides.Enabled() {
 				return next.Handle(ctx)
 			}
 
 			r := ctx.Request()
+			startedAt := time.Now()
 
 			endpoint := ctx.EndpointMeta().NormalizedEndpoint
-			logBodyFromCurrenRequest := enableBodyLogging
+			logBodyFromCurrenRequest := cfg.BodyLogEnable && cfg.BodyLogSupported
 			if logBodyFromCurrenRequest {
-				for _, prefix := range skipBodyLoggingEndpointPrefixes {
+				for _, prefix := range cfg.SkipBodyLoggingEndpointPrefixes {
 					if strings.HasPrefix(endpoint, prefix) {
 						logBodyFromCurrenRequest = false
 						break
 					}
 				}
 			}
+			// приложение и пользователь еще не известны,поэтому тело сохраняется,
+			// если его логирование может включить хотя бы одно правило
+			captureBody := logBodyFromCurrenRequest ||
+				cfg.BodyLogSupported && overrides.BodyLogPossible(cfg.Location, endpoint, startedAt)
 
 			var scSrc scSource
-			var buf *buffer.Buffer
-			if logBodyFromCurrenRequest {
-				buf = buffer.Acquire(ctx.ResponseWriter())
-				defer buffer.Release(buf)
-
-				err := buf.ReadRequestBody(r.Body)
-				if err != nil {
-					return errors.WithMessage(err, "logger: read request body for logging")
+			var requestBody *requestBodyCapture
+			var responseBody *responseBodyCapture
+			if captureBody {
+				requestBody = newRequestBodyCapture(r.Body, r.Header.Get("Content-Type"), newBodyCapture(cfg.Body))
+				r.Body = requestBody
+
+				responseBody = &responseBodyCapture{
+					ResponseWriter: ctx.ResponseWriter(),
+					capture:        newBodyCapture(cfg.Body),
 				}
-				err = r.Body.Close()
-				if err != nil {
-					return errors.WithMessage(err, "logger: close request reader")
-				}
-				r.Body = io.NopCloser(bytes.NewBuffer(buf.RequestBody()))
-
-				scSrc = buf
-				ctx.SetResponseWriter(buf)
+				scSrc = responseBody
+				ctx.SetResponseWriter(responseBody)
 			} else {
 				writer := &writerWrapper{ResponseWriter: ctx.ResponseWriter()}
 				scSrc = writer
@@ -105,11 +130,37 @@ func Logger( // nolint:gocognit,funlen
 			err := next.Handle(ctx)
 
 			authData, _ := ctx.GetAuthData()
+			userAuthData, _ := ctx.GetUserAuthData()
+			enableRequestLogging := cfg.RequestLogEnable
+			successLevel := log.DebugLevel
+			sampleRate := 1.0
+			override, ok := overrides.Find(domain.LoggingOverrideRequest{
+				Location:      cfg.Location,
+				Endpoint:      endpoint,
+				ApplicationId: authData.ApplicationId,
+				UserIdentity:  userAuthData.Identity,
+				Time:          startedAt,
+			})
+			if ok {
+				enableRequestLogging = override.RequestLogEnable
+				logBodyFromCurrenRequest = override.BodyLogEnable && captureBody
+				successLevel = override.Level
+				sampleRate = override.SampleRate
+			}
+			if !enableRequestLogging {
+				return err
+			}
+			statusCode := scSrc.StatusCode()
+			if statusCode < http.StatusBadRequest && sampleRate < 1 && rand.Float64() >= sampleRate { // nolint:gosec
+				return err
+			}
+
 			fields := []log.Field{
 				log.String("httpMethod", r.Method),
 				log.String("remoteAddr", r.RemoteAddr),
+				log.String("clientIp", ctx.ClientIp()),
 				log.String("xForwardedFor", r.Header.Get("X-Forwarded-For")),
-				log.Int("statusCode", scSrc.StatusCode()),
+				log.Int("statusCode", statusCode),
 				log.String("path", originalPath),
 				log.String("endpoint", endpoint),
 				log.Int("applicationId", authData.ApplicationId),
@@ -118,25 +169,64 @@ func Logger( // nolint:gocognit,funlen
 			}
 
 			if logBodyFromCurrenRequest {
-				if enableForceUnescapingUnicode && bytes.Contains(buf.RequestBody(), unicodeEscapePrefix) {
-					fields = append(fields, log.ByteString("request", helpers.UnescapeUnicode(buf.RequestBody())))
-				} else {
-					fields = append(fields, log.ByteString("request", buf.RequestBody()))
-				}
-
-				fields = append(fields, log.ByteString("response", buf.ResponseBody()))
+				requestBody.drain()
+				responseBody.capture.complete = true
+				fields = append(fields,
+					log.ByteString("request", loggedBody(
+						requestBody.capture, r.ContentLength, endpoint, bodyMasker, cfg.EnableForceUnescapingUnicode,
+					)),
+					log.ByteString("response", loggedBody(
+						responseBody.capture, -1, endpoint, bodyMasker, cfg.EnableForceUnescapingUnicode,
+					)),
+				)
 			}
 
 			switch {
-			case scSrc.StatusCode() >= http.StatusInternalServerError:
+			case statusCode >= http.StatusInternalServerError:
 				logger.Error(ctx.Context(), "log request", fields...)
-			case scSrc.StatusCode() >= http.StatusBadRequest:
+			case statusCode >= http.StatusBadRequest:
 				logger.Warn(ctx.Context(), "log request", fields...)
 			default:
-				logger.Debug(ctx.Context(), "log request", fields...)
+				logRequest(ctx.Context(), logger, successLevel, fields)
 			}
 
 			return err
 		})
 	}
 }
+
+func logRequest(ctx context.Context, logger log.Logger, level log.Level, fields []log.Field) {
+	switch level {
+	case log.DebugLevel:
+		logger.Debug(ctx, "log request", fields...)
+	case log.InfoLevel:
+		logger.Info(ctx, "log request", fields...)
+	case log.WarnLevel:
+		logger.Warn(ctx, "log request", fields...)
+	default:
+		logger.Error(ctx, "log request", fields...)
+	}
+}
+
+func loggedBody(
+	capture *bodyCapture,
+	expectedSize int64,
+	endpoint string,
+	bodyMasker BodyMasker,
+	enableForceUnescapingUnicode bool,
+) []byte {
+	body, isText := capture.text()
+	if !isText {
+		return []byte(capture.binarySummary(expectedSize))
+	}
+
+	body = bodyMasker.Mask(endpoint, body)
+	if enableForceUnescapingUnicode && bytes.Contains(body, unicodeEscapePrefix) {
+		body = helpers.UnescapeUnicode(body)
+	}
+	marker := capture.truncationMarker(expectedSize)
+	if marker != "" {
+		body = append(body[:len(body):len(body)], marker...)
+	}
+	return body
+}
@@ -1,12 +1,23 @@
 package middleware
 
 import (
+	"isp-gate-service/gatemetrics"
 	"isp-gate-service/request"
 	"time"
 
 	"github.com/txix-open/isp-kit/metrics/http_metrics"
 )
 
+type RequestMetrics interface {
+	ObserveRequest(req gatemetrics.Request, applicationIdsLimit int)
+}
+
+type RequestMetricsConfig struct {
+	Location            string
+	TargetModule        string
+	ApplicationIdsLimit int
+}
+
 func Metrics(storage *http_metrics.ServerStorage) Middleware {
 	return func(next Handler) Handler {
 		return HandlerFunc(func(ctx *request.Context) error {
@@ -19,13 +30,46 @@ func Metrics(storage *http_metrics.ServerStorage) Middleware {
 
 			start := time.Now()
 			err := next.Handle(ctx)
-			if err != nil {
-				return err
-			}
-
 			storage.ObserveDuration(r.Method, pathSchema, time.Since(start))
 
-			return nil
+			return err
+		})
+	}
+}
+
+// Stage отмечает этап обработки запроса для метрик,запрос,не переданный этапом дальше,считается отклоненным этим этапом
+func Stage(stage string, middleware Middleware) Middleware {
+	return func(next Handler) Handler {
+		handler := middleware(next)
+		return HandlerFunc(func(ctx *request.Context) error {
+			ctx.SetStage(stage)
+			return handler.Handle(ctx)
+		})
+	}
+}
+
+// RequestsMetrics учитывает все запросы location,включая отклоненные шлюзом,
+// должен выполняться до ErrorHandler
+func RequestsMetrics(metrics RequestMetrics, cfg RequestMetricsConfig) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx *request.Context) error {
+			statusWriter := &writerWrapper{ResponseWriter: ctx.ResponseWriter()}
+			ctx.SetResponseWriter(statusWriter)
+
+			start := time.Now()
+			err := next.Handle(ctx)
+
+			authData, _ := ctx.GetAuthData()
+			metrics.ObserveRequest(gatemetrics.Request{
+				Location:      cfg.Location,
+				TargetModule:  cfg.TargetModule,
+				ApplicationId: authData.ApplicationId,
+				StatusCode:    statusWriter.StatusCode(),
+				Stage:         ctx.Stage(),
+				Duration:      time.Since(start),
+			}, cfg.ApplicationIdsLimit)
+
+			return err
 		})
 	}
 }
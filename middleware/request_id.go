@@ -1,23 +1,33 @@
 package middleware
 
 import (
+	"bufio"
+	"net"
+	"net/http"
 	"strings"
 
 	"isp-gate-service/request"
 
+	"github.com/pkg/errors"
 	"github.com/txix-open/isp-kit/log"
 	"github.com/txix-open/isp-kit/requestid"
 )
 
-func RequestId() Middleware {
+type RequestIdGenerator interface {
+	Next() string
+}
+
+// RequestId создает requestId запроса и возвращает его клиенту в заголовке x-request-id
+func RequestId(generator RequestIdGenerator) Middleware {
 	return func(next Handler) Handler {
 		return HandlerFunc(func(ctx *request.Context) error {
-			requestId := requestid.Next()
+			requestId := generator.Next()
 
 			context := requestid.ToContext(ctx.Context(), requestId)
 			context = log.ToContext(context, log.String(requestid.LogKey, requestId))
 
 			ctx.SetContext(context)
+			ctx.SetResponseWriter(&requestIdWriter{ResponseWriter: ctx.ResponseWriter(), ctx: ctx})
 			return next.Handle(ctx)
 		})
 	}
@@ -48,3 +58,45 @@ func ClientRequestId(forwardClientRequestId bool, forwardReqIdByApp map[int]bool
 		})
 	}
 }
+
+// requestIdWriter выставляет заголовок перед отправкой ответа,
+// так как requestId может быть заменен идентификатором клиента в ClientRequestId,
+// а заголовки ответа upstream копируются в ответ в http прокси
+type requestIdWriter struct {
+	http.ResponseWriter
+
+	ctx     *request.Context
+	written bool
+}
+
+func (w *requestIdWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Hijack для websocket заголовок ответа на upgrade выставляется в прокси
+func (w *requestIdWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	upstream, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("requestIdWriter: upstream writer doesn't implement Hijack")
+	}
+	w.written = true
+	return upstream.Hijack()
+}
+
+func (w *requestIdWriter) WriteHeader(statusCode int) {
+	w.setHeader()
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *requestIdWriter) Write(data []byte) (int, error) {
+	w.setHeader()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *requestIdWriter) setHeader() {
+	if w.written {
+		return
+	}
+	w.written = true
+	w.Header().Set(requestid.Header, requestid.FromContext(w.ctx.Context()))
+}
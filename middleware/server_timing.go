@@ -0,0 +1,178 @@
+package middleware
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"isp-gate-service/domain"
+	"isp-gate-service/request"
+
+	"github.com/pkg/errors"
+	"github.com/txix-open/isp-kit/log"
+)
+
+const (
+	ServerTimingHeader = "Server-Timing"
+)
+
+type ServerTimingConfig struct {
+	HeaderEnable          bool
+	TrustedApplicationIds []int
+	AdminEnable           bool
+	SlowRequestThreshold  time.Duration
+}
+
+// TimeStage замеряет время этапа до передачи запроса следующему этапу
+func TimeStage(name string, middleware Middleware) Middleware {
+	return func(next Handler) Handler {
+		handler := middleware(HandlerFunc(func(ctx *request.Context) error {
+			ctx.StopTiming()
+			return next.Handle(ctx)
+		}))
+		return HandlerFunc(func(ctx *request.Context) error {
+			ctx.StartTiming(name)
+			err := handler.Handle(ctx)
+			// запрос отклонен этапом
+			ctx.StopTiming()
+			return err
+		})
+	}
+}
+
+// TimeUpstream замеряет время вызова upstream,должен быть последним в цепочке
+func TimeUpstream() Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx *request.Context) error {
+			ctx.StartTiming(domain.UpstreamTiming)
+			err := next.Handle(ctx)
+			ctx.StopTiming()
+			return err
+		})
+	}
+}
+
+// ServerTiming передает замеры этапов в заголовке Server-Timing доверенным приложениям и администраторам
+// и логирует запросы,длительность которых превысила порог
+func ServerTiming(cfg ServerTimingConfig, logger log.Logger) Middleware {
+	return func(next Handler) Handler {
+		if !cfg.HeaderEnable && cfg.SlowRequestThreshold <= 0 {
+			return next
+		}
+
+		return HandlerFunc(func(ctx *request.Context) error {
+			startedAt := time.Now()
+			if cfg.HeaderEnable {
+				ctx.SetResponseWriter(&serverTimingWriter{
+					ResponseWriter: ctx.ResponseWriter(),
+					ctx:            ctx,
+					cfg:            cfg,
+					startedAt:      startedAt,
+				})
+			}
+
+			err := next.Handle(ctx)
+
+			timings := withTotalTiming(ctx.Timings(), time.Since(startedAt))
+			total := timings[len(timings)-1].Duration
+			if cfg.SlowRequestThreshold <= 0 || total < cfg.SlowRequestThreshold {
+				return err
+			}
+
+			timingsMs := make(map[string]float64, len(timings))
+			for _, timing := range timings {
+				timingsMs[timing.Name] = milliseconds(timing.Duration)
+			}
+			authData, _ := ctx.GetAuthData()
+			logger.Warn(
+				ctx.Context(),
+				"slow request",
+				log.String("httpMethod", ctx.Request().Method),
+				log.String("endpoint", ctx.EndpointMeta().Endpoint),
+				log.Int("applicationId", authData.ApplicationId),
+				log.Int("adminId", ctx.AdminId()),
+				log.Any("timingsMs", timingsMs),
+			)
+
+			return err
+		})
+	}
+}
+
+type serverTimingWriter struct {
+	http.ResponseWriter
+
+	ctx       *request.Context
+	cfg       ServerTimingConfig
+	startedAt time.Time
+	written   bool
+}
+
+func (w *serverTimingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *serverTimingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	upstream, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("serverTimingWriter: upstream writer doesn't implement Hijack")
+	}
+	return upstream.Hijack()
+}
+
+func (w *serverTimingWriter) WriteHeader(statusCode int) {
+	w.writeServerTiming()
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *serverTimingWriter) Write(data []byte) (int, error) {
+	w.writeServerTiming()
+	return w.ResponseWriter.Write(data)
+}
+
+// writeServerTiming заголовок записывается перед отправкой ответа,
+// поэтому время upstream учитывается до получения заголовков ответа
+func (w *serverTimingWriter) writeServerTiming() {
+	if w.written {
+		return
+	}
+	w.written = true
+	if !w.trusted() {
+		return
+	}
+
+	timings := withTotalTiming(w.ctx.Timings(), time.Since(w.startedAt))
+	metrics := make([]string, 0, len(timings))
+	for _, timing := range timings {
+		duration := strconv.FormatFloat(milliseconds(timing.Duration), 'f', -1, 64)
+		metrics = append(metrics, timing.Name+";dur="+duration)
+	}
+	w.Header().Set(ServerTimingHeader, strings.Join(metrics, ", "))
+}
+
+func (w *serverTimingWriter) trusted() bool {
+	if w.cfg.AdminEnable && w.ctx.IsAdminAuthenticated() {
+		return true
+	}
+	authData, err := w.ctx.GetAuthData()
+	return err == nil && slices.Contains(w.cfg.TrustedApplicationIds, authData.ApplicationId)
+}
+
+// withTotalTiming время определения endpoint замеряется до создания контекста запроса,
+// поэтому добавляется к общему времени отдельно
+func withTotalTiming(timings []domain.StageTiming, elapsed time.Duration) []domain.StageTiming {
+	for _, timing := range timings {
+		if timing.Name == domain.ResolveTiming {
+			elapsed += timing.Duration
+		}
+	}
+	return append(timings, domain.StageTiming{Name: domain.TotalTiming, Duration: elapsed})
+}
+
+func milliseconds(duration time.Duration) float64 {
+	return float64(duration.Microseconds()) / 1000 // nolint:mnd
+}
@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"isp-gate-service/domain"
+	"isp-gate-service/httperrors"
+	"isp-gate-service/request"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	applicationIdHeader    = "x-application-id"
+	requestTimestampHeader = "x-request-timestamp"
+	requestNonceHeader     = "x-request-nonce"
+	requestSignatureHeader = "x-request-signature"
+)
+
+type SignedAuthenticator interface {
+	Authenticate(ctx context.Context, req domain.SignedRequest) (*domain.AuthenticateAppResponse, error)
+}
+
+type SignedAuthenticateConfig struct {
+	Enable        bool
+	SignedHeaders []string
+}
+
+// SignedAuthenticate аутентифицирует приложение по подписи HMAC-SHA256,
+// запросы без заголовка x-request-signature аутентифицируются по токену в Authenticate
+func SignedAuthenticate(authenticator SignedAuthenticator, cfg SignedAuthenticateConfig) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx *request.Context) error {
+			if !cfg.Enable || ctx.SkipAppAuth() {
+				return next.Handle(ctx)
+			}
+			signature := strings.TrimSpace(ctx.Request().Header.Get(requestSignatureHeader))
+			if signature == "" {
+				return next.Handle(ctx)
+			}
+
+			signedRequest, err := readSignedRequest(ctx.Request(), cfg.SignedHeaders)
+			if err != nil {
+				return httperrors.New(
+					http.StatusUnauthorized,
+					"invalid request signature",
+					errors.WithMessage(err, "signed authenticate"),
+				)
+			}
+			signedRequest.Signature = strings.ToLower(signature)
+
+			resp, err := authenticator.Authenticate(ctx.Context(), *signedRequest)
+			if err != nil {
+				return errors.WithMessage(err, "signed authenticate: authenticator error")
+			}
+			if !resp.Authenticated {
+				return httperrors.New(
+					http.StatusUnauthorized,
+					"invalid request signature",
+					errors.Errorf("signed authenticate: %s", resp.ErrorReason),
+				)
+			}
+
+			ctx.Authenticate(*resp.AuthData)
+			return next.Handle(ctx)
+		})
+	}
+}
+
+// readSignedRequest собирает строку для подписи:
+// метод,путь,query,timestamp,nonce,подписываемые заголовки в виде 'name:value' и sha256 тела в hex,разделенные '\n'
+func readSignedRequest(req *http.Request, signedHeaders []string) (*domain.SignedRequest, error) {
+	applicationId, err := strconv.Atoi(strings.TrimSpace(req.Header.Get(applicationIdHeader)))
+	if err != nil {
+		return nil, errors.Errorf("invalid header %s", applicationIdHeader)
+	}
+	timestamp, err := strconv.ParseInt(strings.TrimSpace(req.Header.Get(requestTimestampHeader)), 10, 64)
+	if err != nil {
+		return nil, errors.Errorf("invalid header %s", requestTimestampHeader)
+	}
+	nonce := strings.TrimSpace(req.Header.Get(requestNonceHeader))
+	if nonce == "" {
+		return nil, errors.Errorf("header %s required", requestNonceHeader)
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, errors.WithMessage(err, "read request body")
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	bodyHash := sha256.Sum256(body)
+
+	parts := []string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		strconv.FormatInt(timestamp, 10),
+		nonce,
+	}
+	for _, name := range signedHeaders {
+		parts = append(parts, strings.ToLower(name)+":"+strings.TrimSpace(req.Header.Get(name)))
+	}
+	parts = append(parts, hex.EncodeToString(bodyHash[:]))
+
+	return &domain.SignedRequest{
+		ApplicationId: applicationId,
+		Timestamp:     time.Unix(timestamp, 0),
+		Nonce:         nonce,
+		StringToSign:  strings.Join(parts, "\n"),
+	}, nil
+}
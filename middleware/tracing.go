@@ -0,0 +1,186 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+
+	"isp-gate-service/request"
+
+	"github.com/txix-open/isp-kit/log"
+	"github.com/txix-open/isp-kit/observability/tracing"
+	"github.com/txix-open/isp-kit/observability/tracing/http/semconvutil"
+	"github.com/txix-open/isp-kit/requestid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracerName = "isp-gate-service"
+
+	TraceIdLogKey = "traceId"
+
+	locationAttribute      = attribute.Key("gate.location")
+	stageAttribute         = attribute.Key("gate.stage")
+	targetModuleAttribute  = attribute.Key("gate.target_module")
+	applicationIdAttribute = attribute.Key("gate.application_id")
+	adminIdAttribute       = attribute.Key("gate.admin_id")
+)
+
+type TracingConfig struct {
+	Provider   tracing.TracerProvider
+	Propagator tracing.Propagator
+}
+
+// NewTracingConfig провайдер трассировки настраивается isp-kit по локальной конфигурации observability.tracing
+func NewTracingConfig() TracingConfig {
+	return TracingConfig{
+		Provider:   tracing.DefaultProvider,
+		Propagator: tracing.DefaultPropagator,
+	}
+}
+
+func (c TracingConfig) enabled() bool {
+	return !tracing.IsNoop(c.Provider)
+}
+
+// Tracing создает серверный span запроса и добавляет trace id в поля логов
+func Tracing(cfg TracingConfig, location string) Middleware {
+	if !cfg.enabled() {
+		return func(next Handler) Handler {
+			return next
+		}
+	}
+
+	tracer := cfg.Provider.Tracer(tracerName)
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx *request.Context) error {
+			r := ctx.Request()
+			endpointMeta := ctx.EndpointMeta()
+			spanCtx := cfg.Propagator.Extract(ctx.Context(), propagation.HeaderCarrier(r.Header))
+
+			attributes := semconvutil.HTTPServerRequest("", r)
+			attributes = append(attributes, locationAttribute.String(location))
+			spanName := fmt.Sprintf("%s %s", r.Method, r.URL.Path)
+			if endpointMeta.PathSchema != "" {
+				attributes = append(attributes, semconv.HTTPRouteKey.String(endpointMeta.PathSchema))
+				spanName = fmt.Sprintf("%s %s", r.Method, endpointMeta.PathSchema)
+			}
+			spanCtx, span := tracer.Start(
+				spanCtx,
+				spanName,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(attributes...),
+			)
+			defer span.End()
+
+			spanCtx = log.ToContext(spanCtx, log.String(TraceIdLogKey, span.SpanContext().TraceID().String()))
+			ctx.SetContext(spanCtx)
+
+			statusWriter := &writerWrapper{ResponseWriter: ctx.ResponseWriter()}
+			ctx.SetResponseWriter(statusWriter)
+
+			err := next.Handle(ctx)
+
+			authData, _ := ctx.GetAuthData()
+			span.SetAttributes(
+				// request id может быть заменен идентификатором клиента в ClientRequestId
+				tracing.RequestId.String(requestid.FromContext(ctx.Context())),
+				semconv.HTTPStatusCode(statusWriter.StatusCode()),
+				applicationIdAttribute.Int(authData.ApplicationId),
+				adminIdAttribute.Int(ctx.AdminId()),
+			)
+			span.SetStatus(semconvutil.HTTPServerStatus(statusWriter.StatusCode()))
+
+			return err
+		})
+	}
+}
+
+type stageSpanKey struct{}
+
+type stageSpan struct {
+	parent trace.Span
+	passed bool
+}
+
+// TraceStage создает дочерний span этапа обработки запроса,span завершается при передаче запроса
+// следующему этапу,поэтому не включает время последующих этапов
+func TraceStage(cfg TracingConfig, stage string, middleware Middleware) Middleware {
+	if !cfg.enabled() {
+		return middleware
+	}
+
+	tracer := cfg.Provider.Tracer(tracerName)
+	return func(next Handler) Handler {
+		handler := middleware(HandlerFunc(func(ctx *request.Context) error {
+			state, _ := ctx.Context().Value(stageSpanKey{}).(*stageSpan)
+			trace.SpanFromContext(ctx.Context()).End()
+			state.passed = true
+			ctx.SetContext(trace.ContextWithSpan(ctx.Context(), state.parent))
+			return next.Handle(ctx)
+		}))
+
+		return HandlerFunc(func(ctx *request.Context) error {
+			state := &stageSpan{parent: trace.SpanFromContext(ctx.Context())}
+			spanCtx, span := tracer.Start(
+				context.WithValue(ctx.Context(), stageSpanKey{}, state),
+				stage,
+				trace.WithAttributes(stageAttribute.String(stage)),
+			)
+			ctx.SetContext(spanCtx)
+
+			err := handler.Handle(ctx)
+			if state.passed {
+				return err
+			}
+
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+			ctx.SetContext(trace.ContextWithSpan(ctx.Context(), state.parent))
+			return err
+		})
+	}
+}
+
+// TraceUpstream создает клиентский span вызова upstream,контекст span передается в upstream прокси
+func TraceUpstream(cfg TracingConfig, targetModule string) Middleware {
+	if !cfg.enabled() {
+		return func(next Handler) Handler {
+			return next
+		}
+	}
+
+	tracer := cfg.Provider.Tracer(tracerName)
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx *request.Context) error {
+			parentContext := ctx.Context()
+			spanCtx, span := tracer.Start(
+				parentContext,
+				fmt.Sprintf("proxy %s", targetModule),
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(targetModuleAttribute.String(targetModule)),
+			)
+			defer span.End()
+			ctx.SetContext(spanCtx)
+
+			err := next.Handle(ctx)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			statusSource, ok := ctx.ResponseWriter().(scSource)
+			if ok {
+				span.SetAttributes(semconv.HTTPStatusCode(statusSource.StatusCode()))
+			}
+
+			ctx.SetContext(trace.ContextWithSpan(ctx.Context(), trace.SpanFromContext(parentContext)))
+			return err
+		})
+	}
+}
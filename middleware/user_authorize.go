@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"isp-gate-service/domain"
+	"isp-gate-service/httperrors"
+	"isp-gate-service/request"
+
+	"github.com/pkg/errors"
+)
+
+func UserAuthorize() Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx *request.Context) error {
+			endpointMeta := ctx.EndpointMeta()
+			if len(endpointMeta.RequiredUserPermissions) == 0 && len(endpointMeta.RequiredUserRoles) == 0 {
+				return next.Handle(ctx)
+			}
+
+			userAuthData, err := ctx.GetUserAuthData()
+			if err != nil {
+				ctx.AddAuditEvent(domain.AuditUserAuthorizationDeniedEvent)
+				return httperrors.New(
+					http.StatusForbidden,
+					"user authentication required",
+					errors.Errorf("user authorization: user authentication required for '%s'", endpointMeta.Endpoint),
+				)
+			}
+
+			for _, permission := range endpointMeta.RequiredUserPermissions {
+				if !slices.Contains(userAuthData.Permissions, permission) {
+					ctx.AddAuditEvent(domain.AuditUserAuthorizationDeniedEvent)
+					return httperrors.New(
+						http.StatusForbidden,
+						"endpoint is not allowed",
+						errors.Errorf(
+							"user authorization: endpoint '%s' requires '%s' permission, but user '%s' doesn't have it",
+							endpointMeta.Endpoint, permission, userAuthData.Identity,
+						),
+					)
+				}
+			}
+
+			if len(endpointMeta.RequiredUserRoles) > 0 &&
+				!slices.ContainsFunc(endpointMeta.RequiredUserRoles, func(role string) bool {
+					return slices.Contains(userAuthData.Roles, role)
+				}) {
+				ctx.AddAuditEvent(domain.AuditUserAuthorizationDeniedEvent)
+				return httperrors.New(
+					http.StatusForbidden,
+					"endpoint is not allowed",
+					errors.Errorf(
+						"user authorization: endpoint '%s' requires one of roles %v, but user '%s' has none of them",
+						endpointMeta.Endpoint, endpointMeta.RequiredUserRoles, userAuthData.Identity,
+					),
+				)
+			}
+
+			return next.Handle(ctx)
+		})
+	}
+}
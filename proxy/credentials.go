@@ -0,0 +1,66 @@
+package proxy
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"isp-gate-service/request"
+)
+
+// stripCredentials удаляет из запроса в upstream источники токенов,использованных при аутентификации,
+// для каждого типа токена удаляются источники всех настроенных провайдеров
+func stripCredentials(ctx *request.Context, req *http.Request, header http.Header) {
+	credentials := ctx.ConsumedCredentials()
+	if len(credentials) == 0 {
+		return
+	}
+
+	removeQuery := make(map[string]bool)
+	removeCookies := make(map[string]bool)
+	for _, credential := range credentials {
+		switch credential.Source {
+		case request.HeaderCredentialSource:
+			header.Del(credential.Name)
+		case request.QueryCredentialSource:
+			removeQuery[strings.ToLower(credential.Name)] = true
+		case request.CookieCredentialSource:
+			removeCookies[credential.Name] = true
+		}
+	}
+
+	if len(removeQuery) > 0 {
+		req.URL.RawQuery = removeQueryParams(req.URL.RawQuery, removeQuery)
+	}
+
+	if len(removeCookies) > 0 {
+		cookies := make([]string, 0)
+		for _, cookie := range req.Cookies() {
+			if !removeCookies[cookie.Name] {
+				cookies = append(cookies, cookie.String())
+			}
+		}
+		header.Del("Cookie")
+		if len(cookies) > 0 {
+			header.Set("Cookie", strings.Join(cookies, "; "))
+		}
+	}
+}
+
+// removeQueryParams удаляет параметры из исходной строки запроса,
+// порядок и экранирование остальных параметров сохраняются
+func removeQueryParams(rawQuery string, remove map[string]bool) string {
+	params := strings.Split(rawQuery, "&")
+	kept := params[:0]
+	for _, param := range params {
+		key, _, _ := strings.Cut(param, "=")
+		unescaped, err := url.QueryUnescape(key)
+		if err == nil {
+			key = unescaped
+		}
+		if !remove[strings.ToLower(key)] {
+			kept = append(kept, param)
+		}
+	}
+	return strings.Join(kept, "&")
+}
@@ -0,0 +1,91 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+
+	"isp-gate-service/clientip"
+	"isp-gate-service/request"
+
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	xForwardedForHeader   = "X-Forwarded-For"
+	xForwardedProtoHeader = "X-Forwarded-Proto"
+	xForwardedHostHeader  = "X-Forwarded-Host"
+	xRealIpHeader         = "X-Real-Ip"
+	forwardedHeader       = "Forwarded"
+)
+
+type forwarding struct {
+	forwardedFor      string
+	priorForwardedFor string
+	proto             string
+	host              string
+	forwarded         string
+	clientIp          string
+}
+
+// newForwarding формирует значения заголовков проксирования,
+// заголовки входящего запроса сохраняются только если он пришёл от доверенного прокси
+func newForwarding(ctx *request.Context) forwarding {
+	req := ctx.Request()
+	peer := clientip.PeerIp(req)
+	hopProto := "http"
+	if req.TLS != nil {
+		hopProto = "https"
+	}
+
+	result := forwarding{
+		forwardedFor: peer,
+		proto:        hopProto,
+		host:         req.Host,
+		forwarded:    "for=" + clientip.FormatForwardedNode(peer) + ";host=" + quoteForwardedValue(req.Host) + ";proto=" + hopProto,
+		clientIp:     ctx.ClientIp(),
+	}
+	if !ctx.IsTrustedPeer() {
+		return result
+	}
+
+	prior := strings.Join(req.Header.Values(xForwardedForHeader), ", ")
+	if prior != "" {
+		result.priorForwardedFor = prior
+		result.forwardedFor = prior + ", " + peer
+	}
+	if proto := req.Header.Get(xForwardedProtoHeader); proto != "" {
+		result.proto = proto
+	}
+	if host := req.Header.Get(xForwardedHostHeader); host != "" {
+		result.host = host
+	}
+	if forwarded := strings.Join(req.Header.Values(forwardedHeader), ", "); forwarded != "" {
+		result.forwarded = forwarded + ", " + result.forwarded
+	}
+	return result
+}
+
+func (f forwarding) writeHeaders(header http.Header, withForwardedFor bool) {
+	if withForwardedFor {
+		header.Set(xForwardedForHeader, f.forwardedFor)
+	}
+	header.Set(xForwardedProtoHeader, f.proto)
+	header.Set(xForwardedHostHeader, f.host)
+	header.Set(forwardedHeader, f.forwarded)
+	header.Set(xRealIpHeader, f.clientIp)
+}
+
+func (f forwarding) writeMetadata(md metadata.MD) {
+	md.Set(strings.ToLower(xForwardedForHeader), f.forwardedFor)
+	md.Set(strings.ToLower(xForwardedProtoHeader), f.proto)
+	md.Set(strings.ToLower(xForwardedHostHeader), f.host)
+	md.Set(strings.ToLower(forwardedHeader), f.forwarded)
+	md.Set(strings.ToLower(xRealIpHeader), f.clientIp)
+}
+
+func quoteForwardedValue(value string) string {
+	if strings.ContainsAny(value, ":[]") {
+		return `"` + value + `"`
+	}
+	return value
+}
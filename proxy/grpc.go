@@ -19,8 +19,11 @@ import (
 	"github.com/txix-open/isp-kit/grpc/client"
 	"github.com/txix-open/isp-kit/grpc/isp"
 	"github.com/txix-open/isp-kit/json"
+	"github.com/txix-open/isp-kit/observability/tracing"
+	tracinggrpc "github.com/txix-open/isp-kit/observability/tracing/grpc"
 	"github.com/txix-open/isp-kit/requestid"
 	_ "google.golang.org/genproto/googleapis/rpc/errdetails"
+	grpc2 "google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
@@ -32,6 +35,12 @@ func init() {
 	}
 }
 
+const (
+	httpMetadataPrefix     = "x-http-"
+	httpStatusMetadataKey  = "x-http-status"
+	defaultGrpcContentType = "application/json; charset=utf-8"
+)
+
 var (
 	codeMap = map[int]codes.Code{
 		http.StatusOK:                  codes.OK,
@@ -49,45 +58,93 @@ var (
 		http.StatusServiceUnavailable:  codes.Unavailable,
 	}
 	inverseCodeMap = map[codes.Code]int{}
+
+	// заголовки, которые формируются самим http сервером и не могут быть переопределены grpc сервисом
+	forbiddenResponseHeaders = map[string]bool{
+		"Connection":        true,
+		"Content-Length":    true,
+		"Keep-Alive":        true,
+		"Trailer":           true,
+		"Transfer-Encoding": true,
+		"Upgrade":           true,
+	}
 )
 
 type Grpc struct {
-	cli      *client.Client
-	skipAuth bool
-	timeout  time.Duration
+	cli                      *client.Client
+	skipAuth                 bool
+	timeout                  time.Duration
+	responseHeadersAllowlist map[string]bool
+	statusOverrides          map[codes.Code]int
+	problemJson              bool
+	headerRules              HeaderRules
 }
 
-func NewGrpc(cli *client.Client, skipAuth bool, timeout time.Duration) Grpc {
+func NewGrpc(
+	cli *client.Client,
+	skipAuth bool,
+	timeout time.Duration,
+	responseHeadersAllowlist []string,
+	statusOverrides map[codes.Code]int,
+	problemJson bool,
+	headerRules HeaderRules,
+) Grpc {
+	allowlist := make(map[string]bool, len(responseHeadersAllowlist))
+	for _, header := range responseHeadersAllowlist {
+		allowlist[http.CanonicalHeaderKey(strings.TrimSpace(header))] = true
+	}
 	return Grpc{
-		cli:      cli,
-		skipAuth: skipAuth,
-		timeout:  timeout,
+		cli:                      cli,
+		skipAuth:                 skipAuth,
+		timeout:                  timeout,
+		responseHeadersAllowlist: allowlist,
+		statusOverrides:          statusOverrides,
+		problemJson:              problemJson,
+		headerRules:              headerRules,
 	}
 }
 
+func ParseGrpcCode(name string) (codes.Code, error) {
+	for code := codes.OK; code <= codes.Unauthenticated; code++ {
+		if code.String() == name {
+			return code, nil
+		}
+	}
+	return codes.Unknown, errors.Errorf("unknown grpc code '%s'", name)
+}
+
 func (p Grpc) Handle(ctx *request.Context) error {
 	body, err := io.ReadAll(ctx.Request().Body)
 	if err != nil {
 		return errors.WithMessage(err, "grpc: read body")
 	}
 
-	requestId := requestid.FromContext(ctx.Context())
 	md := p.writeMetadata(ctx)
+	p.headerRules.ApplyToMetadata(ctx, md)
 	requestContext := metadata.NewOutgoingContext(ctx.Context(), md)
 
 	requestContext, cancel := context.WithTimeout(requestContext, p.timeout)
 	defer cancel()
-	result, err := p.cli.BackendClient().Request(requestContext, &isp.Message{
-		Body: &isp.Message_BytesBody{BytesBody: body},
-	})
+	var header, trailer metadata.MD
+	result, err := p.cli.BackendClient().Request(
+		requestContext,
+		&isp.Message{Body: &isp.Message_BytesBody{BytesBody: body}},
+		grpc2.Header(&header),
+		grpc2.Trailer(&trailer),
+	)
+	responseMd := metadata.Join(header, trailer)
 	if err != nil {
-		return p.handleError(err, ctx.ResponseWriter(), ctx.EndpointMeta().Endpoint)
+		return p.handleError(ctx, err, responseMd)
 	}
 
-	return p.writeResponse(http.StatusOK, result.GetBytesBody(), requestId, ctx.ResponseWriter())
+	statusCode := p.applyResponseMetadata(responseMd, http.StatusOK, ctx.ResponseWriter())
+	p.headerRules.ApplyToResponse(ctx, ctx.ResponseWriter().Header())
+	return p.writeResponse(statusCode, result.GetBytesBody(), ctx.ResponseWriter())
 }
 
-func (p Grpc) handleError(err error, w http.ResponseWriter, endpoint string) error {
+func (p Grpc) handleError(ctx *request.Context, err error, responseMd metadata.MD) error {
+	w := ctx.ResponseWriter()
+	endpoint := ctx.EndpointMeta().Endpoint
 	status, ok := status.FromError(err)
 	if !ok {
 		return httperrors.New(
@@ -97,13 +154,33 @@ func (p Grpc) handleError(err error, w http.ResponseWriter, endpoint string) err
 		)
 	}
 
-	statusCode := p.codeToHttpStatus(status.Code())
+	statusCode := p.applyResponseMetadata(responseMd, p.codeToHttpStatus(status.Code()), w)
+	p.headerRules.ApplyToResponse(ctx, w.Header())
+	if p.problemJson {
+		httpErr := httperrors.New(
+			statusCode,
+			status.Message(),
+			errors.WithMessagef(err, "isp-routing-service: proxy '%s'", endpoint),
+		)
+		details := p.problemDetails(status.Details())
+		if len(details) == 0 {
+			return httpErr
+		}
+		// ошибка с деталями от upstream,как и без problem+json,пишется в ответ без логирования ошибки шлюза
+		httpErr.WithDetails(details...)
+		err = httpErr.WriteProblem(w, requestid.FromContext(ctx.Context()))
+		if err != nil {
+			return errors.WithMessage(err, "write problem")
+		}
+		return nil
+	}
+
 	for _, detail := range status.Details() {
 		switch typeOfDetail := detail.(type) {
 		case *isp.Message:
 			switch {
 			case typeOfDetail.GetBytesBody() != nil:
-				return p.writeResponse(statusCode, typeOfDetail.GetBytesBody(), "", w)
+				return p.writeResponse(statusCode, typeOfDetail.GetBytesBody(), w)
 			case typeOfDetail.GetListBody() != nil:
 				return p.writeProto(statusCode, typeOfDetail.GetListBody(), w)
 			case typeOfDetail.GetStructBody() != nil:
@@ -126,13 +203,12 @@ func (p Grpc) writeProto(statusCode int, proto any, w http.ResponseWriter) error
 	if err != nil {
 		return errors.WithMessage(err, "marshal grpc details to json")
 	}
-	return p.writeResponse(statusCode, data, "", w)
+	return p.writeResponse(statusCode, data, w)
 }
 
-func (p Grpc) writeResponse(statusCode int, data []byte, requestId string, w http.ResponseWriter) error {
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	if requestId != "" {
-		w.Header().Set(requestid.Header, requestId)
+func (p Grpc) writeResponse(statusCode int, data []byte, w http.ResponseWriter) error {
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", defaultGrpcContentType)
 	}
 	w.WriteHeader(statusCode)
 	_, err := w.Write(data)
@@ -142,8 +218,77 @@ func (p Grpc) writeResponse(statusCode int, data []byte, requestId string, w htt
 	return nil
 }
 
+// applyResponseMetadata переносит метаданные ответа с префиксом x-http- в заголовки HTTP ответа,
+// возвращает статус ответа с учетом переопределения через x-http-status
+func (p Grpc) applyResponseMetadata(md metadata.MD, statusCode int, w http.ResponseWriter) int {
+	for key, values := range md {
+		key = strings.ToLower(key)
+		if !strings.HasPrefix(key, httpMetadataPrefix) || len(values) == 0 {
+			continue
+		}
+
+		if key == httpStatusMetadataKey {
+			overridden, err := strconv.Atoi(values[len(values)-1])
+			if err == nil && overridden >= 100 && overridden <= 599 {
+				statusCode = overridden
+			}
+			continue
+		}
+
+		header := http.CanonicalHeaderKey(strings.TrimPrefix(key, httpMetadataPrefix))
+		if !p.isResponseHeaderAllowed(header) {
+			continue
+		}
+		w.Header().Del(header)
+		for _, value := range values {
+			w.Header().Add(header, value)
+		}
+	}
+	return statusCode
+}
+
+// isResponseHeaderAllowed заголовки,отсутствующие в списке разрешенных,не устанавливаются,
+// при пустом списке grpc сервис не может установить ни один заголовок
+func (p Grpc) isResponseHeaderAllowed(header string) bool {
+	if forbiddenResponseHeaders[header] {
+		return false
+	}
+	return p.responseHeadersAllowlist[header]
+}
+
+func (p Grpc) problemDetails(details []any) []any {
+	result := make([]any, 0, len(details))
+	for _, detail := range details {
+		message, ok := detail.(*isp.Message)
+		if !ok {
+			result = append(result, detail)
+			continue
+		}
+		switch {
+		case message.GetBytesBody() != nil:
+			body := json.RawMessage{}
+			err := json.Unmarshal(message.GetBytesBody(), &body)
+			if err == nil {
+				result = append(result, body)
+			} else {
+				result = append(result, string(message.GetBytesBody()))
+			}
+		case message.GetListBody() != nil:
+			result = append(result, message.GetListBody())
+		case message.GetStructBody() != nil:
+			result = append(result, message.GetStructBody())
+		}
+	}
+	return result
+}
+
 func (p Grpc) codeToHttpStatus(code codes.Code) int {
-	s, ok := inverseCodeMap[code]
+	s, ok := p.statusOverrides[code]
+	if ok {
+		return s
+	}
+
+	s, ok = inverseCodeMap[code]
 	if !ok {
 		return http.StatusInternalServerError
 	}
@@ -157,11 +302,18 @@ func (p Grpc) writeMetadata(ctx *request.Context) metadata.MD {
 		grpc.ProxyMethodNameHeader: {ctx.EndpointMeta().Endpoint},
 		requestid.Header:           {requestId},
 	}
+	newForwarding(ctx).writeMetadata(md)
+	tracing.DefaultPropagator.Inject(ctx.Context(), tracinggrpc.MetadataCarrier(md))
 
 	if p.skipAuth {
 		return md
 	}
 
+	internalTokenHeader, internalToken := ctx.InternalToken()
+	if internalToken != "" {
+		md.Set(internalTokenHeader, internalToken)
+	}
+
 	appAuthData, err := ctx.GetAuthData()
 	if err == nil {
 		md[grpc.SystemIdHeader] = []string{strconv.Itoa(appAuthData.SystemId)}
@@ -174,7 +326,8 @@ func (p Grpc) writeMetadata(ctx *request.Context) metadata.MD {
 
 	userAuthData, err := ctx.GetUserAuthData()
 	if err == nil {
-		proxyHeaders := maps.Clone(userAuthData.ExtraHeaders)
+		proxyHeaders := make(map[string][]string, len(userAuthData.ExtraHeaders)+1)
+		maps.Copy(proxyHeaders, userAuthData.ExtraHeaders)
 		proxyHeaders[userAuthData.IdentityHeader] = []string{userAuthData.Identity}
 		for header, values := range proxyHeaders {
 			header = strings.ToLower(header)
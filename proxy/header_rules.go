@@ -0,0 +1,140 @@
+package proxy
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"isp-gate-service/conf"
+	"isp-gate-service/request"
+
+	"github.com/txix-open/isp-kit/requestid"
+	"google.golang.org/grpc/metadata"
+)
+
+type headerRule struct {
+	action   string
+	name     string
+	isPrefix bool
+	newName  string
+	value    string
+}
+
+type HeaderRules struct {
+	request  []headerRule
+	response []headerRule
+}
+
+func NewHeaderRules(requestRules []conf.HeaderRule, responseRules []conf.HeaderRule) HeaderRules {
+	return HeaderRules{
+		request:  compileHeaderRules(requestRules),
+		response: compileHeaderRules(responseRules),
+	}
+}
+
+func (r HeaderRules) ApplyToRequest(ctx *request.Context, header http.Header) {
+	applyHeaderRules(ctx, r.request, header)
+}
+
+func (r HeaderRules) ApplyToResponse(ctx *request.Context, header http.Header) {
+	applyHeaderRules(ctx, r.response, header)
+}
+
+func (r HeaderRules) ApplyToMetadata(ctx *request.Context, md metadata.MD) {
+	if len(r.request) == 0 {
+		return
+	}
+
+	header := make(http.Header, len(md))
+	for key, values := range md {
+		header[http.CanonicalHeaderKey(key)] = values
+	}
+	applyHeaderRules(ctx, r.request, header)
+
+	for key := range md {
+		delete(md, key)
+	}
+	for key, values := range header {
+		md[strings.ToLower(key)] = values
+	}
+}
+
+func compileHeaderRules(rules []conf.HeaderRule) []headerRule {
+	result := make([]headerRule, 0, len(rules))
+	for _, rule := range rules {
+		name := strings.TrimSpace(rule.Name)
+		isPrefix := strings.HasSuffix(name, "*")
+		result = append(result, headerRule{
+			action:   rule.Action,
+			name:     http.CanonicalHeaderKey(strings.TrimSuffix(name, "*")),
+			isPrefix: isPrefix,
+			newName:  http.CanonicalHeaderKey(strings.TrimSpace(rule.NewName)),
+			value:    rule.Value,
+		})
+	}
+	return result
+}
+
+func applyHeaderRules(ctx *request.Context, rules []headerRule, header http.Header) {
+	if len(rules) == 0 {
+		return
+	}
+
+	var replacer *strings.Replacer
+	for _, rule := range rules {
+		switch rule.action {
+		case conf.SetHeaderAction, conf.AddHeaderAction:
+			value := rule.value
+			if strings.Contains(value, "{") {
+				if replacer == nil {
+					replacer = templateReplacer(ctx)
+				}
+				value = replacer.Replace(value)
+			}
+			if rule.action == conf.SetHeaderAction {
+				header.Set(rule.name, value)
+			} else {
+				header.Add(rule.name, value)
+			}
+		case conf.RemoveHeaderAction:
+			if !rule.isPrefix {
+				header.Del(rule.name)
+				continue
+			}
+			for key := range header {
+				if strings.HasPrefix(key, rule.name) {
+					delete(header, key)
+				}
+			}
+		case conf.RenameHeaderAction:
+			values := header.Values(rule.name)
+			if len(values) == 0 {
+				continue
+			}
+			header.Del(rule.name)
+			header[rule.newName] = values
+		}
+	}
+}
+
+func templateReplacer(ctx *request.Context) *strings.Replacer {
+	appAuthData, _ := ctx.GetAuthData()
+	userAuthData, _ := ctx.GetUserAuthData()
+	adminId := ""
+	if ctx.IsAdminAuthenticated() {
+		adminId = strconv.Itoa(ctx.AdminId())
+	}
+	applicationId := ""
+	if appAuthData.ApplicationId != 0 {
+		applicationId = strconv.Itoa(appAuthData.ApplicationId)
+	}
+
+	return strings.NewReplacer(
+		"{requestId}", requestid.FromContext(ctx.Context()),
+		"{applicationId}", applicationId,
+		"{applicationName}", appAuthData.AppName,
+		"{userIdentity}", userAuthData.Identity,
+		"{adminId}", adminId,
+		"{clientIp}", ctx.ClientIp(),
+	)
+}
@@ -15,7 +15,9 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/txix-open/isp-kit/grpc"
+	"github.com/txix-open/isp-kit/observability/tracing"
 	"github.com/txix-open/isp-kit/requestid"
+	"go.opentelemetry.io/otel/propagation"
 	"golang.org/x/net/context"
 )
 
@@ -40,16 +42,26 @@ type HttpHostManager interface {
 }
 
 type Http struct {
-	hostManager HttpHostManager
-	skipAuth    bool
-	timeout     time.Duration
+	hostManager        HttpHostManager
+	skipAuth           bool
+	timeout            time.Duration
+	headerRules        HeaderRules
+	forwardCredentials bool
 }
 
-func NewHttp(hostManager HttpHostManager, skipAuth bool, timeout time.Duration) Http {
+func NewHttp(
+	hostManager HttpHostManager,
+	skipAuth bool,
+	timeout time.Duration,
+	headerRules HeaderRules,
+	forwardCredentials bool,
+) Http {
 	return Http{
-		hostManager: hostManager,
-		skipAuth:    skipAuth,
-		timeout:     timeout,
+		hostManager:        hostManager,
+		skipAuth:           skipAuth,
+		timeout:            timeout,
+		headerRules:        headerRules,
+		forwardCredentials: forwardCredentials,
 	}
 }
 
@@ -67,10 +79,26 @@ func (p Http) Handle(ctx *request.Context) error {
 
 	request := ctx.Request()
 	request.URL.Path = ctx.EndpointMeta().Endpoint
+	if !p.forwardCredentials {
+		stripCredentials(ctx, request, request.Header)
+	}
 	setHttpHeaders(ctx, request.Header, p.skipAuth)
+	forwarding := newForwarding(ctx)
+	forwarding.writeHeaders(request.Header, false)
+	// адрес отправителя добавляется в X-Forwarded-For в httputil.ReverseProxy
+	if forwarding.priorForwardedFor != "" {
+		request.Header.Set(xForwardedForHeader, forwarding.priorForwardedFor)
+	} else {
+		request.Header.Del(xForwardedForHeader)
+	}
+	p.headerRules.ApplyToRequest(ctx, request.Header)
 
 	reverseProxy := httputil.NewSingleHostReverseProxy(target)
 	reverseProxy.Transport = httpTransport
+	reverseProxy.ModifyResponse = func(resp *http.Response) error {
+		p.headerRules.ApplyToResponse(ctx, resp.Header)
+		return nil
+	}
 	var resultError error
 	reverseProxy.ErrorHandler = func(writer http.ResponseWriter, request *http.Request, err error) {
 		resultError = httperrors.New(
@@ -90,10 +118,16 @@ func (p Http) Handle(ctx *request.Context) error {
 
 func setHttpHeaders(ctx *request.Context, header http.Header, skipAuth bool) {
 	header.Set(requestid.Header, requestid.FromContext(ctx.Context()))
+	tracing.DefaultPropagator.Inject(ctx.Context(), propagation.HeaderCarrier(header))
 	if skipAuth {
 		return
 	}
 
+	internalTokenHeader, internalToken := ctx.InternalToken()
+	if internalToken != "" {
+		header.Set(internalTokenHeader, internalToken)
+	}
+
 	userAuthData, err := ctx.GetUserAuthData()
 	if err == nil {
 		for key, values := range userAuthData.ExtraHeaders {
@@ -2,26 +2,31 @@ package proxy
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/pkg/errors"
-	"github.com/tomakado/websocketproxy"
+	"github.com/txix-open/isp-kit/requestid"
 	"isp-gate-service/httperrors"
 	"isp-gate-service/request"
 )
 
 type Ws struct {
-	hostManager HttpHostManager
-	skipAuth    bool
+	hostManager        HttpHostManager
+	skipAuth           bool
+	headerRules        HeaderRules
+	forwardCredentials bool
 }
 
-func NewWs(hostManager HttpHostManager, skipAuth bool) Ws {
+func NewWs(hostManager HttpHostManager, skipAuth bool, headerRules HeaderRules, forwardCredentials bool) Ws {
 	return Ws{
-		hostManager: hostManager,
-		skipAuth:    skipAuth,
+		hostManager:        hostManager,
+		skipAuth:           skipAuth,
+		headerRules:        headerRules,
+		forwardCredentials: forwardCredentials,
 	}
 }
 
@@ -31,21 +36,34 @@ func (ws Ws) Handle(ctx *request.Context) error {
 		return errors.WithMessage(err, "ws: next host")
 	}
 
-	rawUrl := fmt.Sprintf("ws://%s", host)
-	target, err := url.Parse(rawUrl)
-	if err != nil {
-		return errors.WithMessage(err, "ws: parse url")
-	}
-
 	request := ctx.Request()
 	request.URL.Path = ctx.EndpointMeta().Endpoint
+	if !ws.forwardCredentials {
+		stripCredentials(ctx, request, request.Header)
+	}
+	target := url.URL{
+		Scheme:   "ws",
+		Host:     host,
+		Path:     request.URL.Path,
+		RawQuery: request.URL.RawQuery,
+	}
 
-	var resultError error
-	proxy := websocketproxy.NewProxy(target)
-	proxy.Director = func(incoming *http.Request, out http.Header) {
-		setHttpHeaders(ctx, out, ws.skipAuth)
+	connBackend, resp, err := websocket.DefaultDialer.DialContext(ctx.Context(), target.String(), ws.requestHeader(ctx))
+	if err != nil && resp != nil {
+		// ответ upstream на неудачный handshake возвращается клиенту как есть
+		return ws.copyResponse(ctx, ctx.ResponseWriter(), resp)
+	}
+	if err != nil {
+		return httperrors.New(
+			http.StatusServiceUnavailable,
+			"upstream is not available",
+			errors.WithMessagef(err, "ws proxy to %s", host),
+		)
 	}
-	proxy.Upgrader = &websocket.Upgrader{
+	defer connBackend.Close()
+
+	var resultError error
+	upgrader := &websocket.Upgrader{
 		HandshakeTimeout: 5 * time.Second,
 		ReadBufferSize:   1024,
 		WriteBufferSize:  1024,
@@ -63,8 +81,91 @@ func (ws Ws) Handle(ctx *request.Context) error {
 		},
 		EnableCompression: false,
 	}
+	connPub, err := upgrader.Upgrade(ctx.ResponseWriter(), request, ws.upgradeHeader(ctx, resp))
+	if err != nil {
+		return resultError
+	}
+	defer connPub.Close()
+
+	errs := make(chan error, 2) // nolint:mnd
+	go replicateWsConn(connPub, connBackend, errs)
+	go replicateWsConn(connBackend, connPub, errs)
+	<-errs
 
-	proxy.ServeHTTP(ctx.ResponseWriter(), ctx.Request())
+	return nil
+}
 
-	return resultError
+func (ws Ws) requestHeader(ctx *request.Context) http.Header {
+	request := ctx.Request()
+	header := http.Header{}
+	if origin := request.Header.Get("Origin"); origin != "" {
+		header.Set("Origin", origin)
+	}
+	for _, protocol := range request.Header.Values("Sec-Websocket-Protocol") {
+		header.Add("Sec-Websocket-Protocol", protocol)
+	}
+	for _, cookie := range request.Header.Values("Cookie") {
+		header.Add("Cookie", cookie)
+	}
+	if request.Host != "" {
+		header.Set("Host", request.Host)
+	}
+
+	setHttpHeaders(ctx, header, ws.skipAuth)
+	newForwarding(ctx).writeHeaders(header, true)
+	ws.headerRules.ApplyToRequest(ctx, header)
+	return header
+}
+
+// upgradeHeader заголовки ответа на upgrade,
+// gorilla/websocket записывает ответ в соединение напрямую и не использует заголовки ResponseWriter
+func (ws Ws) upgradeHeader(ctx *request.Context, resp *http.Response) http.Header {
+	header := http.Header{}
+	if protocol := resp.Header.Get("Sec-Websocket-Protocol"); protocol != "" {
+		header.Set("Sec-Websocket-Protocol", protocol)
+	}
+	if cookie := resp.Header.Get("Set-Cookie"); cookie != "" {
+		header.Set("Set-Cookie", cookie)
+	}
+	header.Set(requestid.Header, requestid.FromContext(ctx.Context()))
+	ws.headerRules.ApplyToResponse(ctx, header)
+	return header
+}
+
+func (ws Ws) copyResponse(ctx *request.Context, w http.ResponseWriter, resp *http.Response) error {
+	defer resp.Body.Close()
+
+	for key, values := range resp.Header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	ws.headerRules.ApplyToResponse(ctx, w.Header())
+	w.WriteHeader(resp.StatusCode)
+	_, err := io.Copy(w, resp.Body)
+	if err != nil {
+		return errors.WithMessage(err, "ws: copy handshake response")
+	}
+	return nil
+}
+
+func replicateWsConn(dst *websocket.Conn, src *websocket.Conn, errs chan<- error) {
+	for {
+		messageType, message, err := src.ReadMessage()
+		if err != nil {
+			closeMessage := websocket.FormatCloseMessage(websocket.CloseNormalClosure, fmt.Sprintf("%v", err))
+			closeErr := &websocket.CloseError{}
+			if errors.As(err, &closeErr) && closeErr.Code != websocket.CloseNoStatusReceived {
+				closeMessage = websocket.FormatCloseMessage(closeErr.Code, closeErr.Text)
+			}
+			errs <- err
+			_ = dst.WriteMessage(websocket.CloseMessage, closeMessage)
+			return
+		}
+		err = dst.WriteMessage(messageType, message)
+		if err != nil {
+			errs <- err
+			return
+		}
+	}
 }
@@ -0,0 +1,222 @@
+package proxyprotocol
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	v1Prefix         = "PROXY "
+	v1MaxLength      = 107
+	v2HeaderLength   = 16
+	v2CommandLocal   = 0x0
+	v2CommandProxy   = 0x1
+	v2FamilyTcp4     = 0x11
+	v2FamilyTcp6     = 0x21
+	v2AddressLenIpv4 = 12
+	v2AddressLenIpv6 = 36
+)
+
+var (
+	v2Signature = []byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A} // nolint:gochecknoglobals
+)
+
+type TrustedSources interface {
+	Contains(addr netip.Addr) bool
+}
+
+// Listener разбирает заголовок PROXY protocol v1/v2 у соединений от доверенных источников
+// и подменяет адрес отправителя на адрес из заголовка
+type Listener struct {
+	net.Listener
+
+	trusted       TrustedSources
+	headerTimeout time.Duration
+}
+
+func NewListener(listener net.Listener, trusted TrustedSources, headerTimeout time.Duration) *Listener {
+	return &Listener{
+		Listener:      listener,
+		trusted:       trusted,
+		headerTimeout: headerTimeout,
+	}
+}
+
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err // nolint:wrapcheck
+	}
+
+	addrPort, err := netip.ParseAddrPort(conn.RemoteAddr().String())
+	if err != nil || !l.trusted.Contains(addrPort.Addr()) {
+		return conn, nil
+	}
+
+	return &Conn{
+		Conn:          conn,
+		reader:        bufio.NewReader(conn),
+		headerTimeout: l.headerTimeout,
+	}, nil
+}
+
+type Conn struct {
+	net.Conn
+
+	reader        *bufio.Reader
+	headerTimeout time.Duration
+	once          sync.Once
+	remoteAddr    net.Addr
+	err           error
+}
+
+func (c *Conn) Read(b []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b) // nolint:wrapcheck
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+	return c.Conn.RemoteAddr()
+}
+
+func (c *Conn) readHeader() {
+	if c.headerTimeout > 0 {
+		_ = c.Conn.SetReadDeadline(time.Now().Add(c.headerTimeout))
+		defer func() {
+			_ = c.Conn.SetReadDeadline(time.Time{})
+		}()
+	}
+
+	addr, err := ReadHeader(c.reader)
+	if err != nil {
+		c.err = errors.WithMessage(err, "read proxy protocol header")
+		_ = c.Conn.Close()
+		return
+	}
+	c.remoteAddr = addr
+}
+
+// ReadHeader читает заголовок PROXY protocol, если он присутствует.
+// Возвращает nil, если заголовок отсутствует или передан без адреса (UNKNOWN, LOCAL)
+func ReadHeader(reader *bufio.Reader) (net.Addr, error) {
+	first, err := reader.Peek(1)
+	if err != nil {
+		return nil, nil // nolint:nilerr
+	}
+
+	switch first[0] {
+	case v1Prefix[0]:
+		prefix, err := reader.Peek(len(v1Prefix))
+		if err != nil || string(prefix) != v1Prefix {
+			return nil, nil // nolint:nilerr
+		}
+		return readV1(reader)
+	case v2Signature[0]:
+		signature, err := reader.Peek(len(v2Signature))
+		if err != nil || !bytes.Equal(signature, v2Signature) {
+			return nil, nil // nolint:nilerr
+		}
+		return readV2(reader)
+	default:
+		return nil, nil
+	}
+}
+
+func readV1(reader *bufio.Reader) (net.Addr, error) {
+	line := make([]byte, 0, v1MaxLength)
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return nil, errors.WithMessage(err, "read v1 header")
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+		if len(line) >= v1MaxLength {
+			return nil, errors.New("v1 header is too long")
+		}
+	}
+
+	fields := strings.Fields(strings.TrimSuffix(string(line), "\r\n"))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, errors.Errorf("invalid v1 header '%s'", strings.TrimSpace(string(line)))
+	}
+
+	addr, err := netip.ParseAddr(fields[2])
+	if err != nil {
+		return nil, errors.WithMessage(err, "parse v1 source address")
+	}
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, errors.WithMessage(err, "parse v1 source port")
+	}
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(addr, uint16(port))), nil
+}
+
+func readV2(reader *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, v2HeaderLength)
+	_, err := io.ReadFull(reader, header)
+	if err != nil {
+		return nil, errors.WithMessage(err, "read v2 header")
+	}
+
+	versionCommand := header[12]
+	if versionCommand>>4 != 2 {
+		return nil, errors.Errorf("unsupported v2 version %d", versionCommand>>4)
+	}
+	family := header[13]
+	length := binary.BigEndian.Uint16(header[14:16])
+	payload := make([]byte, length)
+	_, err = io.ReadFull(reader, payload)
+	if err != nil {
+		return nil, errors.WithMessage(err, "read v2 addresses")
+	}
+
+	switch versionCommand & 0x0F {
+	case v2CommandLocal:
+		return nil, nil
+	case v2CommandProxy:
+	default:
+		return nil, errors.Errorf("unsupported v2 command %d", versionCommand&0x0F)
+	}
+
+	switch family {
+	case v2FamilyTcp4:
+		if len(payload) < v2AddressLenIpv4 {
+			return nil, errors.New("v2 ipv4 addresses are too short")
+		}
+		addr := netip.AddrFrom4([4]byte(payload[0:4]))
+		port := binary.BigEndian.Uint16(payload[8:10])
+		return net.TCPAddrFromAddrPort(netip.AddrPortFrom(addr, port)), nil
+	case v2FamilyTcp6:
+		if len(payload) < v2AddressLenIpv6 {
+			return nil, errors.New("v2 ipv6 addresses are too short")
+		}
+		addr := netip.AddrFrom16([16]byte(payload[0:16]))
+		port := binary.BigEndian.Uint16(payload[32:34])
+		return net.TCPAddrFromAddrPort(netip.AddrPortFrom(addr, port)), nil
+	default:
+		return nil, nil
+	}
+}
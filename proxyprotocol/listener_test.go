@@ -0,0 +1,61 @@
+package proxyprotocol_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"isp-gate-service/proxyprotocol"
+)
+
+func TestReadHeaderV1(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	reader := bufio.NewReader(bytes.NewBufferString("PROXY TCP4 198.51.100.22 203.0.113.7 35646 80\r\nGET / HTTP/1.1\r\n"))
+	addr, err := proxyprotocol.ReadHeader(reader)
+	require.NoError(err)
+	require.EqualValues("198.51.100.22:35646", addr.String())
+
+	rest, err := reader.ReadString('\n')
+	require.NoError(err)
+	require.EqualValues("GET / HTTP/1.1\r\n", rest)
+}
+
+func TestReadHeaderV2(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	header := []byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A, 0x21, 0x11}
+	header = binary.BigEndian.AppendUint16(header, 12)
+	header = append(header, net.ParseIP("198.51.100.22").To4()...)
+	header = append(header, net.ParseIP("203.0.113.7").To4()...)
+	header = binary.BigEndian.AppendUint16(header, 35646)
+	header = binary.BigEndian.AppendUint16(header, 80)
+
+	reader := bufio.NewReader(bytes.NewBuffer(append(header, []byte("GET")...)))
+	addr, err := proxyprotocol.ReadHeader(reader)
+	require.NoError(err)
+	require.EqualValues("198.51.100.22:35646", addr.String())
+
+	rest := make([]byte, 3)
+	_, err = reader.Read(rest)
+	require.NoError(err)
+	require.EqualValues("GET", rest)
+}
+
+func TestReadHeaderAbsent(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	reader := bufio.NewReader(bytes.NewBufferString("GET / HTTP/1.1\r\n"))
+	addr, err := proxyprotocol.ReadHeader(reader)
+	require.NoError(err)
+	require.Nil(addr)
+
+	_, err = proxyprotocol.ReadHeader(bufio.NewReader(bytes.NewBufferString("PROXY TCP4 invalid\r\n")))
+	require.Error(err)
+}
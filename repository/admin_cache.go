@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"isp-gate-service/cache"
+	"isp-gate-service/domain"
+	"isp-gate-service/entity"
+
+	"github.com/pkg/errors"
+	"github.com/txix-open/isp-kit/json"
+)
+
+const (
+	adminAuthenticationKeyPrefix = "authn:"
+	adminAuthorizationKeyPrefix  = "authz:"
+)
+
+// AdminCache хранит результаты аутентификации и авторизации администраторов в общем кеше,
+// чтобы их можно было удалить при отзыве сессии администратора
+type AdminCache struct {
+	cache                 *cache.Cache
+	authorizationDuration time.Duration
+}
+
+func NewAdminCache(cache *cache.Cache, authorizationDuration time.Duration) AdminCache {
+	return AdminCache{
+		cache:                 cache,
+		authorizationDuration: authorizationDuration,
+	}
+}
+
+func (r AdminCache) GetAuthentication(ctx context.Context, token string) (*entity.AdminAuthenticateResponse, error) {
+	data, ok := r.cache.Get(adminAuthenticationKeyPrefix + token)
+	if !ok {
+		return nil, domain.ErrAuthenticationCacheMiss
+	}
+
+	result := entity.AdminAuthenticateResponse{}
+	err := json.Unmarshal(data, &result)
+	if err != nil {
+		return nil, errors.WithMessage(err, "json unmarshal admin auth data")
+	}
+
+	return &result, nil
+}
+
+func (r AdminCache) SetAuthentication(
+	ctx context.Context,
+	token string,
+	data entity.AdminAuthenticateResponse,
+	duration time.Duration,
+) error {
+	value, err := json.Marshal(data)
+	if err != nil {
+		return errors.WithMessage(err, "json marshal admin auth data")
+	}
+
+	r.cache.Set(adminAuthenticationKeyPrefix+token, value, duration)
+
+	return nil
+}
+
+func (r AdminCache) Get(ctx context.Context, adminId int, permission string) (bool, error) {
+	_, ok := r.cache.Get(r.authorizationKey(adminId, permission))
+	return ok, nil
+}
+
+func (r AdminCache) SetAuthorized(ctx context.Context, adminId int, permission string) error {
+	r.cache.Set(r.authorizationKey(adminId, permission), nil, r.authorizationDuration)
+	return nil
+}
+
+func (r AdminCache) DeleteByToken(ctx context.Context, token string) {
+	r.cache.Delete(adminAuthenticationKeyPrefix + token)
+}
+
+func (r AdminCache) DeleteByAdminId(ctx context.Context, adminId int) {
+	authorizationPrefix := fmt.Sprintf("%s%d:", adminAuthorizationKeyPrefix, adminId)
+	r.cache.DeleteFunc(func(key string, data []byte) bool {
+		if strings.HasPrefix(key, authorizationPrefix) {
+			return true
+		}
+		if !strings.HasPrefix(key, adminAuthenticationKeyPrefix) {
+			return false
+		}
+		authData := entity.AdminAuthenticateResponse{}
+		err := json.Unmarshal(data, &authData)
+		return err == nil && authData.AdminId == adminId
+	})
+}
+
+func (r AdminCache) Clear(ctx context.Context) {
+	r.cache.Clear()
+}
+
+func (r AdminCache) authorizationKey(adminId int, permission string) string {
+	return fmt.Sprintf("%s%d:%s", adminAuthorizationKeyPrefix, adminId, permission)
+}
@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"context"
+	"sync"
+
+	"isp-gate-service/domain"
+)
+
+type ApplicationPermissionsCache struct {
+	lock        *sync.RWMutex
+	permissions map[int]domain.ApplicationPermissions
+}
+
+func NewApplicationPermissionsCache() ApplicationPermissionsCache {
+	return ApplicationPermissionsCache{
+		lock:        &sync.RWMutex{},
+		permissions: make(map[int]domain.ApplicationPermissions),
+	}
+}
+
+func (r ApplicationPermissionsCache) Get(ctx context.Context, applicationId int) (domain.ApplicationPermissions, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	permissions, ok := r.permissions[applicationId]
+	return permissions, ok
+}
+
+func (r ApplicationPermissionsCache) Set(ctx context.Context, applicationId int, permissions domain.ApplicationPermissions) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.permissions[applicationId] = permissions
+}
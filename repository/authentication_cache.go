@@ -17,10 +17,10 @@ type AuthenticationCache struct {
 	duration time.Duration
 }
 
-func NewAuthenticationCache(duration time.Duration) AuthenticationCache {
+func NewAuthenticationCache(cache *cache.Cache, duration time.Duration) AuthenticationCache {
 	return AuthenticationCache{
 		duration: duration,
-		cache:    cache.New(),
+		cache:    cache,
 	}
 }
 
@@ -49,3 +49,19 @@ func (r AuthenticationCache) Set(ctx context.Context, token string, data entity.
 
 	return nil
 }
+
+func (r AuthenticationCache) Delete(ctx context.Context, token string) {
+	r.cache.Delete(token)
+}
+
+func (r AuthenticationCache) DeleteByApplicationId(ctx context.Context, applicationId int) {
+	r.cache.DeleteFunc(func(key string, data []byte) bool {
+		authData := entity.AppAuthData{}
+		err := json.Unmarshal(data, &authData)
+		return err == nil && authData.ApplicationId == applicationId
+	})
+}
+
+func (r AuthenticationCache) Clear(ctx context.Context) {
+	r.cache.Clear()
+}
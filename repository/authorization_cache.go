@@ -13,10 +13,10 @@ type AuthorizationCache struct {
 	cache    *cache.Cache
 }
 
-func NewAuthorizationCache(duration time.Duration) *AuthorizationCache {
+func NewAuthorizationCache(cache *cache.Cache, duration time.Duration) *AuthorizationCache {
 	return &AuthorizationCache{
 		duration: duration,
-		cache:    cache.New(),
+		cache:    cache,
 	}
 }
 
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"isp-gate-service/conf"
+
+	"github.com/pkg/errors"
+	"github.com/txix-open/isp-kit/http/httpcli"
+	"github.com/txix-open/isp-kit/http/httpclix"
+)
+
+const (
+	defaultIntrospectionTimeout = 5 * time.Second
+)
+
+type Introspection struct {
+	cli *httpcli.Client
+}
+
+func NewIntrospection() Introspection {
+	return Introspection{
+		cli: httpclix.Default(),
+	}
+}
+
+// Introspect вызывает OAuth2 introspection endpoint (RFC 7662) и возвращает поля ответа
+func (r Introspection) Introspect(ctx context.Context, cfg conf.Introspection, token string) (map[string]any, error) {
+	form := map[string][]string{
+		"token": {token},
+	}
+	if cfg.TokenTypeHint != "" {
+		form["token_type_hint"] = []string{cfg.TokenTypeHint}
+	}
+	timeout := defaultIntrospectionTimeout
+	if cfg.TimeoutInSec > 0 {
+		timeout = time.Duration(cfg.TimeoutInSec) * time.Second
+	}
+
+	claims := make(map[string]any)
+	err := r.cli.Post(cfg.Endpoint).
+		BasicAuth(httpcli.BasicAuth{Username: cfg.ClientId, Password: cfg.ClientSecret}).
+		Header("Accept", "application/json").
+		FormDataRequestBody(form).
+		JsonResponseBody(&claims).
+		Timeout(timeout).
+		StatusCodeToError().
+		DoWithoutResponse(ctx)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "http client invoke: %s", cfg.Endpoint)
+	}
+	return claims, nil
+}
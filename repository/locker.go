@@ -12,20 +12,30 @@ import (
 const (
 	incrementEndpoint = "isp-lock-service/daily_limit/increment"
 	rateLimitEndpoint = "isp-lock-service/rate_limit"
+
+	incrementOperation = "daily_limit_increment"
+	rateLimitOperation = "rate_limit"
 )
 
+type LockerMetrics interface {
+	ObserveLockDuration(operation string, duration time.Duration, err error)
+}
+
 type Locker struct {
-	cli *client.Client
+	cli     *client.Client
+	metrics LockerMetrics
 }
 
-func NewLocker(cli *client.Client) Locker {
+func NewLocker(cli *client.Client, metrics LockerMetrics) Locker {
 	return Locker{
-		cli: cli,
+		cli:     cli,
+		metrics: metrics,
 	}
 }
 
 func (r Locker) Increment(ctx context.Context, key string, today time.Time) (int64, error) {
 	resp := new(entity.IncrementResponse)
+	start := time.Now()
 	err := r.cli.Invoke(incrementEndpoint).
 		JsonRequestBody(entity.IncrementRequest{
 			Key:   key,
@@ -33,6 +43,7 @@ func (r Locker) Increment(ctx context.Context, key string, today time.Time) (int
 		}).
 		JsonResponseBody(resp).
 		Do(ctx)
+	r.metrics.ObserveLockDuration(incrementOperation, time.Since(start), err)
 	switch {
 	case err != nil:
 		return 0, errors.WithMessagef(err, "invoke isp-lock-service: '%s'", incrementEndpoint)
@@ -43,6 +54,7 @@ func (r Locker) Increment(ctx context.Context, key string, today time.Time) (int
 
 func (r Locker) IsAllowRequestPerSecond(ctx context.Context, key string, rate int) (*entity.RateLimiterResponse, error) {
 	resp := new(entity.RateLimiterResponse)
+	start := time.Now()
 	err := r.cli.Invoke(rateLimitEndpoint).
 		JsonRequestBody(entity.RateLimiterRequest{
 			Key:    key,
@@ -50,6 +62,7 @@ func (r Locker) IsAllowRequestPerSecond(ctx context.Context, key string, rate in
 		}).
 		JsonResponseBody(resp).
 		Do(ctx)
+	r.metrics.ObserveLockDuration(rateLimitOperation, time.Since(start), err)
 	if err != nil {
 		return nil, errors.WithMessagef(err, "invoke isp-lock-service: '%s'", rateLimitEndpoint)
 	}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"isp-gate-service/entity"
+
+	"github.com/pkg/errors"
+	"github.com/txix-open/isp-kit/grpc/client"
+)
+
+const (
+	setIfAbsentEndpoint = "isp-lock-service/set_if_absent"
+
+	setIfAbsentOperation = "set_if_absent"
+)
+
+// NonceRegistry использованные nonce хранятся в isp-lock-service,
+// повтор запроса отклоняется любой репликой шлюза
+type NonceRegistry struct {
+	cli     *client.Client
+	metrics LockerMetrics
+}
+
+func NewNonceRegistry(cli *client.Client, metrics LockerMetrics) NonceRegistry {
+	return NonceRegistry{
+		cli:     cli,
+		metrics: metrics,
+	}
+}
+
+// Register запоминает nonce приложения,возвращает false если nonce уже использовался
+func (r NonceRegistry) Register(ctx context.Context, applicationId int, nonce string, lifeTime time.Duration) (bool, error) {
+	resp := new(entity.SetIfAbsentResponse)
+	start := time.Now()
+	err := r.cli.Invoke(setIfAbsentEndpoint).
+		JsonRequestBody(entity.SetIfAbsentRequest{
+			Key: fmt.Sprintf("isp-gate-service/nonce/%d/%s", applicationId, nonce),
+			Ttl: lifeTime,
+		}).
+		JsonResponseBody(resp).
+		Do(ctx)
+	r.metrics.ObserveLockDuration(setIfAbsentOperation, time.Since(start), err)
+	if err != nil {
+		return false, errors.WithMessagef(err, "invoke isp-lock-service: '%s'", setIfAbsentEndpoint)
+	}
+	return resp.Set, nil
+}
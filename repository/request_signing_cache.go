@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"isp-gate-service/cache"
+	"isp-gate-service/domain"
+	"isp-gate-service/entity"
+
+	"github.com/pkg/errors"
+	"github.com/txix-open/isp-kit/json"
+)
+
+type ApplicationSecretCache struct {
+	cache    *cache.Cache
+	duration time.Duration
+}
+
+func NewApplicationSecretCache(cache *cache.Cache, duration time.Duration) ApplicationSecretCache {
+	return ApplicationSecretCache{
+		duration: duration,
+		cache:    cache,
+	}
+}
+
+func (r ApplicationSecretCache) Get(ctx context.Context, applicationId int) (*entity.ApplicationSecretResponse, error) {
+	data, ok := r.cache.Get(strconv.Itoa(applicationId))
+	if !ok {
+		return nil, domain.ErrAuthenticationCacheMiss
+	}
+
+	result := entity.ApplicationSecretResponse{}
+	err := json.Unmarshal(data, &result)
+	if err != nil {
+		return nil, errors.WithMessage(err, "json unmarshal application secret")
+	}
+
+	return &result, nil
+}
+
+func (r ApplicationSecretCache) Set(ctx context.Context, applicationId int, data entity.ApplicationSecretResponse) error {
+	value, err := json.Marshal(data)
+	if err != nil {
+		return errors.WithMessage(err, "json marshal application secret")
+	}
+
+	r.cache.Set(strconv.Itoa(applicationId), value, r.duration)
+
+	return nil
+}
+
+func (r ApplicationSecretCache) Delete(ctx context.Context, applicationId int) {
+	r.cache.Delete(strconv.Itoa(applicationId))
+}
+
+func (r ApplicationSecretCache) Clear(ctx context.Context) {
+	r.cache.Clear()
+}
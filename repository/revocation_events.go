@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"isp-gate-service/entity"
+
+	"github.com/pkg/errors"
+	"github.com/txix-open/isp-kit/grpc/client"
+	"github.com/txix-open/isp-kit/json"
+)
+
+const (
+	publishEndpoint = "isp-lock-service/pubsub/publish"
+	pollEndpoint    = "isp-lock-service/pubsub/poll"
+
+	publishOperation = "pubsub_publish"
+	pollOperation    = "pubsub_poll"
+
+	revocationChannel = "isp-gate-service/revocation"
+)
+
+// RevocationEvents события отзыва,которыми реплики шлюза обмениваются через pub/sub isp-lock-service
+type RevocationEvents struct {
+	cli     *client.Client
+	metrics LockerMetrics
+}
+
+func NewRevocationEvents(cli *client.Client, metrics LockerMetrics) RevocationEvents {
+	return RevocationEvents{
+		cli:     cli,
+		metrics: metrics,
+	}
+}
+
+func (r RevocationEvents) Publish(ctx context.Context, req entity.RevocationRequest) error {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return errors.WithMessage(err, "marshal revocation request")
+	}
+
+	start := time.Now()
+	err = r.cli.Invoke(publishEndpoint).
+		JsonRequestBody(entity.PublishRequest{
+			Channel: revocationChannel,
+			Data:    data,
+		}).
+		Do(ctx)
+	r.metrics.ObserveLockDuration(publishOperation, time.Since(start), err)
+	if err != nil {
+		return errors.WithMessagef(err, "invoke isp-lock-service: '%s'", publishEndpoint)
+	}
+	return nil
+}
+
+// Poll возвращает события,опубликованные после afterId,и идентификатор последнего события канала
+func (r RevocationEvents) Poll(ctx context.Context, afterId int64) (*entity.PollResponse, error) {
+	resp := new(entity.PollResponse)
+	start := time.Now()
+	err := r.cli.Invoke(pollEndpoint).
+		JsonRequestBody(entity.PollRequest{
+			Channel: revocationChannel,
+			AfterId: afterId,
+		}).
+		JsonResponseBody(resp).
+		Do(ctx)
+	r.metrics.ObserveLockDuration(pollOperation, time.Since(start), err)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "invoke isp-lock-service: '%s'", pollEndpoint)
+	}
+	return resp, nil
+}
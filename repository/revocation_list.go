@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"isp-gate-service/cache"
+	"isp-gate-service/entity"
+)
+
+const (
+	allRevokedKey = "all"
+)
+
+// RevocationList хранит время отзыва токенов,приложений и пользователей,
+// чтобы ответы,полученные до отзыва,не попали обратно в кеш
+type RevocationList struct {
+	cache *cache.Cache
+}
+
+func NewRevocationList(cache *cache.Cache) RevocationList {
+	return RevocationList{
+		cache: cache,
+	}
+}
+
+func (r RevocationList) Add(ctx context.Context, req entity.RevocationRequest, revokedAt time.Time, lifeTime time.Duration) {
+	value := []byte(strconv.FormatInt(revokedAt.UnixNano(), 10))
+	if req.All {
+		r.cache.Set(allRevokedKey, value, lifeTime)
+	}
+	if req.Token != "" {
+		r.cache.Set(r.tokenKey(req.Token), value, lifeTime)
+	}
+	if req.ApplicationId != 0 {
+		r.cache.Set(r.applicationKey(req.ApplicationId), value, lifeTime)
+	}
+	if req.UserIdentity != "" {
+		r.cache.Set(r.userKey(req.UserIdentity), value, lifeTime)
+	}
+	if req.AdminToken != "" {
+		r.cache.Set(r.adminTokenKey(req.AdminToken), value, lifeTime)
+	}
+	if req.AdminId != 0 {
+		r.cache.Set(r.adminKey(req.AdminId), value, lifeTime)
+	}
+}
+
+func (r RevocationList) ApplicationRevoked(ctx context.Context, since time.Time, token string, applicationId int) bool {
+	return r.revokedAfter(since, allRevokedKey, r.tokenKey(token), r.applicationKey(applicationId))
+}
+
+func (r RevocationList) UserRevoked(ctx context.Context, since time.Time, token string, identity string) bool {
+	return r.revokedAfter(since, allRevokedKey, r.tokenKey(token), r.userKey(identity))
+}
+
+func (r RevocationList) AdminRevoked(ctx context.Context, since time.Time, token string, adminId int) bool {
+	return r.revokedAfter(since, allRevokedKey, r.adminTokenKey(token), r.adminKey(adminId))
+}
+
+func (r RevocationList) revokedAfter(since time.Time, keys ...string) bool {
+	for _, key := range keys {
+		value, ok := r.cache.Get(key)
+		if !ok {
+			continue
+		}
+		revokedAt, err := strconv.ParseInt(string(value), 10, 64)
+		if err == nil && revokedAt >= since.UnixNano() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r RevocationList) tokenKey(token string) string {
+	return "token:" + token
+}
+
+func (r RevocationList) applicationKey(applicationId int) string {
+	return "app:" + strconv.Itoa(applicationId)
+}
+
+func (r RevocationList) userKey(identity string) string {
+	return "user:" + identity
+}
+
+func (r RevocationList) adminTokenKey(token string) string {
+	return "admin-token:" + token
+}
+
+func (r RevocationList) adminKey(adminId int) string {
+	return "admin:" + strconv.Itoa(adminId)
+}
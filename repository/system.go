@@ -13,6 +13,8 @@ import (
 const (
 	authenticate = "system/secure/authenticate"
 	authorize    = "system/secure/authorize"
+	getAppSecret = "system/secure/get_application_secret"
+	getAppPerms  = "system/secure/get_application_permissions"
 )
 
 type System struct {
@@ -48,3 +50,27 @@ func (r System) Authorize(ctx context.Context, req entity.AuthorizeRequest) (boo
 	}
 	return resp.Authorized, nil
 }
+
+func (r System) GetApplicationSecret(ctx context.Context, applicationId int) (*entity.ApplicationSecretResponse, error) {
+	resp := entity.ApplicationSecretResponse{}
+	err := r.cli.Invoke(getAppSecret).
+		JsonRequestBody(entity.ApplicationSecretRequest{ApplicationId: applicationId}).
+		JsonResponseBody(&resp).
+		Do(ctx)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "grpc client invoke: %s", getAppSecret)
+	}
+	return &resp, nil
+}
+
+func (r System) GetApplicationPermissions(ctx context.Context, applicationId int) (*entity.ApplicationPermissionsResponse, error) {
+	resp := entity.ApplicationPermissionsResponse{}
+	err := r.cli.Invoke(getAppPerms).
+		JsonRequestBody(entity.ApplicationPermissionsRequest{ApplicationId: applicationId}).
+		JsonResponseBody(&resp).
+		Do(ctx)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "grpc client invoke: %s", getAppPerms)
+	}
+	return &resp, nil
+}
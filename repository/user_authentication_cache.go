@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"isp-gate-service/domain"
@@ -15,6 +16,9 @@ import (
 type Cache interface {
 	Get(key string) ([]byte, bool)
 	Set(key string, data []byte, lifeTime time.Duration)
+	Delete(key string)
+	DeleteFunc(fn func(key string, data []byte) bool)
+	Clear()
 }
 
 type UserAuthenticationCache struct {
@@ -27,13 +31,13 @@ func NewUserAuthenticationCache(cache Cache) UserAuthenticationCache {
 	}
 }
 
-func (r UserAuthenticationCache) Get(ctx context.Context, authMethodPath string, token string) (*entity.UserAuthData, error) {
+func (r UserAuthenticationCache) Get(ctx context.Context, authMethodPath string, token string) (*entity.CachedUserAuthData, error) {
 	data, ok := r.cache.Get(r.key(authMethodPath, token))
 	if !ok {
 		return nil, domain.ErrAuthenticationCacheMiss
 	}
 
-	result := entity.UserAuthData{}
+	result := entity.CachedUserAuthData{}
 	err := json.Unmarshal(data, &result)
 	if err != nil {
 		return nil, errors.WithMessage(err, "json unmarshal auth data")
@@ -46,7 +50,7 @@ func (r UserAuthenticationCache) Set(
 	ctx context.Context,
 	authMethodPath string,
 	token string,
-	data entity.UserAuthData,
+	data entity.CachedUserAuthData,
 	duration time.Duration,
 ) error {
 	value, err := json.Marshal(data)
@@ -59,6 +63,29 @@ func (r UserAuthenticationCache) Set(
 	return nil
 }
 
+func (r UserAuthenticationCache) Delete(ctx context.Context, authMethodPath string, token string) {
+	r.cache.Delete(r.key(authMethodPath, token))
+}
+
+func (r UserAuthenticationCache) DeleteByToken(ctx context.Context, token string) {
+	suffix := ":" + token
+	r.cache.DeleteFunc(func(key string, data []byte) bool {
+		return strings.HasSuffix(key, suffix)
+	})
+}
+
+func (r UserAuthenticationCache) DeleteByIdentity(ctx context.Context, identity string) {
+	r.cache.DeleteFunc(func(key string, data []byte) bool {
+		cached := entity.CachedUserAuthData{}
+		err := json.Unmarshal(data, &cached)
+		return err == nil && cached.AuthData.Identity == identity
+	})
+}
+
+func (r UserAuthenticationCache) Clear(ctx context.Context) {
+	r.cache.Clear()
+}
+
 func (r UserAuthenticationCache) key(authModuleName string, token string) string {
 	return fmt.Sprintf("%s:%s", authModuleName, token)
 }
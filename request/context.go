@@ -3,8 +3,11 @@ package request
 import (
 	"context"
 	"isp-gate-service/domain"
+	"net"
 	"net/http"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -29,7 +32,23 @@ type Context struct {
 	adminId            int
 	adminToken         string
 
+	clientIp    string
+	trustedPeer bool
+
 	queryParams map[string]string
+
+	consumedCredentials []Credential
+
+	internalTokenHeader string
+	internalToken       string
+
+	auditEvents []string
+
+	stage string
+
+	timings     []domain.StageTiming
+	timingName  string
+	timingStart time.Time
 }
 
 func NewContext(
@@ -65,6 +84,10 @@ func (c *Context) Authenticate(authData domain.AppAuthData) {
 	c.authData = &authData
 }
 
+func (c *Context) IsAuthenticated() bool {
+	return c.authenticated
+}
+
 func (c *Context) GetAuthData() (domain.AppAuthData, error) {
 	if !c.authenticated {
 		return domain.AppAuthData{}, ErrNotAuthenticated
@@ -106,6 +129,106 @@ func (c *Context) AuthenticateAdmin(adminId int, adminToken string) {
 	c.adminToken = adminToken
 }
 
+func (c *Context) SetClientIp(clientIp string, trustedPeer bool) {
+	c.clientIp = clientIp
+	c.trustedPeer = trustedPeer
+}
+
+func (c *Context) ClientIp() string {
+	if c.clientIp != "" {
+		return c.clientIp
+	}
+	host, _, err := net.SplitHostPort(c.request.RemoteAddr)
+	if err != nil {
+		return c.request.RemoteAddr
+	}
+	return host
+}
+
+// IsTrustedPeer сообщает, что запрос пришёл от доверенного прокси и его заголовки проксирования можно сохранить
+func (c *Context) IsTrustedPeer() bool {
+	return c.trustedPeer
+}
+
+func (c *Context) ConsumeCredential(credential Credential) {
+	c.consumedCredentials = append(c.consumedCredentials, credential)
+}
+
+// ConsumedCredentials источники учетных данных,из которых были получены токены приложения,администратора и пользователя
+func (c *Context) ConsumedCredentials() []Credential {
+	return c.consumedCredentials
+}
+
+func (c *Context) SetInternalToken(header string, token string) {
+	c.internalTokenHeader = header
+	c.internalToken = token
+}
+
+// InternalToken возвращает название заголовка и токен шлюза для передачи в upstream
+func (c *Context) InternalToken() (string, string) {
+	return c.internalTokenHeader, c.internalToken
+}
+
+func (c *Context) AddAuditEvent(event string) {
+	c.auditEvents = append(c.auditEvents, event)
+}
+
+// AuditEvents события авторизации,которые должны попасть в журнал аудита
+func (c *Context) AuditEvents() []string {
+	return c.auditEvents
+}
+
+// SetStage запоминает этап обработки,последний установленный этап сформировал ответ на запрос
+func (c *Context) SetStage(stage string) {
+	c.stage = stage
+}
+
+func (c *Context) Stage() string {
+	return c.stage
+}
+
+// StartTiming начинает замер этапа,предыдущий незавершенный замер завершается
+func (c *Context) StartTiming(name string) {
+	c.StopTiming()
+	c.timingName = name
+	c.timingStart = time.Now()
+}
+
+func (c *Context) StopTiming() {
+	if c.timingName == "" {
+		return
+	}
+	c.AddTiming(c.timingName, time.Since(c.timingStart))
+	c.timingName = ""
+}
+
+// AddTiming добавляет длительность этапа,длительности этапов с одинаковым названием суммируются
+func (c *Context) AddTiming(name string, duration time.Duration) {
+	for i := range c.timings {
+		if c.timings[i].Name == name {
+			c.timings[i].Duration += duration
+			return
+		}
+	}
+	c.timings = append(c.timings, domain.StageTiming{Name: name, Duration: duration})
+}
+
+// Timings замеры этапов,включая незавершенный на момент вызова этап
+func (c *Context) Timings() []domain.StageTiming {
+	timings := slices.Clone(c.timings)
+	if c.timingName == "" {
+		return timings
+	}
+	duration := time.Since(c.timingStart)
+	for i := range timings {
+		if timings[i].Name == c.timingName {
+			timings[i].Duration += duration
+			return timings
+		}
+	}
+	return append(timings, domain.StageTiming{Name: c.timingName, Duration: duration})
+}
+
 func (c *Context) Context() context.Context {
 	return c.request.Context()
 }
@@ -119,7 +242,11 @@ func (c *Context) Param(name string) string {
 	if value != "" {
 		return strings.TrimSpace(value)
 	}
+	return c.QueryParam(name)
+}
 
+// QueryParam возвращает первое значение query параметра,название сравнивается без учета регистра
+func (c *Context) QueryParam(name string) string {
 	if c.queryParams == nil {
 		query := c.request.URL.Query()
 		c.queryParams = map[string]string{}
@@ -130,7 +257,7 @@ func (c *Context) Param(name string) string {
 			c.queryParams[strings.ToLower(key)] = values[0]
 		}
 	}
-	value = c.queryParams[strings.ToLower(name)]
+	value := c.queryParams[strings.ToLower(name)]
 
 	return strings.TrimSpace(value)
 }
@@ -0,0 +1,13 @@
+package request
+
+const (
+	HeaderCredentialSource = "header"
+	QueryCredentialSource  = "query"
+	CookieCredentialSource = "cookie"
+)
+
+// Credential источник учетных данных в запросе,использованный при аутентификации
+type Credential struct {
+	Source string
+	Name   string
+}
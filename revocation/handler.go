@@ -0,0 +1,94 @@
+package revocation
+
+import (
+	"context"
+	"net/http"
+
+	"isp-gate-service/domain"
+	"isp-gate-service/entity"
+
+	"github.com/pkg/errors"
+	"github.com/txix-open/isp-kit/json"
+	"github.com/txix-open/isp-kit/log"
+)
+
+const (
+	adminTokenHeader = "x-auth-admin"
+)
+
+type Service interface {
+	Revoke(ctx context.Context, req entity.RevocationRequest) *entity.RevocationResponse
+}
+
+type AdminAuth interface {
+	AdminAuthenticate(ctx context.Context, token string) (*domain.AdminAuthenticateResponse, error)
+	AdminAuthorize(ctx context.Context, adminId int, permission string) (bool, error)
+}
+
+// Handler принимает запрос на отзыв токена,приложения,пользователя или всего кеша
+// от администратора,аутентифицированного через msp-admin-service
+func Handler(service Service, adminAuth AdminAuth, permission string, logger log.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		adminToken := r.Header.Get(adminTokenHeader)
+		if adminToken == "" {
+			http.Error(w, "admin token required", http.StatusUnauthorized)
+			return
+		}
+		admin, err := adminAuth.AdminAuthenticate(ctx, adminToken)
+		if err != nil {
+			logger.Error(ctx, errors.WithMessage(err, "revocation: admin authenticate"))
+			http.Error(w, "internal service error", http.StatusInternalServerError)
+			return
+		}
+		if !admin.Authenticated {
+			http.Error(w, "admin is not authenticated", http.StatusUnauthorized)
+			return
+		}
+		if permission != "" {
+			ok, err := adminAuth.AdminAuthorize(ctx, admin.AdminId, permission)
+			if err != nil {
+				logger.Error(ctx, errors.WithMessage(err, "revocation: admin authorize"))
+				http.Error(w, "internal service error", http.StatusInternalServerError)
+				return
+			}
+			if !ok {
+				http.Error(w, "permission denied", http.StatusForbidden)
+				return
+			}
+		}
+
+		req := entity.RevocationRequest{}
+		err = json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		if !req.All && req.Token == "" && req.ApplicationId == 0 && req.UserIdentity == "" &&
+			req.AdminToken == "" && req.AdminId == 0 {
+			http.Error(w, "one of token, applicationId, userIdentity, adminToken, adminId or all is required", http.StatusBadRequest)
+			return
+		}
+
+		logger.Info(
+			ctx,
+			"revocation: revoke",
+			log.Int("adminId", admin.AdminId),
+			log.Bool("token", req.Token != ""),
+			log.Int("applicationId", req.ApplicationId),
+			log.String("userIdentity", req.UserIdentity),
+			log.Bool("adminToken", req.AdminToken != ""),
+			log.Int("revokedAdminId", req.AdminId),
+			log.Bool("all", req.All),
+		)
+		resp := service.Revoke(ctx, req)
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+}
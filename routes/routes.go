@@ -111,6 +111,8 @@ func (s *Routes) registerEndpoint(
 		UserAuthRequired:        descriptor.UserAuthRequired,
 		ModuleName:              moduleName,
 		RequiredAdminPermission: requiredAdminPerm,
+		RequiredUserPermissions: extraStrings(descriptor.Extra, domain.RequiredUserPermissionsExtraKey),
+		RequiredUserRoles:       extraStrings(descriptor.Extra, domain.RequiredUserRolesExtraKey),
 		PathSchema:              descriptor.Path,
 	}
 
@@ -125,3 +127,24 @@ func (s *Routes) registerEndpoint(
 func normalizePath(path string) string {
 	return strings.TrimPrefix(path, "/")
 }
+
+// extraStrings читает из Extra описания endpoint строку или список строк
+func extraStrings(extra map[string]any, key string) []string {
+	switch value := extra[key].(type) {
+	case string:
+		return []string{value}
+	case []string:
+		return value
+	case []any:
+		result := make([]string, 0, len(value))
+		for _, item := range value {
+			str, ok := item.(string)
+			if ok {
+				result = append(result, str)
+			}
+		}
+		return result
+	default:
+		return nil
+	}
+}
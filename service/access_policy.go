@@ -0,0 +1,178 @@
+package service
+
+import (
+	"strings"
+
+	"isp-gate-service/conf"
+	"isp-gate-service/domain"
+
+	"github.com/google/cel-go/cel"
+	"github.com/pkg/errors"
+)
+
+const (
+	accessPolicyCostLimit = 10000
+)
+
+type accessPolicyRule struct {
+	name    string
+	effect  string
+	program cel.Program
+}
+
+type AccessPolicy struct {
+	rules []accessPolicyRule
+}
+
+// NewAccessPolicy компилирует условия правил при загрузке конфигурации
+func NewAccessPolicy(cfg conf.AccessPolicy) (AccessPolicy, error) {
+	if !cfg.Enable {
+		return AccessPolicy{}, nil
+	}
+
+	env, err := cel.NewEnv(
+		cel.Variable("request", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("app", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("user", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("admin", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("now", cel.TimestampType),
+	)
+	if err != nil {
+		return AccessPolicy{}, errors.WithMessage(err, "new cel env")
+	}
+
+	rules := make([]accessPolicyRule, 0, len(cfg.Rules))
+	for _, rule := range cfg.Rules {
+		ast, issues := env.Compile(rule.Condition)
+		if issues.Err() != nil {
+			return AccessPolicy{}, errors.WithMessagef(issues.Err(), "compile condition of rule '%s'", rule.Name)
+		}
+		if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+			return AccessPolicy{}, errors.Errorf("condition of rule '%s' must be bool, got %s", rule.Name, ast.OutputType())
+		}
+		program, err := env.Program(ast, cel.CostLimit(accessPolicyCostLimit))
+		if err != nil {
+			return AccessPolicy{}, errors.WithMessagef(err, "program of rule '%s'", rule.Name)
+		}
+		rules = append(rules, accessPolicyRule{
+			name:    rule.Name,
+			effect:  rule.Effect,
+			program: program,
+		})
+	}
+
+	return AccessPolicy{
+		rules: rules,
+	}, nil
+}
+
+func (s AccessPolicy) Enabled() bool {
+	return len(s.rules) > 0
+}
+
+func (s AccessPolicy) Evaluate(req domain.AccessPolicyRequest) domain.AccessPolicyDecision {
+	decision := domain.AccessPolicyDecision{}
+	activation := s.activation(req)
+	for _, rule := range s.rules {
+		matched, err := s.eval(rule, activation)
+		if err != nil {
+			if decision.Errors == nil {
+				decision.Errors = make(map[string]error)
+			}
+			decision.Errors[rule.name] = err
+			if rule.effect == conf.AuditPolicyEffect {
+				continue
+			}
+			// ошибка в правиле ALLOW или DENY не должна открывать доступ
+			decision.Deny = true
+			decision.Rule = rule.name
+			return decision
+		}
+		if !matched {
+			continue
+		}
+
+		switch rule.effect {
+		case conf.AuditPolicyEffect:
+			decision.AuditRules = append(decision.AuditRules, rule.name)
+		case conf.DenyPolicyEffect:
+			decision.Deny = true
+			decision.Rule = rule.name
+			return decision
+		default:
+			decision.Rule = rule.name
+			return decision
+		}
+	}
+	return decision
+}
+
+func (s AccessPolicy) eval(rule accessPolicyRule, activation map[string]any) (bool, error) {
+	out, _, err := rule.program.Eval(activation)
+	if err != nil {
+		return false, errors.WithMessage(err, "eval condition")
+	}
+	matched, ok := out.Value().(bool)
+	if !ok {
+		return false, errors.Errorf("condition result must be bool, got %T", out.Value())
+	}
+	return matched, nil
+}
+
+func (s AccessPolicy) activation(req domain.AccessPolicyRequest) map[string]any {
+	app := map[string]any{"authenticated": false}
+	if req.App != nil {
+		app = map[string]any{
+			"authenticated": true,
+			"id":            req.App.ApplicationId,
+			"name":          req.App.AppName,
+			"systemId":      req.App.SystemId,
+			"domainId":      req.App.DomainId,
+			"serviceId":     req.App.ServiceId,
+		}
+	}
+
+	user := map[string]any{
+		"authenticated": false,
+		"roles":         []string{},
+		"permissions":   []string{},
+		"headers":       map[string][]string{},
+	}
+	if req.User != nil {
+		headers := make(map[string][]string, len(req.User.ExtraHeaders))
+		for name, values := range req.User.ExtraHeaders {
+			headers[strings.ToLower(name)] = values
+		}
+		user = map[string]any{
+			"authenticated": true,
+			"identity":      req.User.Identity,
+			"roles":         nonNil(req.User.Roles),
+			"permissions":   nonNil(req.User.Permissions),
+			"headers":       headers,
+		}
+	}
+
+	return map[string]any{
+		"request": map[string]any{
+			"method":     req.Method,
+			"endpoint":   req.Endpoint,
+			"pathSchema": req.PathSchema,
+			"moduleName": req.ModuleName,
+			"clientIp":   req.ClientIp,
+		},
+		"app":  app,
+		"user": user,
+		"admin": map[string]any{
+			"authenticated": req.Admin,
+			"id":            req.AdminId,
+		},
+		"now": req.Time,
+	}
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
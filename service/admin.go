@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"time"
 
 	"isp-gate-service/domain"
 	"isp-gate-service/entity"
@@ -14,28 +15,67 @@ type AdminAuth interface {
 	Authorize(ctx context.Context, adminId int, permission string) (bool, error)
 }
 
+type AdminCache interface {
+	AuthorizationCache
+	GetAuthentication(ctx context.Context, token string) (*entity.AdminAuthenticateResponse, error)
+	SetAuthentication(ctx context.Context, token string, data entity.AdminAuthenticateResponse, duration time.Duration) error
+}
+
+type AdminRevocations interface {
+	AdminRevoked(ctx context.Context, since time.Time, token string, adminId int) bool
+}
+
 type Admin struct {
-	cache AuthorizationCache
-	repo  AdminAuth
+	cache                     AdminCache
+	repo                      AdminAuth
+	revocations               AdminRevocations
+	authCacheDuration         time.Duration
+	negativeAuthCacheDuration time.Duration
 }
 
-func NewAdmin(cache AuthorizationCache, adminAuth AdminAuth) Admin {
+func NewAdmin(
+	cache AdminCache,
+	adminAuth AdminAuth,
+	revocations AdminRevocations,
+	authCacheDuration time.Duration,
+	negativeAuthCacheDuration time.Duration,
+) Admin {
 	return Admin{
-		cache: cache,
-		repo:  adminAuth,
+		cache:                     cache,
+		repo:                      adminAuth,
+		revocations:               revocations,
+		authCacheDuration:         authCacheDuration,
+		negativeAuthCacheDuration: negativeAuthCacheDuration,
 	}
 }
 
 func (s Admin) AdminAuthenticate(ctx context.Context, token string) (*domain.AdminAuthenticateResponse, error) {
-	resp, err := s.repo.Authenticate(ctx, token)
-	if err != nil {
-		return nil, errors.WithMessage(err, "get admin token data from admin service")
+	if s.authCacheDuration <= 0 && s.negativeAuthCacheDuration <= 0 {
+		resp, err := s.repo.Authenticate(ctx, token)
+		if err != nil {
+			return nil, errors.WithMessage(err, "get admin token data from admin service")
+		}
+		return s.convertAuthResponse(resp), nil
+	}
+
+	cached, err := s.cache.GetAuthentication(ctx, token)
+	switch {
+	case errors.Is(err, domain.ErrAuthenticationCacheMiss):
+		startedAt := time.Now()
+		resp, err := s.repo.Authenticate(ctx, token)
+		if err != nil {
+			return nil, errors.WithMessage(err, "get admin token data from admin service")
+		}
+		err = s.cacheAuthentication(ctx, token, resp, startedAt)
+		if err != nil {
+			return nil, err
+		}
+		return s.convertAuthResponse(resp), nil
+	case err != nil:
+		return nil, errors.WithMessage(err, "admin auth cache get")
+	default:
+		return s.convertAuthResponse(cached), nil
 	}
-	return &domain.AdminAuthenticateResponse{
-		Authenticated: resp.Authenticated,
-		ErrorReason:   resp.ErrorReason,
-		AdminId:       resp.AdminId,
-	}, nil
 }
 
 func (s Admin) AdminAuthorize(ctx context.Context, adminId int, permission string) (bool, error) {
@@ -47,11 +87,12 @@ func (s Admin) AdminAuthorize(ctx context.Context, adminId int, permission strin
 		return ok, nil
 	}
 
+	startedAt := time.Now()
 	ok, err = s.repo.Authorize(ctx, adminId, permission)
 	if err != nil {
 		return false, errors.WithMessagef(err, "authz repo authorize")
 	}
-	if ok {
+	if ok && !s.revocations.AdminRevoked(ctx, startedAt, "", adminId) {
 		err = s.cache.SetAuthorized(ctx, adminId, permission)
 		if err != nil {
 			return false, errors.WithMessagef(err, "authz cache set")
@@ -60,3 +101,39 @@ func (s Admin) AdminAuthorize(ctx context.Context, adminId int, permission strin
 
 	return ok, nil
 }
+
+func (s Admin) cacheAuthentication(
+	ctx context.Context,
+	token string,
+	resp *entity.AdminAuthenticateResponse,
+	startedAt time.Time,
+) error {
+	duration := s.negativeAuthCacheDuration
+	if resp.Authenticated {
+		// ответ получен до отзыва сессии администратора и не должен вернуться в кеш
+		if s.revocations.AdminRevoked(ctx, startedAt, token, resp.AdminId) {
+			return nil
+		}
+		duration = s.authCacheDuration
+		if resp.ExpiresAt != nil {
+			duration = min(duration, time.Until(*resp.ExpiresAt))
+		}
+	}
+	if duration <= 0 {
+		return nil
+	}
+
+	err := s.cache.SetAuthentication(ctx, token, *resp, duration)
+	if err != nil {
+		return errors.WithMessage(err, "admin auth cache set")
+	}
+	return nil
+}
+
+func (s Admin) convertAuthResponse(resp *entity.AdminAuthenticateResponse) *domain.AdminAuthenticateResponse {
+	return &domain.AdminAuthenticateResponse{
+		Authenticated: resp.Authenticated,
+		ErrorReason:   resp.ErrorReason,
+		AdminId:       resp.AdminId,
+	}
+}
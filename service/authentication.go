@@ -2,6 +2,8 @@ package service
 
 import (
 	"context"
+	"time"
+
 	"isp-gate-service/domain"
 	"isp-gate-service/entity"
 
@@ -17,18 +19,25 @@ type AuthenticationRepo interface {
 	Authenticate(ctx context.Context, token string) (*entity.AuthenticateResponse, error)
 }
 
+type ApplicationRevocations interface {
+	ApplicationRevoked(ctx context.Context, since time.Time, token string, applicationId int) bool
+}
+
 type Authentication struct {
-	cache AuthenticationCache
-	repo  AuthenticationRepo
+	cache       AuthenticationCache
+	repo        AuthenticationRepo
+	revocations ApplicationRevocations
 }
 
 func NewAuthentication(
 	cache AuthenticationCache,
 	repo AuthenticationRepo,
+	revocations ApplicationRevocations,
 ) Authentication {
 	return Authentication{
-		cache: cache,
-		repo:  repo,
+		cache:       cache,
+		repo:        repo,
+		revocations: revocations,
 	}
 }
 
@@ -36,6 +45,7 @@ func (s Authentication) Authenticate(ctx context.Context, token string) (*domain
 	authData, err := s.cache.Get(ctx, token)
 	switch {
 	case errors.Is(err, domain.ErrAuthenticationCacheMiss):
+		startedAt := time.Now()
 		resp, err := s.repo.Authenticate(ctx, token)
 		if err != nil {
 			return nil, errors.WithMessage(err, "auth repo authenticate")
@@ -43,6 +53,10 @@ func (s Authentication) Authenticate(ctx context.Context, token string) (*domain
 		if !resp.Authenticated {
 			return s.convertAuthReponse(resp), nil
 		}
+		// ответ получен до отзыва токена или приложения и не должен вернуться в кеш
+		if s.revocations.ApplicationRevoked(ctx, startedAt, token, resp.AuthData.ApplicationId) {
+			return s.convertAuthReponse(resp), nil
+		}
 		err = s.cache.Set(ctx, token, *resp.AuthData)
 		if err != nil {
 			return nil, errors.WithMessage(err, "auth cache set")
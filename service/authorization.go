@@ -3,9 +3,18 @@ package service
 import (
 	"context"
 	"fmt"
+	"sync"
+	"time"
+
+	"isp-gate-service/domain"
 	"isp-gate-service/entity"
 
 	"github.com/pkg/errors"
+	"github.com/txix-open/isp-kit/log"
+)
+
+const (
+	permissionsRefreshTimeout = 15 * time.Second
 )
 
 type AuthorizationCache interface {
@@ -15,21 +24,51 @@ type AuthorizationCache interface {
 
 type AuthorizationRepo interface {
 	Authorize(ctx context.Context, req entity.AuthorizeRequest) (bool, error)
+	GetApplicationPermissions(ctx context.Context, applicationId int) (*entity.ApplicationPermissionsResponse, error)
+}
+
+type ApplicationPermissionsCache interface {
+	Get(ctx context.Context, applicationId int) (domain.ApplicationPermissions, bool)
+	Set(ctx context.Context, applicationId int, permissions domain.ApplicationPermissions)
 }
 
 type Authorization struct {
-	cache AuthorizationCache
-	repo  AuthorizationRepo
+	cache            AuthorizationCache
+	repo             AuthorizationRepo
+	permissionsCache ApplicationPermissionsCache
+	prefetch         bool
+	refreshInterval  time.Duration
+	refreshing       *sync.Map
+	logger           log.Logger
 }
 
-func NewAuthorization(cache AuthorizationCache, repo AuthorizationRepo) Authorization {
+func NewAuthorization(
+	cache AuthorizationCache,
+	repo AuthorizationRepo,
+	permissionsCache ApplicationPermissionsCache,
+	prefetch bool,
+	refreshInterval time.Duration,
+	logger log.Logger,
+) Authorization {
 	return Authorization{
-		cache: cache,
-		repo:  repo,
+		cache:            cache,
+		repo:             repo,
+		permissionsCache: permissionsCache,
+		prefetch:         prefetch,
+		refreshInterval:  refreshInterval,
+		refreshing:       &sync.Map{},
+		logger:           logger,
 	}
 }
 
 func (s Authorization) Authorize(ctx context.Context, applicationId int, httpMethod string, endpoint string) (bool, error) {
+	if s.prefetch {
+		permissions := s.applicationPermissions(ctx, applicationId)
+		if permissions.Endpoints != nil {
+			return permissions.Endpoints.Allowed(httpMethod, endpoint), nil
+		}
+	}
+
 	cacheKey := fmt.Sprintf("%s %s", httpMethod, endpoint)
 	ok, err := s.cache.Get(ctx, applicationId, cacheKey)
 	if err != nil {
@@ -56,3 +95,89 @@ func (s Authorization) Authorize(ctx context.Context, applicationId int, httpMet
 
 	return ok, nil
 }
+
+// applicationPermissions возвращает список разрешений приложения,
+// устаревший список обновляется в фоне,сильно устаревший - синхронно
+func (s Authorization) applicationPermissions(ctx context.Context, applicationId int) domain.ApplicationPermissions {
+	permissions, ok := s.permissionsCache.Get(ctx, applicationId)
+	age := time.Since(permissions.LoadedAt)
+	switch {
+	case !ok || age > 2*s.refreshInterval:
+		load := s.refreshPermissions(ctx, applicationId, permissions)
+		select {
+		case <-load.done:
+			return load.permissions
+		case <-ctx.Done():
+			return permissions
+		}
+	case age > s.refreshInterval:
+		s.refreshPermissions(ctx, applicationId, permissions)
+	}
+	return permissions
+}
+
+type permissionsLoad struct {
+	done        chan struct{}
+	permissions domain.ApplicationPermissions
+}
+
+// refreshPermissions запускает загрузку списка в фоне,
+// одновременные запросы одного приложения ожидают одну загрузку
+func (s Authorization) refreshPermissions(
+	ctx context.Context,
+	applicationId int,
+	previous domain.ApplicationPermissions,
+) *permissionsLoad {
+	load := &permissionsLoad{done: make(chan struct{})}
+	current, alreadyRefreshing := s.refreshing.LoadOrStore(applicationId, load)
+	if alreadyRefreshing {
+		return current.(*permissionsLoad) // nolint:forcetypeassert
+	}
+
+	go func() {
+		defer func() {
+			s.refreshing.Delete(applicationId)
+			close(load.done)
+		}()
+
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), permissionsRefreshTimeout)
+		defer cancel()
+		load.permissions = s.loadPermissions(ctx, applicationId, previous)
+	}()
+	return load
+}
+
+// loadPermissions при ошибке оставляет ранее загруженный список до следующего обновления,
+// если список ни разу не был загружен,используется проверка каждого endpoint
+func (s Authorization) loadPermissions(
+	ctx context.Context,
+	applicationId int,
+	previous domain.ApplicationPermissions,
+) domain.ApplicationPermissions {
+	permissions := domain.ApplicationPermissions{
+		Endpoints: previous.Endpoints,
+		LoadedAt:  time.Now(),
+	}
+	resp, err := s.repo.GetApplicationPermissions(ctx, applicationId)
+	switch {
+	case err != nil && previous.Endpoints != nil:
+		s.logger.Warn(
+			ctx,
+			errors.WithMessage(err, "authorization: get application permissions, keep previous permissions"),
+			log.Int("applicationId", applicationId),
+		)
+	case err != nil:
+		s.logger.Warn(
+			ctx,
+			errors.WithMessage(err, "authorization: get application permissions, fallback to per endpoint authorization"),
+			log.Int("applicationId", applicationId),
+		)
+	default:
+		permissions.Endpoints = domain.NewEndpointPermissionSet()
+		for _, endpoint := range resp.Endpoints {
+			permissions.Endpoints.Add(endpoint.HttpMethod, endpoint.Endpoint)
+		}
+	}
+	s.permissionsCache.Set(ctx, applicationId, permissions)
+	return permissions
+}
@@ -0,0 +1,121 @@
+package service_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"isp-gate-service/entity"
+	"isp-gate-service/repository"
+	"isp-gate-service/service"
+
+	"github.com/pkg/errors"
+	"github.com/txix-open/isp-kit/test"
+)
+
+type permissionsRepo struct {
+	calls     *atomic.Int32
+	fail      *atomic.Bool
+	release   chan struct{}
+	authorize *atomic.Int32
+}
+
+func newPermissionsRepo() permissionsRepo {
+	return permissionsRepo{
+		calls:     &atomic.Int32{},
+		fail:      &atomic.Bool{},
+		release:   make(chan struct{}),
+		authorize: &atomic.Int32{},
+	}
+}
+
+func (r permissionsRepo) Authorize(ctx context.Context, req entity.AuthorizeRequest) (bool, error) {
+	r.authorize.Add(1)
+	return false, nil
+}
+
+func (r permissionsRepo) GetApplicationPermissions(ctx context.Context, applicationId int) (*entity.ApplicationPermissionsResponse, error) {
+	r.calls.Add(1)
+	<-r.release
+	if r.fail.Load() {
+		return nil, errors.New("unavailable")
+	}
+	return &entity.ApplicationPermissionsResponse{
+		Endpoints: []entity.EndpointPermission{{HttpMethod: "POST", Endpoint: "/endpoint"}},
+	}, nil
+}
+
+type authorizationCache struct{}
+
+func (authorizationCache) Get(ctx context.Context, applicationId int, endpoint string) (bool, error) {
+	return false, nil
+}
+
+func (authorizationCache) SetAuthorized(ctx context.Context, applicationId int, endpoint string) error {
+	return nil
+}
+
+func TestAuthorization_PermissionsLoadedOnce(t *testing.T) {
+	t.Parallel()
+	test, require := test.New(t)
+
+	repo := newPermissionsRepo()
+	authorization := service.NewAuthorization(
+		authorizationCache{},
+		repo,
+		repository.NewApplicationPermissionsCache(),
+		true,
+		time.Minute,
+		test.Logger(),
+	)
+
+	wg := sync.WaitGroup{}
+	results := make(chan bool, 10)
+	for range 10 {
+		wg.Go(func() {
+			ok, err := authorization.Authorize(t.Context(), 1, "POST", "/endpoint")
+			require.NoError(err)
+			results <- ok
+		})
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(repo.release)
+	wg.Wait()
+	close(results)
+
+	require.EqualValues(1, repo.calls.Load())
+	for ok := range results {
+		require.True(ok)
+	}
+}
+
+func TestAuthorization_KeepPermissionsOnError(t *testing.T) {
+	t.Parallel()
+	test, require := test.New(t)
+
+	repo := newPermissionsRepo()
+	close(repo.release)
+	refreshInterval := 20 * time.Millisecond
+	authorization := service.NewAuthorization(
+		authorizationCache{},
+		repo,
+		repository.NewApplicationPermissionsCache(),
+		true,
+		refreshInterval,
+		test.Logger(),
+	)
+
+	ok, err := authorization.Authorize(t.Context(), 1, "POST", "/endpoint")
+	require.NoError(err)
+	require.True(ok)
+
+	repo.fail.Store(true)
+	time.Sleep(3 * refreshInterval)
+	ok, err = authorization.Authorize(t.Context(), 1, "POST", "/endpoint")
+	require.NoError(err)
+	require.True(ok)
+	require.EqualValues(2, repo.calls.Load())
+	require.Zero(repo.authorize.Load())
+}
@@ -0,0 +1,308 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"isp-gate-service/conf"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	bodyMask       = "***"
+	bodyHashPrefix = "hmac-sha256:"
+)
+
+type bodyMaskingRule struct {
+	endpointPrefixes []string
+	fields           [][]string
+	fieldsPattern    *regexp.Regexp
+	formPattern      *regexp.Regexp
+	patterns         []*regexp.Regexp
+	hashKey          []byte
+}
+
+type BodyMasking struct {
+	rules []bodyMaskingRule
+}
+
+// NewBodyMasking компилирует правила маскирования при загрузке конфигурации,
+// hashKey используется в режиме HASH,без ключа хеш короткого значения подбирается перебором
+func NewBodyMasking(rules []conf.BodyMaskingRule, hashKey []byte) (BodyMasking, error) {
+	result := make([]bodyMaskingRule, 0, len(rules))
+	for i, rule := range rules {
+		var ruleHashKey []byte
+		if rule.Mode == conf.HashBodyMaskingMode {
+			if len(hashKey) == 0 {
+				return BodyMasking{}, errors.Errorf("rule %d: hash mode requires bodyMasking.hashKey in local config", i)
+			}
+			ruleHashKey = hashKey
+		}
+		endpointPrefixes := make([]string, 0, len(rule.EndpointPrefixes))
+		for _, prefix := range rule.EndpointPrefixes {
+			endpointPrefixes = append(endpointPrefixes, strings.TrimPrefix(prefix, "/"))
+		}
+		fields := make([][]string, 0, len(rule.Fields))
+		fieldNames := make([]string, 0, len(rule.Fields))
+		for _, field := range rule.Fields {
+			path := strings.Split(field, ".")
+			fields = append(fields, path)
+			if path[len(path)-1] != "*" {
+				fieldNames = append(fieldNames, regexp.QuoteMeta(path[len(path)-1]))
+			}
+		}
+		var fieldsPattern, formPattern *regexp.Regexp
+		if len(fieldNames) > 0 {
+			fieldsPattern = regexp.MustCompile(
+				`("(?:` + strings.Join(fieldNames, "|") + `)"\s*:\s*)("(?:[^"\\]|\\.)*"?|[^\s,}\]{\["]+)`,
+			)
+			formPattern = regexp.MustCompile(`(?:^|&)(?:` + strings.Join(fieldNames, "|") + `)=([^&]*)`)
+		}
+		patterns := make([]*regexp.Regexp, 0, len(rule.Patterns))
+		for _, pattern := range rule.Patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return BodyMasking{}, errors.WithMessagef(err, "compile pattern '%s' of rule %d", pattern, i)
+			}
+			patterns = append(patterns, re)
+		}
+		result = append(result, bodyMaskingRule{
+			endpointPrefixes: endpointPrefixes,
+			fields:           fields,
+			fieldsPattern:    fieldsPattern,
+			formPattern:      formPattern,
+			patterns:         patterns,
+			hashKey:          ruleHashKey,
+		})
+	}
+	return BodyMasking{
+		rules: result,
+	}, nil
+}
+
+// Mask заменяет чувствительные данные в теле,остальная часть тела не изменяется,
+// при ошибке разбора JSON к телу применяются только регулярные выражения
+func (s BodyMasking) Mask(endpoint string, body []byte) []byte {
+	if len(body) == 0 {
+		return body
+	}
+	for _, rule := range s.rules {
+		if !rule.matchEndpoint(endpoint) {
+			continue
+		}
+		body = rule.mask(body)
+	}
+	return body
+}
+
+func (r bodyMaskingRule) matchEndpoint(endpoint string) bool {
+	if len(r.endpointPrefixes) == 0 {
+		return true
+	}
+	for _, prefix := range r.endpointPrefixes {
+		if strings.HasPrefix(endpoint, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r bodyMaskingRule) mask(body []byte) []byte {
+	if json.Valid(body) {
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		out := bytes.NewBuffer(make([]byte, 0, len(body)))
+		err := r.maskJsonValue(decoder, out, nil)
+		if err == nil {
+			return out.Bytes()
+		}
+	}
+
+	if r.fieldsPattern != nil {
+		body = r.maskTextFields(body)
+		body = r.maskFormFields(body)
+	}
+	for _, pattern := range r.patterns {
+		body = pattern.ReplaceAllFunc(body, func(value []byte) []byte {
+			return []byte(r.replacement(value))
+		})
+	}
+	return body
+}
+
+// maskTextFields маскирует скалярные значения полей по названию в теле,
+// которое не разбирается как JSON,например обрезанном при логировании
+func (r bodyMaskingRule) maskTextFields(body []byte) []byte {
+	matches := r.fieldsPattern.FindAllSubmatchIndex(body, -1)
+	if len(matches) == 0 {
+		return body
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(body)))
+	prev := 0
+	for _, match := range matches {
+		valueStart, valueEnd := match[4], match[5]
+		out.Write(body[prev:valueStart])
+		writeJsonString(out, r.replacement(bytes.Trim(body[valueStart:valueEnd], `"`)))
+		prev = valueEnd
+	}
+	out.Write(body[prev:])
+	return out.Bytes()
+}
+
+// maskFormFields маскирует значения полей по названию в теле application/x-www-form-urlencoded
+func (r bodyMaskingRule) maskFormFields(body []byte) []byte {
+	matches := r.formPattern.FindAllSubmatchIndex(body, -1)
+	if len(matches) == 0 {
+		return body
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(body)))
+	prev := 0
+	for _, match := range matches {
+		valueStart, valueEnd := match[2], match[3]
+		value := string(body[valueStart:valueEnd])
+		unescaped, err := url.QueryUnescape(value)
+		if err == nil {
+			value = unescaped
+		}
+		out.Write(body[prev:valueStart])
+		out.WriteString(r.replacement([]byte(value)))
+		prev = valueEnd
+	}
+	out.Write(body[prev:])
+	return out.Bytes()
+}
+
+// nolint:gocognit,cyclop
+func (r bodyMaskingRule) maskJsonValue(decoder *json.Decoder, out *bytes.Buffer, path []string) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err // nolint:wrapcheck
+	}
+
+	switch typed := token.(type) {
+	case json.Delim:
+		if typed == '[' {
+			out.WriteByte('[')
+			for i := 0; decoder.More(); i++ {
+				if i > 0 {
+					out.WriteByte(',')
+				}
+				// элементы массива не добавляют уровень в путь поля
+				err := r.maskJsonValue(decoder, out, path)
+				if err != nil {
+					return err
+				}
+			}
+			_, err := decoder.Token()
+			if err != nil {
+				return err // nolint:wrapcheck
+			}
+			out.WriteByte(']')
+			return nil
+		}
+
+		out.WriteByte('{')
+		for i := 0; decoder.More(); i++ {
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			keyToken, err := decoder.Token()
+			if err != nil {
+				return err // nolint:wrapcheck
+			}
+			key, _ := keyToken.(string)
+			writeJsonString(out, key)
+			out.WriteByte(':')
+
+			fieldPath := append(path[:len(path):len(path)], key)
+			if !r.matchField(fieldPath) {
+				err := r.maskJsonValue(decoder, out, fieldPath)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
+			value := json.RawMessage{}
+			err = decoder.Decode(&value)
+			if err != nil {
+				return err // nolint:wrapcheck
+			}
+			writeJsonString(out, r.replacement(bytes.Trim(value, `"`)))
+		}
+		_, err := decoder.Token()
+		if err != nil {
+			return err // nolint:wrapcheck
+		}
+		out.WriteByte('}')
+	case string:
+		for _, pattern := range r.patterns {
+			typed = pattern.ReplaceAllStringFunc(typed, func(value string) string {
+				return r.replacement([]byte(value))
+			})
+		}
+		writeJsonString(out, typed)
+	case json.Number:
+		out.WriteString(typed.String())
+	case bool:
+		if typed {
+			out.WriteString("true")
+		} else {
+			out.WriteString("false")
+		}
+	case nil:
+		out.WriteString("null")
+	}
+	return nil
+}
+
+// matchField название поля без точек ищется на любой глубине,путь через точку сравнивается от корня,
+// * соответствует любому полю
+func (r bodyMaskingRule) matchField(path []string) bool {
+	for _, field := range r.fields {
+		if len(field) == 1 {
+			if field[0] == path[len(path)-1] {
+				return true
+			}
+			continue
+		}
+		if len(field) != len(path) {
+			continue
+		}
+		matched := true
+		for i, segment := range field {
+			if segment != "*" && segment != path[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (r bodyMaskingRule) replacement(value []byte) string {
+	if r.hashKey == nil {
+		return bodyMask
+	}
+	mac := hmac.New(sha256.New, r.hashKey)
+	_, _ = mac.Write(value)
+	return bodyHashPrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+func writeJsonString(out *bytes.Buffer, value string) {
+	encoder := json.NewEncoder(out)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(value)
+	// Encode добавляет перевод строки после значения
+	out.Truncate(out.Len() - 1)
+}
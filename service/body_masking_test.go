@@ -0,0 +1,107 @@
+package service_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"isp-gate-service/conf"
+	"isp-gate-service/service"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBodyMasking(t *testing.T) {
+	t.Parallel()
+
+	masking, err := service.NewBodyMasking([]conf.BodyMaskingRule{{
+		Fields:   []string{"password", "*.passport.number"},
+		Patterns: []string{`\b\d{16}\b`, `[\w.]+@[\w.]+`},
+	}, {
+		EndpointPrefixes: []string{"/auth"},
+		Fields:           []string{"token"},
+		Mode:             conf.HashBodyMaskingMode,
+	}, {
+		EndpointPrefixes: []string{"/auth/form"},
+		Fields:           []string{"code"},
+		Mode:             conf.HashBodyMaskingMode,
+	}}, []byte("key"))
+	require.NoError(t, err)
+
+	hash := func(value string) string {
+		mac := hmac.New(sha256.New, []byte("key"))
+		_, _ = mac.Write([]byte(value))
+		return "hmac-sha256:" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	type testCase struct {
+		name     string
+		endpoint string
+		body     string
+		expected string
+	}
+	testCases := []testCase{{
+		name:     "field at any depth",
+		endpoint: "user/create",
+		body:     `{"login":"user","password":"123","nested":[{"password":{"old":"1"}}],"age":30}`,
+		expected: `{"login":"user","password":"***","nested":[{"password":"***"}],"age":30}`,
+	}, {
+		name:     "path from root",
+		endpoint: "user/create",
+		body:     `{"user":{"passport":{"number":"1234 567890","series":"12"}},"passport":{"number":"1"}}`,
+		expected: `{"user":{"passport":{"number":"***","series":"12"}},"passport":{"number":"1"}}`,
+	}, {
+		name:     "patterns in json strings",
+		endpoint: "payment",
+		body:     `{"comment":"card 4111111111111111, mail a.b@example.com","amount":1.50}`,
+		expected: `{"comment":"card ***, mail ***","amount":1.50}`,
+	}, {
+		name:     "patterns in plain text",
+		endpoint: "payment",
+		body:     `card=4111111111111111&sum=1`,
+		expected: `card=***&sum=1`,
+	}, {
+		name:     "fields in truncated json",
+		endpoint: "user/create",
+		body:     `{"password":"12\"3","pin":1234,"passport":{"number":"12`,
+		expected: `{"password":"***","pin":1234,"passport":{"number":"***"`,
+	}, {
+		name:     "hash for endpoint prefix",
+		endpoint: "auth/login",
+		body:     `{"token":"secret"}`,
+		expected: `{"token":"` + hash("secret") + `"}`,
+	}, {
+		name:     "fields in form body",
+		endpoint: "user/create",
+		body:     `login=user&password=p%40ss&old_password=1&password=2`,
+		expected: `login=user&password=***&old_password=1&password=***`,
+	}, {
+		name:     "hash of unescaped form value",
+		endpoint: "auth/form",
+		body:     `code=a%2Bb&state=1`,
+		expected: `code=` + hash("a+b") + `&state=1`,
+	}, {
+		name:     "rule for other endpoint is skipped",
+		endpoint: "user/token",
+		body:     `{"token":"secret"}`,
+		expected: `{"token":"secret"}`,
+	}}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			out := masking.Mask(tt.endpoint, []byte(tt.body))
+			require.Equal(t, tt.expected, string(out))
+		})
+	}
+
+	_, err = service.NewBodyMasking([]conf.BodyMaskingRule{{Patterns: []string{"("}}}, nil)
+	require.Error(t, err)
+
+	_, err = service.NewBodyMasking([]conf.BodyMaskingRule{{
+		Fields: []string{"token"},
+		Mode:   conf.HashBodyMaskingMode,
+	}}, nil)
+	require.Error(t, err)
+}
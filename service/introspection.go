@@ -0,0 +1,131 @@
+package service
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"isp-gate-service/conf"
+	"isp-gate-service/entity"
+
+	"github.com/txix-open/isp-kit/json"
+)
+
+const (
+	defaultIdentityClaim    = "sub"
+	defaultPermissionsClaim = "scope"
+)
+
+// introspectionResponse преобразует ответ introspection endpoint (RFC 7662) в ответ аутентификации пользователя
+func introspectionResponse(
+	claims map[string]any,
+	cfg conf.Introspection,
+	now time.Time,
+) *entity.UserAuthenticateResponse {
+	active, _ := claims["active"].(bool)
+	if !active {
+		return notAuthenticatedUser("token is not active")
+	}
+
+	var expiresAt *time.Time
+	exp, ok := claims["exp"].(float64)
+	if ok {
+		expiresAt = new(time.Unix(int64(exp), 0))
+		if !now.Before(*expiresAt) {
+			return notAuthenticatedUser("token is expired")
+		}
+	}
+
+	scopes := strings.Fields(claimString(claims["scope"]))
+	for _, required := range cfg.RequiredScopes {
+		if !slices.Contains(scopes, required) {
+			return notAuthenticatedUser("token has no required scope '" + required + "'")
+		}
+	}
+
+	identityClaim := cfg.IdentityClaim
+	if identityClaim == "" {
+		identityClaim = defaultIdentityClaim
+	}
+	identity := claimString(claims[identityClaim])
+	if identity == "" {
+		return notAuthenticatedUser("token has no claim '" + identityClaim + "'")
+	}
+
+	extraHeaders := make(map[string][]string, len(cfg.ClaimHeaders))
+	for _, claimHeader := range cfg.ClaimHeaders {
+		values := claimValues(claims[claimHeader.Claim])
+		if len(values) > 0 {
+			extraHeaders[claimHeader.Header] = values
+		}
+	}
+
+	permissionsClaim := cfg.PermissionsClaim
+	if permissionsClaim == "" {
+		permissionsClaim = defaultPermissionsClaim
+	}
+	var roles []string
+	if cfg.RolesClaim != "" {
+		roles = claimList(claims[cfg.RolesClaim])
+	}
+
+	return &entity.UserAuthenticateResponse{
+		Authenticated: true,
+		AuthData: &entity.UserAuthData{
+			Identity:       identity,
+			IdentityHeader: cfg.IdentityHeader,
+			ExtraHeaders:   extraHeaders,
+			Roles:          roles,
+			Permissions:    claimList(claims[permissionsClaim]),
+		},
+		ExpiresAt: expiresAt,
+	}
+}
+
+func notAuthenticatedUser(reason string) *entity.UserAuthenticateResponse {
+	return &entity.UserAuthenticateResponse{
+		Authenticated: false,
+		ErrorReason:   reason,
+	}
+}
+
+func claimValues(value any) []string {
+	switch typed := value.(type) {
+	case nil:
+		return nil
+	case []any:
+		values := make([]string, 0, len(typed))
+		for _, item := range typed {
+			values = append(values, claimString(item))
+		}
+		return values
+	default:
+		return []string{claimString(typed)}
+	}
+}
+
+// claimList возвращает элементы списка или строки,разделенной пробелами,как scope в RFC 7662
+func claimList(value any) []string {
+	str, ok := value.(string)
+	if ok {
+		return strings.Fields(str)
+	}
+	return claimValues(value)
+}
+
+func claimString(value any) string {
+	switch typed := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return typed
+	case float64:
+		return strconv.FormatFloat(typed, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(typed)
+	default:
+		data, _ := json.Marshal(typed)
+		return string(data)
+	}
+}
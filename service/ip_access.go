@@ -0,0 +1,81 @@
+package service
+
+import (
+	"isp-gate-service/clientip"
+	"isp-gate-service/conf"
+	"isp-gate-service/domain"
+
+	"github.com/pkg/errors"
+)
+
+type ipRule struct {
+	allow clientip.Networks
+	deny  clientip.Networks
+}
+
+type IpAccess struct {
+	locationRule        *ipRule
+	ruleByApplicationId map[int]ipRule
+}
+
+func NewIpAccess(applicationRules []conf.ApplicationIpRule, locationRule *conf.IpRule) (IpAccess, error) {
+	ruleByApplicationId := make(map[int]ipRule, len(applicationRules))
+	for _, rule := range applicationRules {
+		compiled, err := newIpRule(rule.Allow, rule.Deny)
+		if err != nil {
+			return IpAccess{}, errors.WithMessagef(err, "ip rule for application '%d'", rule.ApplicationId)
+		}
+		ruleByApplicationId[rule.ApplicationId] = compiled
+	}
+
+	var compiledLocationRule *ipRule
+	if locationRule != nil {
+		compiled, err := newIpRule(locationRule.Allow, locationRule.Deny)
+		if err != nil {
+			return IpAccess{}, errors.WithMessage(err, "location ip rule")
+		}
+		compiledLocationRule = &compiled
+	}
+
+	return IpAccess{
+		locationRule:        compiledLocationRule,
+		ruleByApplicationId: ruleByApplicationId,
+	}, nil
+}
+
+func (s IpAccess) CheckLocation(clientIp string) domain.IpAccessResult {
+	if s.locationRule != nil && !s.locationRule.isAllowed(clientIp) {
+		return domain.IpAccessResult{Allow: false, Reason: domain.IpDeniedByLocationReason}
+	}
+	return domain.IpAccessResult{Allow: true}
+}
+
+func (s IpAccess) CheckApplication(applicationId int, clientIp string) domain.IpAccessResult {
+	rule, ok := s.ruleByApplicationId[applicationId]
+	if ok && !rule.isAllowed(clientIp) {
+		return domain.IpAccessResult{Allow: false, Reason: domain.IpDeniedByApplicationReason}
+	}
+	return domain.IpAccessResult{Allow: true}
+}
+
+func newIpRule(allow []string, deny []string) (ipRule, error) {
+	allowNetworks, err := clientip.ParseNetworks(allow)
+	if err != nil {
+		return ipRule{}, errors.WithMessage(err, "parse allow list")
+	}
+	denyNetworks, err := clientip.ParseNetworks(deny)
+	if err != nil {
+		return ipRule{}, errors.WithMessage(err, "parse deny list")
+	}
+	return ipRule{
+		allow: allowNetworks,
+		deny:  denyNetworks,
+	}, nil
+}
+
+func (r ipRule) isAllowed(clientIp string) bool {
+	if r.deny.ContainsString(clientIp) {
+		return false
+	}
+	return len(r.allow) == 0 || r.allow.ContainsString(clientIp)
+}
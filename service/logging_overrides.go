@@ -0,0 +1,74 @@
+package service
+
+import (
+	"strings"
+	"time"
+
+	"isp-gate-service/conf"
+	"isp-gate-service/domain"
+)
+
+type LoggingOverrides struct {
+	rules []conf.LoggingOverride
+}
+
+func NewLoggingOverrides(rules []conf.LoggingOverride) LoggingOverrides {
+	normalized := make([]conf.LoggingOverride, 0, len(rules))
+	for _, rule := range rules {
+		rule.EndpointPrefix = strings.TrimPrefix(rule.EndpointPrefix, "/")
+		if rule.SampleRate <= 0 {
+			rule.SampleRate = 1
+		}
+		normalized = append(normalized, rule)
+	}
+	return LoggingOverrides{
+		rules: normalized,
+	}
+}
+
+func (s LoggingOverrides) Enabled() bool {
+	return len(s.rules) > 0
+}
+
+// BodyLogPossible проверяет до аутентификации,может ли какое-либо правило включить логирование тел для запроса
+func (s LoggingOverrides) BodyLogPossible(location string, endpoint string, now time.Time) bool {
+	for _, rule := range s.rules {
+		if rule.BodyLogEnable && s.active(rule, now) && s.matchRoute(rule, location, endpoint) {
+			return true
+		}
+	}
+	return false
+}
+
+// Find возвращает первое действующее правило,подходящее под запрос
+func (s LoggingOverrides) Find(req domain.LoggingOverrideRequest) (domain.LoggingOverride, bool) {
+	for _, rule := range s.rules {
+		if !s.active(rule, req.Time) || !s.matchRoute(rule, req.Location, req.Endpoint) {
+			continue
+		}
+		if rule.ApplicationId != 0 && rule.ApplicationId != req.ApplicationId {
+			continue
+		}
+		if rule.UserIdentity != "" && rule.UserIdentity != req.UserIdentity {
+			continue
+		}
+		return domain.LoggingOverride{
+			RequestLogEnable: rule.RequestLogEnable,
+			BodyLogEnable:    rule.BodyLogEnable,
+			Level:            rule.LogLevel,
+			SampleRate:       rule.SampleRate,
+		}, true
+	}
+	return domain.LoggingOverride{}, false
+}
+
+func (s LoggingOverrides) active(rule conf.LoggingOverride, now time.Time) bool {
+	return rule.ExpireAt.IsZero() || now.Before(rule.ExpireAt)
+}
+
+func (s LoggingOverrides) matchRoute(rule conf.LoggingOverride, location string, endpoint string) bool {
+	if rule.Location != "" && rule.Location != location {
+		return false
+	}
+	return strings.HasPrefix(endpoint, rule.EndpointPrefix)
+}
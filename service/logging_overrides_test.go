@@ -0,0 +1,63 @@
+package service_test
+
+import (
+	"testing"
+	"time"
+
+	"isp-gate-service/conf"
+	"isp-gate-service/domain"
+	"isp-gate-service/service"
+
+	"github.com/stretchr/testify/require"
+	"github.com/txix-open/isp-kit/log"
+)
+
+func TestLoggingOverrides(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	overrides := service.NewLoggingOverrides([]conf.LoggingOverride{{
+		ApplicationId:    1,
+		RequestLogEnable: true,
+		BodyLogEnable:    true,
+		ExpireAt:         now.Add(-time.Minute),
+	}, {
+		UserIdentity:     "debug-user",
+		EndpointPrefix:   "/orders",
+		RequestLogEnable: true,
+		BodyLogEnable:    true,
+		LogLevel:         log.DebugLevel,
+		SampleRate:       0.5,
+	}, {
+		Location:       "/api",
+		EndpointPrefix: "health",
+	}})
+
+	require.True(t, overrides.BodyLogPossible("/api", "orders/list", now))
+	require.False(t, overrides.BodyLogPossible("/api", "users/list", now))
+
+	_, ok := overrides.Find(domain.LoggingOverrideRequest{Location: "/api", Endpoint: "users", ApplicationId: 1, Time: now})
+	require.False(t, ok)
+
+	override, ok := overrides.Find(domain.LoggingOverrideRequest{
+		Location:     "/api",
+		Endpoint:     "orders/list",
+		UserIdentity: "debug-user",
+		Time:         now,
+	})
+	require.True(t, ok)
+	require.Equal(t, domain.LoggingOverride{
+		RequestLogEnable: true,
+		BodyLogEnable:    true,
+		Level:            log.DebugLevel,
+		SampleRate:       0.5,
+	}, override)
+
+	override, ok = overrides.Find(domain.LoggingOverrideRequest{Location: "/api", Endpoint: "health", Time: now})
+	require.True(t, ok)
+	require.False(t, override.RequestLogEnable)
+	require.InDelta(t, 1, override.SampleRate, 0)
+
+	_, ok = overrides.Find(domain.LoggingOverrideRequest{Location: "/ws", Endpoint: "health", Time: now})
+	require.False(t, ok)
+}
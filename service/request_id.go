@@ -0,0 +1,65 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"time"
+
+	"isp-gate-service/conf"
+
+	"github.com/google/uuid"
+	"github.com/txix-open/isp-kit/requestid"
+)
+
+const (
+	ulidAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+	ulidLength   = 26
+)
+
+type RequestIdGenerator struct {
+	format string
+	prefix string
+}
+
+func NewRequestIdGenerator(cfg conf.RequestId) RequestIdGenerator {
+	return RequestIdGenerator{
+		format: cfg.Format,
+		prefix: cfg.Prefix,
+	}
+}
+
+func (g RequestIdGenerator) Next() string {
+	switch g.format {
+	case conf.UuidRequestIdFormat:
+		return g.prefix + uuid.NewString()
+	case conf.UlidRequestIdFormat:
+		return g.prefix + newUlid(time.Now())
+	default:
+		return g.prefix + requestid.Next()
+	}
+}
+
+// newUlid 48 бит времени в миллисекундах и 80 случайных бит в кодировке Crockford base32
+func newUlid(now time.Time) string {
+	id := make([]byte, 16)                                          // nolint:mnd
+	binary.BigEndian.PutUint64(id[:8], uint64(now.UnixMilli())<<16) // nolint:gosec,mnd
+	_, err := rand.Read(id[6:])
+	if err != nil {
+		panic(err)
+	}
+
+	// 128 бит дополняются двумя старшими нулевыми битами до 26 символов по 5 бит
+	result := make([]byte, ulidLength)
+	for i := range result {
+		value := 0
+		for j := range 5 {
+			bit := i*5 + j - 2
+			value <<= 1
+			if bit >= 0 && id[bit/8]&(0x80>>(bit%8)) != 0 {
+				value |= 1
+			}
+		}
+		result[i] = ulidAlphabet[value]
+	}
+	return string(result)
+}
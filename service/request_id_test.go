@@ -0,0 +1,68 @@
+package service_test
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"isp-gate-service/conf"
+	"isp-gate-service/service"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestIdGenerator(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		cfg     conf.RequestId
+		pattern string
+	}{
+		{
+			name:    "default",
+			cfg:     conf.RequestId{},
+			pattern: `^[0-9a-f]{32}$`,
+		},
+		{
+			name:    "uuid",
+			cfg:     conf.RequestId{Format: conf.UuidRequestIdFormat},
+			pattern: `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[0-9a-f]{4}-[0-9a-f]{12}$`,
+		},
+		{
+			name:    "ulid",
+			cfg:     conf.RequestId{Format: conf.UlidRequestIdFormat},
+			pattern: `^[0-7][0-9A-HJKMNP-TV-Z]{25}$`,
+		},
+		{
+			name:    "prefix",
+			cfg:     conf.RequestId{Format: conf.HexRequestIdFormat, Prefix: "gate-"},
+			pattern: `^gate-[0-9a-f]{32}$`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			generator := service.NewRequestIdGenerator(test.cfg)
+			first := generator.Next()
+			require.Regexp(t, regexp.MustCompile(test.pattern), first)
+			require.NotEqual(t, first, generator.Next())
+		})
+	}
+
+	uuidGenerator := service.NewRequestIdGenerator(conf.RequestId{Format: conf.UuidRequestIdFormat, Prefix: "req-"})
+	_, err := uuid.Parse(strings.TrimPrefix(uuidGenerator.Next(), "req-"))
+	require.NoError(t, err)
+}
+
+func TestRequestIdGeneratorUlidOrder(t *testing.T) {
+	t.Parallel()
+
+	generator := service.NewRequestIdGenerator(conf.RequestId{Format: conf.UlidRequestIdFormat})
+	first := generator.Next()
+	time.Sleep(2 * time.Millisecond)
+	require.Less(t, first[:10], generator.Next()[:10])
+}
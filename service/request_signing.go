@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"isp-gate-service/domain"
+	"isp-gate-service/entity"
+
+	"github.com/pkg/errors"
+)
+
+type ApplicationSecretCache interface {
+	Get(ctx context.Context, applicationId int) (*entity.ApplicationSecretResponse, error)
+	Set(ctx context.Context, applicationId int, data entity.ApplicationSecretResponse) error
+}
+
+type ApplicationSecretRepo interface {
+	GetApplicationSecret(ctx context.Context, applicationId int) (*entity.ApplicationSecretResponse, error)
+}
+
+type NonceRegistry interface {
+	Register(ctx context.Context, applicationId int, nonce string, lifeTime time.Duration) (bool, error)
+}
+
+type RequestSigning struct {
+	cache        ApplicationSecretCache
+	repo         ApplicationSecretRepo
+	nonces       NonceRegistry
+	revocations  ApplicationRevocations
+	maxClockSkew time.Duration
+}
+
+func NewRequestSigning(
+	cache ApplicationSecretCache,
+	repo ApplicationSecretRepo,
+	nonces NonceRegistry,
+	revocations ApplicationRevocations,
+	maxClockSkew time.Duration,
+) RequestSigning {
+	return RequestSigning{
+		cache:        cache,
+		repo:         repo,
+		nonces:       nonces,
+		revocations:  revocations,
+		maxClockSkew: maxClockSkew,
+	}
+}
+
+func (s RequestSigning) Authenticate(ctx context.Context, req domain.SignedRequest) (*domain.AuthenticateAppResponse, error) {
+	skew := time.Since(req.Timestamp).Abs()
+	if skew > s.maxClockSkew {
+		return s.notAuthenticated("request timestamp is out of allowed clock skew"), nil
+	}
+
+	secret, err := s.applicationSecret(ctx, req.ApplicationId)
+	if err != nil {
+		return nil, errors.WithMessage(err, "get application secret")
+	}
+	if !secret.Found || secret.AuthData == nil {
+		return s.notAuthenticated("application secret not found"), nil
+	}
+	if secret.AuthData.ApplicationId != req.ApplicationId {
+		return s.notAuthenticated("application id mismatch"), nil
+	}
+
+	signature, err := hex.DecodeString(req.Signature)
+	if err != nil {
+		return s.notAuthenticated("signature is not a hex string"), nil
+	}
+	mac := hmac.New(sha256.New, []byte(secret.Secret))
+	_, _ = mac.Write([]byte(req.StringToSign))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return s.notAuthenticated("signature mismatch"), nil
+	}
+
+	// nonce хранится дольше окна расхождения времени,чтобы повтор не прошел на другой границе окна
+	registered, err := s.nonces.Register(ctx, req.ApplicationId, req.Nonce, 2*s.maxClockSkew)
+	if err != nil {
+		return nil, errors.WithMessage(err, "register nonce")
+	}
+	if !registered {
+		return s.notAuthenticated("nonce has already been used"), nil
+	}
+
+	return &domain.AuthenticateAppResponse{
+		Authenticated: true,
+		AuthData:      Authentication{}.convertAuthData(secret.AuthData),
+	}, nil
+}
+
+func (s RequestSigning) applicationSecret(ctx context.Context, applicationId int) (*entity.ApplicationSecretResponse, error) {
+	secret, err := s.cache.Get(ctx, applicationId)
+	switch {
+	case errors.Is(err, domain.ErrAuthenticationCacheMiss):
+		startedAt := time.Now()
+		secret, err = s.repo.GetApplicationSecret(ctx, applicationId)
+		if err != nil {
+			return nil, errors.WithMessage(err, "system repo get application secret")
+		}
+		if !secret.Found {
+			return secret, nil
+		}
+		if s.revocations.ApplicationRevoked(ctx, startedAt, "", applicationId) {
+			return secret, nil
+		}
+		err = s.cache.Set(ctx, applicationId, *secret)
+		if err != nil {
+			return nil, errors.WithMessage(err, "application secret cache set")
+		}
+		return secret, nil
+	case err != nil:
+		return nil, errors.WithMessage(err, "application secret cache get")
+	default:
+		return secret, nil
+	}
+}
+
+func (RequestSigning) notAuthenticated(reason string) *domain.AuthenticateAppResponse {
+	return &domain.AuthenticateAppResponse{
+		Authenticated: false,
+		ErrorReason:   reason,
+	}
+}
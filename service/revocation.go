@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"isp-gate-service/entity"
+
+	"github.com/pkg/errors"
+	"github.com/txix-open/isp-kit/json"
+	"github.com/txix-open/isp-kit/log"
+)
+
+type RevocationList interface {
+	Add(ctx context.Context, req entity.RevocationRequest, revokedAt time.Time, lifeTime time.Duration)
+}
+
+type RevocableAuthenticationCache interface {
+	Delete(ctx context.Context, token string)
+	DeleteByApplicationId(ctx context.Context, applicationId int)
+	Clear(ctx context.Context)
+}
+
+type RevocableApplicationSecretCache interface {
+	Delete(ctx context.Context, applicationId int)
+	Clear(ctx context.Context)
+}
+
+type RevocableUserAuthenticationCache interface {
+	DeleteByToken(ctx context.Context, token string)
+	DeleteByIdentity(ctx context.Context, identity string)
+	Clear(ctx context.Context)
+}
+
+type RevocableAdminCache interface {
+	DeleteByToken(ctx context.Context, token string)
+	DeleteByAdminId(ctx context.Context, adminId int)
+	Clear(ctx context.Context)
+}
+
+const (
+	revocationPublishAttempts   = 3
+	revocationPublishRetryDelay = 500 * time.Millisecond
+)
+
+type RevocationEvents interface {
+	Publish(ctx context.Context, req entity.RevocationRequest) error
+	Poll(ctx context.Context, afterId int64) (*entity.PollResponse, error)
+}
+
+type Revocation struct {
+	list           RevocationList
+	authCache      RevocableAuthenticationCache
+	appSecretCache RevocableApplicationSecretCache
+	userAuthCache  RevocableUserAuthenticationCache
+	adminCache     RevocableAdminCache
+	events         RevocationEvents
+	denylistTtl    time.Duration
+	logger         log.Logger
+}
+
+func NewRevocation(
+	list RevocationList,
+	authCache RevocableAuthenticationCache,
+	appSecretCache RevocableApplicationSecretCache,
+	userAuthCache RevocableUserAuthenticationCache,
+	adminCache RevocableAdminCache,
+	events RevocationEvents,
+	denylistTtl time.Duration,
+	logger log.Logger,
+) Revocation {
+	return Revocation{
+		list:           list,
+		authCache:      authCache,
+		appSecretCache: appSecretCache,
+		userAuthCache:  userAuthCache,
+		adminCache:     adminCache,
+		events:         events,
+		denylistTtl:    denylistTtl,
+		logger:         logger,
+	}
+}
+
+// Revoke удаляет данные из локальных кешей и публикует событие отзыва для остальных реплик шлюза,
+// если событие не опубликовано после повторов,локальный отзыв сохраняется,а в ответе broadcast=false
+func (s Revocation) Revoke(ctx context.Context, req entity.RevocationRequest) *entity.RevocationResponse {
+	s.revokeLocal(ctx, req)
+
+	err := s.retry(ctx, func() error {
+		return s.events.Publish(ctx, req)
+	})
+	if err != nil {
+		s.logger.Error(ctx, errors.WithMessage(err, "revocation: publish event"))
+		return &entity.RevocationResponse{Broadcast: false}
+	}
+	return &entity.RevocationResponse{Broadcast: true}
+}
+
+// Subscribe применяет события отзыва всех реплик до завершения ctx,
+// собственные события реплики применяются повторно,удаление из кешей идемпотентно
+func (s Revocation) Subscribe(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	lastId := int64(0)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			lastId = s.applyEvents(ctx, lastId)
+		}
+	}
+}
+
+func (s Revocation) applyEvents(ctx context.Context, afterId int64) int64 {
+	resp, err := s.events.Poll(ctx, afterId)
+	if err == nil && resp.LastId < afterId {
+		// нумерация событий начата заново после перезапуска isp-lock-service
+		resp, err = s.events.Poll(ctx, 0)
+	}
+	if err != nil {
+		s.logger.Error(ctx, errors.WithMessage(err, "revocation: poll events"))
+		return afterId
+	}
+
+	for _, message := range resp.Messages {
+		req := entity.RevocationRequest{}
+		err := json.Unmarshal(message.Data, &req)
+		if err != nil {
+			s.logger.Error(ctx, errors.WithMessagef(err, "revocation: unmarshal event %d", message.Id))
+			continue
+		}
+		s.revokeLocal(ctx, req)
+	}
+	return resp.LastId
+}
+
+func (s Revocation) retry(ctx context.Context, f func() error) error {
+	var err error
+	for attempt := range revocationPublishAttempts {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(revocationPublishRetryDelay):
+			}
+		}
+		err = f()
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (s Revocation) revokeLocal(ctx context.Context, req entity.RevocationRequest) {
+	s.list.Add(ctx, req, time.Now(), s.denylistTtl)
+
+	if req.All {
+		s.authCache.Clear(ctx)
+		s.appSecretCache.Clear(ctx)
+		s.userAuthCache.Clear(ctx)
+		s.adminCache.Clear(ctx)
+		return
+	}
+	if req.Token != "" {
+		s.authCache.Delete(ctx, req.Token)
+		s.userAuthCache.DeleteByToken(ctx, req.Token)
+	}
+	if req.ApplicationId != 0 {
+		s.authCache.DeleteByApplicationId(ctx, req.ApplicationId)
+		s.appSecretCache.Delete(ctx, req.ApplicationId)
+	}
+	if req.UserIdentity != "" {
+		s.userAuthCache.DeleteByIdentity(ctx, req.UserIdentity)
+	}
+	if req.AdminToken != "" {
+		s.adminCache.DeleteByToken(ctx, req.AdminToken)
+	}
+	if req.AdminId != 0 {
+		s.adminCache.DeleteByAdminId(ctx, req.AdminId)
+	}
+}
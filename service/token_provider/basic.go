@@ -0,0 +1,34 @@
+package token_provider
+
+import (
+	"isp-gate-service/request"
+	"strings"
+)
+
+type BasicProvider struct {
+	name string
+}
+
+func NewBasicProvider(name string) BasicProvider {
+	return BasicProvider{
+		name: name,
+	}
+}
+
+func (p BasicProvider) GetName() string {
+	return p.name
+}
+
+func (p BasicProvider) CredentialSource() request.Credential {
+	return request.Credential{Source: request.HeaderCredentialSource, Name: "Authorization"}
+}
+
+func (p BasicProvider) ExtractToken(ctx *request.Context) (string, error) {
+	_, password, _ := ctx.Request().BasicAuth()
+	return strings.TrimSpace(password), nil
+}
+
+// ExtractCredentials возвращает имя пользователя и пароль из basic auth
+func (p BasicProvider) ExtractCredentials(ctx *request.Context) (string, string, bool) {
+	return ctx.Request().BasicAuth()
+}
@@ -0,0 +1,44 @@
+package token_provider
+
+import (
+	"isp-gate-service/conf"
+	"isp-gate-service/request"
+	"strings"
+)
+
+const (
+	defaultBearerHeaderName = "Authorization"
+	bearerScheme            = "bearer "
+)
+
+type BearerProvider struct {
+	name       string
+	headerName string
+}
+
+func NewBearerProvider(name string, cfg conf.BearerTokenProvider) BearerProvider {
+	headerName := cfg.HeaderName
+	if headerName == "" {
+		headerName = defaultBearerHeaderName
+	}
+	return BearerProvider{
+		name:       name,
+		headerName: headerName,
+	}
+}
+
+func (p BearerProvider) GetName() string {
+	return p.name
+}
+
+func (p BearerProvider) CredentialSource() request.Credential {
+	return request.Credential{Source: request.HeaderCredentialSource, Name: p.headerName}
+}
+
+func (p BearerProvider) ExtractToken(ctx *request.Context) (string, error) {
+	value := strings.TrimSpace(ctx.Request().Header.Get(p.headerName))
+	if len(value) <= len(bearerScheme) || !strings.EqualFold(value[:len(bearerScheme)], bearerScheme) {
+		return "", nil
+	}
+	return strings.TrimSpace(value[len(bearerScheme):]), nil
+}
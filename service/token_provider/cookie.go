@@ -27,6 +27,10 @@ func (p CookieProvider) GetName() string {
 	return p.name
 }
 
+func (p CookieProvider) CredentialSource() request.Credential {
+	return request.Credential{Source: request.CookieCredentialSource, Name: p.cookieName}
+}
+
 func (p CookieProvider) ExtractToken(ctx *request.Context) (string, error) {
 	cookie, err := ctx.Request().Cookie(p.cookieName)
 	switch {
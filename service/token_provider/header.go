@@ -22,6 +22,10 @@ func (p HeaderProvider) GetName() string {
 	return p.name
 }
 
+func (p HeaderProvider) CredentialSource() request.Credential {
+	return request.Credential{Source: request.HeaderCredentialSource, Name: p.headerName}
+}
+
 func (p HeaderProvider) ExtractToken(ctx *request.Context) (string, error) {
 	value := ctx.Request().Header.Get(p.headerName)
 	return strings.TrimSpace(value), nil
@@ -0,0 +1,30 @@
+package token_provider
+
+import (
+	"isp-gate-service/conf"
+	"isp-gate-service/request"
+)
+
+type QueryProvider struct {
+	name      string
+	paramName string
+}
+
+func NewQueryProvider(name string, cfg conf.QueryTokenProvider) QueryProvider {
+	return QueryProvider{
+		name:      name,
+		paramName: cfg.ParamName,
+	}
+}
+
+func (p QueryProvider) GetName() string {
+	return p.name
+}
+
+func (p QueryProvider) CredentialSource() request.Credential {
+	return request.Credential{Source: request.QueryCredentialSource, Name: p.paramName}
+}
+
+func (p QueryProvider) ExtractToken(ctx *request.Context) (string, error) {
+	return ctx.QueryParam(p.paramName), nil
+}
@@ -0,0 +1,112 @@
+// nolint:ireturn
+package service
+
+import (
+	"isp-gate-service/conf"
+	"isp-gate-service/service/token_provider"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	applicationTokenName = "x-application-token"
+	adminTokenName       = "x-auth-admin"
+)
+
+type TokenProviders struct {
+	byName map[string]TokenProvider
+}
+
+func NewTokenProviders(cfg []conf.TokenProvider) (TokenProviders, error) {
+	byName := make(map[string]TokenProvider, len(cfg))
+	for i, provider := range cfg {
+		_, ok := byName[provider.Name]
+		if ok {
+			return TokenProviders{}, errors.Errorf("token provider name must have unique name, found duplicate at [%d] with name '%s'", i, provider.Name)
+		}
+
+		tokenProvider, err := tokenProviderFromConfig(provider)
+		if err != nil {
+			return TokenProviders{}, errors.WithMessagef(err, "init token provider with name '%s'", provider.Name)
+		}
+		byName[provider.Name] = tokenProvider
+	}
+	return TokenProviders{
+		byName: byName,
+	}, nil
+}
+
+// Select возвращает провайдеров по списку названий,при пустом списке - провайдеров по умолчанию.
+// При forbidQuery провайдеры, читающие query параметры, исключаются
+func (p TokenProviders) Select(names []string, defaults []TokenProvider, forbidQuery bool) ([]TokenProvider, error) {
+	selected := defaults
+	if len(names) > 0 {
+		selected = make([]TokenProvider, 0, len(names))
+		for _, name := range names {
+			provider, ok := p.byName[name]
+			if !ok {
+				return nil, errors.Errorf("unknown token provider '%s'", name)
+			}
+			selected = append(selected, provider)
+		}
+	}
+	if !forbidQuery {
+		return selected, nil
+	}
+
+	result := make([]TokenProvider, 0, len(selected))
+	for _, provider := range selected {
+		_, isQuery := provider.(token_provider.QueryProvider)
+		if !isQuery {
+			result = append(result, provider)
+		}
+	}
+	return result, nil
+}
+
+// DefaultApplicationTokenProviders заголовок или query параметр x-application-token,затем basic auth
+func DefaultApplicationTokenProviders() []TokenProvider {
+	return []TokenProvider{
+		token_provider.NewHeaderProvider("default-header", conf.HeaderTokenProvider{HeaderName: applicationTokenName}),
+		token_provider.NewQueryProvider("default-query", conf.QueryTokenProvider{ParamName: applicationTokenName}),
+		token_provider.NewBasicProvider("default-basic"),
+	}
+}
+
+// DefaultAdminTokenProviders заголовок или query параметр x-auth-admin
+func DefaultAdminTokenProviders() []TokenProvider {
+	return []TokenProvider{
+		token_provider.NewHeaderProvider("default-header", conf.HeaderTokenProvider{HeaderName: adminTokenName}),
+		token_provider.NewQueryProvider("default-query", conf.QueryTokenProvider{ParamName: adminTokenName}),
+	}
+}
+
+func tokenProviderFromConfig(cfg conf.TokenProvider) (TokenProvider, error) {
+	switch cfg.Type {
+	case conf.HeaderTokenProviderType:
+		if cfg.HeaderProvider == nil {
+			return nil, errors.Errorf("token method '%s' has empty header provider", cfg.Name)
+		}
+		return token_provider.NewHeaderProvider(cfg.Name, *cfg.HeaderProvider), nil
+	case conf.CookieTokenProviderType:
+		if cfg.CookieProvider == nil {
+			return nil, errors.Errorf("token method '%s' has empty cookie provider", cfg.Name)
+		}
+		return token_provider.NewCookieProvider(cfg.Name, *cfg.CookieProvider), nil
+	case conf.BearerTokenProviderType:
+		bearerProvider := conf.BearerTokenProvider{}
+		if cfg.BearerProvider != nil {
+			bearerProvider = *cfg.BearerProvider
+		}
+		return token_provider.NewBearerProvider(cfg.Name, bearerProvider), nil
+	case conf.QueryTokenProviderType:
+		if cfg.QueryProvider == nil {
+			return nil, errors.Errorf("token method '%s' has empty query provider", cfg.Name)
+		}
+		return token_provider.NewQueryProvider(cfg.Name, *cfg.QueryProvider), nil
+	case conf.BasicTokenProviderType:
+		return token_provider.NewBasicProvider(cfg.Name), nil
+	default:
+		return nil, errors.Errorf("unknown token provider with type '%s'", cfg.Type)
+	}
+}
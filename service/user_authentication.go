@@ -7,64 +7,78 @@ import (
 	"isp-gate-service/domain"
 	"isp-gate-service/entity"
 	"isp-gate-service/request"
-	"isp-gate-service/service/token_provider"
+	"math/rand/v2"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
 )
 
+const (
+	refreshAheadTimeout = 15 * time.Second
+)
+
 type UserAuthenticationCache interface {
-	Get(ctx context.Context, authEndpoint string, token string) (*entity.UserAuthData, error)
-	Set(ctx context.Context, authEndpoint string, token string, data entity.UserAuthData, duration time.Duration) error
+	Get(ctx context.Context, authEndpoint string, token string) (*entity.CachedUserAuthData, error)
+	Set(ctx context.Context, authEndpoint string, token string, data entity.CachedUserAuthData, duration time.Duration) error
+	Delete(ctx context.Context, authEndpoint string, token string)
 }
 
 type UserAuthenticationRepo interface {
 	Authenticate(ctx context.Context, authEndpoint string, token string) (*entity.UserAuthenticateResponse, error)
 }
 
+type IntrospectionRepo interface {
+	Introspect(ctx context.Context, cfg conf.Introspection, token string) (map[string]any, error)
+}
+
+type UserRevocations interface {
+	UserRevoked(ctx context.Context, since time.Time, token string, identity string) bool
+}
+
 type TokenProvider interface {
 	GetName() string
+	CredentialSource() request.Credential
 	ExtractToken(ctx *request.Context) (string, error)
 }
 
 type userAuthSetting struct {
-	tokenProviders    []TokenProvider
-	authEndpoint      string
-	authCacheDuration time.Duration
-	skipAppAuth       bool
+	tokenProviders      []TokenProvider
+	authEndpoint        string
+	introspection       *conf.Introspection
+	authCacheDuration   time.Duration
+	cacheJitterPercent  int
+	refreshAheadPercent int
+	skipAppAuth         bool
 }
 
 type UserAuthentication struct {
 	cache                UserAuthenticationCache
 	repo                 UserAuthenticationRepo
+	introspectionRepo    IntrospectionRepo
+	revocations          UserRevocations
 	settingsByModuleName map[string]userAuthSetting
+	refreshing           *sync.Map
 }
 
 func NewUserAuthentication(
 	cfg conf.CustomAuth,
 	cache UserAuthenticationCache,
 	repo UserAuthenticationRepo,
+	introspectionRepo IntrospectionRepo,
+	revocations UserRevocations,
 ) (UserAuthentication, error) {
-	tokenProviders := make(map[string]TokenProvider, len(cfg.TokenProviders))
-	for i, provider := range cfg.TokenProviders {
-		_, ok := tokenProviders[provider.Name]
-		if ok {
-			return UserAuthentication{}, errors.Errorf("token provider name must have unique name, found duplicate at [%d] with name '%s'", i, provider.Name)
-		}
-
-		tokenProvider, err := tokenProviderFromConfig(provider)
-		if err != nil {
-			return UserAuthentication{}, errors.WithMessagef(err, "init token provider with name '%s'", provider.Name)
-		}
-		tokenProviders[provider.Name] = tokenProvider
+	tokenProviders, err := NewTokenProviders(cfg.TokenProviders)
+	if err != nil {
+		return UserAuthentication{}, errors.WithMessage(err, "new token providers")
 	}
 
 	settingsByModuleName := make(map[string]userAuthSetting, len(cfg.UserAuthSettings))
 	for _, setting := range cfg.UserAuthSettings {
 		settingTokenProviders := make([]TokenProvider, 0, len(setting.TokenProviders))
 		for _, providerName := range setting.TokenProviders {
-			tokenProvider, ok := tokenProviders[providerName]
+			tokenProvider, ok := tokenProviders.byName[providerName]
 			if !ok {
 				return UserAuthentication{},
 					errors.Errorf("modules with names'[%s]' has unknown token provider '%s'",
@@ -76,6 +90,10 @@ func NewUserAuthentication(
 		}
 
 		cacheDuration := time.Duration(setting.CacheDataInSec) * time.Second
+		authEndpoint := setting.AuthenticateEndpoint
+		if setting.Introspection != nil {
+			authEndpoint = setting.Introspection.Endpoint
+		}
 		for _, moduleName := range setting.ModuleNameList {
 			_, ok := settingsByModuleName[moduleName]
 			if ok {
@@ -85,10 +103,13 @@ func NewUserAuthentication(
 					)
 			}
 			settingsByModuleName[moduleName] = userAuthSetting{
-				tokenProviders:    settingTokenProviders,
-				authEndpoint:      setting.AuthenticateEndpoint,
-				authCacheDuration: cacheDuration,
-				skipAppAuth:       setting.SkipAppAuth,
+				tokenProviders:      settingTokenProviders,
+				authEndpoint:        authEndpoint,
+				introspection:       setting.Introspection,
+				authCacheDuration:   cacheDuration,
+				cacheJitterPercent:  setting.CacheJitterPercent,
+				refreshAheadPercent: setting.RefreshAheadPercent,
+				skipAppAuth:         setting.SkipAppAuth,
 			}
 		}
 	}
@@ -96,7 +117,10 @@ func NewUserAuthentication(
 	return UserAuthentication{
 		cache:                cache,
 		repo:                 repo,
+		introspectionRepo:    introspectionRepo,
+		revocations:          revocations,
 		settingsByModuleName: settingsByModuleName,
+		refreshing:           &sync.Map{},
 	}, nil
 }
 
@@ -144,6 +168,10 @@ func (s UserAuthentication) extractToken(ctx *request.Context, providers []Token
 			)
 		}
 		if token != "" {
+			// копия токена в источнике другого провайдера также не должна попасть в upstream
+			for _, provider := range providers {
+				ctx.ConsumeCredential(provider.CredentialSource())
+			}
 			return token, nil
 		}
 	}
@@ -155,46 +183,122 @@ func (s UserAuthentication) authenticate(
 	setting userAuthSetting,
 	token string,
 ) (*domain.AuthenticateUserResponse, error) {
-	if setting.authCacheDuration <= 0 {
-		resp, err := s.repo.Authenticate(ctx, setting.authEndpoint, token)
+	if setting.authCacheDuration <= 0 && setting.introspection == nil {
+		resp, err := s.callBackend(ctx, setting, token)
 		if err != nil {
-			return nil, errors.WithMessage(err, "auth repo authenticate")
+			return nil, err
 		}
 		return s.convertAuthResponse(resp, setting.skipAppAuth), nil
 	}
 
-	authData, err := s.cache.Get(ctx, setting.authEndpoint, token)
+	cached, err := s.cache.Get(ctx, setting.authEndpoint, token)
 	switch {
 	case errors.Is(err, domain.ErrAuthenticationCacheMiss):
-		resp, err := s.repo.Authenticate(ctx, setting.authEndpoint, token)
+		startedAt := time.Now()
+		resp, err := s.callBackend(ctx, setting, token)
 		if err != nil {
-			return nil, errors.WithMessage(err, "auth repo authenticate")
+			return nil, err
 		}
-		if !resp.Authenticated {
-			return s.convertAuthResponse(resp, setting.skipAppAuth), nil
-		}
-		err = s.cache.Set(
-			ctx,
-			setting.authEndpoint,
-			token,
-			*resp.AuthData,
-			setting.authCacheDuration,
-		)
+		err = s.cacheResponse(ctx, setting, token, resp, startedAt)
 		if err != nil {
-			return nil, errors.WithMessage(err, "auth cache set")
+			return nil, err
 		}
 		return s.convertAuthResponse(resp, setting.skipAppAuth), nil
 	case err != nil:
 		return nil, errors.WithMessage(err, "auth cache get")
 	default:
+		if !cached.RefreshAt.IsZero() && time.Now().After(cached.RefreshAt) {
+			s.refreshAhead(ctx, setting, token)
+		}
 		return &domain.AuthenticateUserResponse{
 			Authenticated: true,
 			ErrorReason:   "",
-			AuthData:      s.convertAuthData(authData, setting.skipAppAuth),
+			AuthData:      s.convertAuthData(&cached.AuthData, setting.skipAppAuth),
 		}, nil
 	}
 }
 
+func (s UserAuthentication) cacheResponse(
+	ctx context.Context,
+	setting userAuthSetting,
+	token string,
+	resp *entity.UserAuthenticateResponse,
+	startedAt time.Time,
+) error {
+	if !resp.Authenticated {
+		return nil
+	}
+	// ответ получен до отзыва токена или пользователя и не должен вернуться в кеш
+	if s.revocations.UserRevoked(ctx, startedAt, token, resp.AuthData.Identity) {
+		return nil
+	}
+	now := time.Now()
+	duration := setting.cacheDuration(resp, now)
+	if duration <= 0 {
+		return nil
+	}
+
+	cached := entity.CachedUserAuthData{
+		AuthData:  *resp.AuthData,
+		RefreshAt: setting.refreshAt(duration, now),
+	}
+	err := s.cache.Set(ctx, setting.authEndpoint, token, cached, duration)
+	if err != nil {
+		return errors.WithMessage(err, "auth cache set")
+	}
+	return nil
+}
+
+// refreshAhead обновляет данные в кеше в фоне,пока текущая запись еще действительна.
+// При ошибке backend запись остается в кеше до истечения
+func (s UserAuthentication) refreshAhead(ctx context.Context, setting userAuthSetting, token string) {
+	key := setting.authEndpoint + ":" + token
+	_, alreadyRefreshing := s.refreshing.LoadOrStore(key, struct{}{})
+	if alreadyRefreshing {
+		return
+	}
+
+	go func() {
+		defer s.refreshing.Delete(key)
+
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), refreshAheadTimeout)
+		defer cancel()
+		startedAt := time.Now()
+		resp, err := s.callBackend(ctx, setting, token)
+		if err != nil {
+			return
+		}
+		if !resp.Authenticated {
+			s.cache.Delete(ctx, setting.authEndpoint, token)
+			return
+		}
+		_ = s.cacheResponse(ctx, setting, token, resp, startedAt)
+	}()
+}
+
+func (s UserAuthentication) callBackend(
+	ctx context.Context,
+	setting userAuthSetting,
+	token string,
+) (*entity.UserAuthenticateResponse, error) {
+	if setting.introspection == nil {
+		resp, err := s.repo.Authenticate(ctx, setting.authEndpoint, token)
+		if err != nil {
+			return nil, errors.WithMessage(err, "auth repo authenticate")
+		}
+		if resp.Authenticated && resp.ExpiresAt != nil && !time.Now().Before(*resp.ExpiresAt) {
+			return notAuthenticatedUser("token is expired"), nil
+		}
+		return resp, nil
+	}
+
+	claims, err := s.introspectionRepo.Introspect(ctx, *setting.introspection, token)
+	if err != nil {
+		return nil, errors.WithMessage(err, "introspection repo introspect")
+	}
+	return introspectionResponse(claims, *setting.introspection, time.Now()), nil
+}
+
 func (s UserAuthentication) convertAuthResponse(resp *entity.UserAuthenticateResponse, skipAppAuth bool) *domain.AuthenticateUserResponse {
 	return &domain.AuthenticateUserResponse{
 		Authenticated: resp.Authenticated,
@@ -212,23 +316,42 @@ func (UserAuthentication) convertAuthData(authData *entity.UserAuthData, skipApp
 		Identity:       authData.Identity,
 		IdentityHeader: authData.IdentityHeader,
 		ExtraHeaders:   authData.ExtraHeaders,
+		Roles:          authData.Roles,
+		Permissions:    authData.Permissions,
 		SkipAppAuth:    skipAppAuth,
 	}
 }
 
-func tokenProviderFromConfig(cfg conf.TokenProvider) (TokenProvider, error) {
-	switch cfg.Type {
-	case conf.HeaderTokenProviderType:
-		if cfg.HeaderProvider == nil {
-			return nil, errors.Errorf("token method '%s' has empty header provider", cfg.Name)
+// cacheDuration возвращает минимум из настроенного времени кеширования,cacheTtlSec и времени до expiresAt из ответа,
+// уменьшенный на случайную величину в пределах cacheJitterPercent
+func (s userAuthSetting) cacheDuration(resp *entity.UserAuthenticateResponse, now time.Time) time.Duration {
+	duration := s.authCacheDuration
+	if resp.CacheTtlSec > 0 {
+		hint := time.Duration(resp.CacheTtlSec) * time.Second
+		if duration <= 0 || hint < duration {
+			duration = hint
 		}
-		return token_provider.NewHeaderProvider(cfg.Name, *cfg.HeaderProvider), nil
-	case conf.CookieTokenProviderType:
-		if cfg.CookieProvider == nil {
-			return nil, errors.Errorf("token method '%s' has empty cookie provider", cfg.Name)
+	}
+	if resp.ExpiresAt != nil {
+		untilExpiry := resp.ExpiresAt.Sub(now)
+		if duration <= 0 || untilExpiry < duration {
+			duration = untilExpiry
 		}
-		return token_provider.NewCookieProvider(cfg.Name, *cfg.CookieProvider), nil
-	default:
-		return nil, errors.Errorf("unknown token provider with type '%s'", cfg.Type)
 	}
+	if duration <= 0 || s.cacheJitterPercent <= 0 {
+		return duration
+	}
+
+	maxJitter := int64(duration) * int64(s.cacheJitterPercent) / 100 //nolint:mnd
+	if maxJitter <= 0 {
+		return duration
+	}
+	return duration - time.Duration(rand.Int64N(maxJitter)) // nolint:gosec
+}
+
+func (s userAuthSetting) refreshAt(duration time.Duration, now time.Time) time.Time {
+	if s.refreshAheadPercent <= 0 {
+		return time.Time{}
+	}
+	return now.Add(duration * time.Duration(100-s.refreshAheadPercent) / 100) //nolint:mnd
 }
@@ -2,20 +2,40 @@
 package tests
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"maps"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"isp-gate-service/assembly"
+	"isp-gate-service/audit"
 	"isp-gate-service/cache"
 	"isp-gate-service/conf"
+	"isp-gate-service/domain"
 	"isp-gate-service/entity"
+	"isp-gate-service/gatemetrics"
+	"isp-gate-service/internaltoken"
+	"isp-gate-service/repository"
+	"isp-gate-service/revocation"
 	"isp-gate-service/routes"
+	"isp-gate-service/service"
 
 	"github.com/stretchr/testify/require"
 	"github.com/txix-open/etp/v3"
@@ -25,19 +45,32 @@ import (
 	"github.com/txix-open/isp-kit/http/endpoint/httplog"
 	"github.com/txix-open/isp-kit/http/httpcli"
 	"github.com/txix-open/isp-kit/http/router"
+	"github.com/txix-open/isp-kit/json"
 	"github.com/txix-open/isp-kit/test/fake"
 
 	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/suite"
 	"github.com/txix-open/isp-kit/grpc"
 	"github.com/txix-open/isp-kit/grpc/client"
+	"github.com/txix-open/isp-kit/grpc/isp"
 	"github.com/txix-open/isp-kit/lb"
 	"github.com/txix-open/isp-kit/log"
+	"github.com/txix-open/isp-kit/log/file"
+	"github.com/txix-open/isp-kit/metrics"
+	"github.com/txix-open/isp-kit/observability/tracing"
 	"github.com/txix-open/isp-kit/requestid"
 	"github.com/txix-open/isp-kit/test"
 	"github.com/txix-open/isp-kit/test/grpct"
 	"github.com/txix-open/isp-kit/test/httpt"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	grpc2 "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 type request struct {
@@ -91,7 +124,17 @@ func (s *HappyPathTestSuite) TestGrpcProxy() {
 	}})
 	require.NoError(err)
 
-	locator := assembly.NewLocator(logger, targetClients, nil, routes, systemCli, adminCli, nil, nil, nil)
+	locator := assembly.NewLocator(assembly.LocatorDeps{
+		Logger:          logger,
+		GrpcClients:     targetClients,
+		Routes:          routes,
+		SystemCli:       systemCli,
+		AdminCli:        adminCli,
+		AppAuthCache:    cache.New(),
+		AppSecretCache:  cache.New(),
+		RevocationCache: cache.New(),
+		AdminCache:      cache.New(),
+	})
 
 	locations := []conf.Location{{
 		SkipAuth:     false,
@@ -118,6 +161,206 @@ func (s *HappyPathTestSuite) TestGrpcProxy() {
 	require.EqualValues(req.Id, resp.Id)
 }
 
+func (s *HappyPathTestSuite) TestGrpcProxy_ResponseMetadata() {
+	test, require := test.New(s.T())
+	config, systemCli, adminCli := s.commonDependencies(test)
+	config.GrpcProxy.ResponseHeadersAllowlist = []string{"Set-Cookie", "Location", "Content-Type"}
+
+	targetService, targetCli := grpct.NewMock(test)
+	targetService.Mock("endpoint", func(ctx context.Context) ([]byte, error) {
+		err := grpc2.SetHeader(ctx, metadata.Pairs(
+			"x-http-status", "302",
+			"x-http-location", "/somewhere",
+			"x-http-set-cookie", "a=1",
+			"x-http-set-cookie", "b=2",
+			"x-http-x-debug", "forbidden",
+		))
+		if err != nil {
+			return nil, err
+		}
+		err = grpc2.SetTrailer(ctx, metadata.Pairs("x-http-content-type", "text/plain"))
+		return []byte("redirect"), err
+	})
+	targetClients := map[string]*client.Client{"target": targetCli}
+
+	routes := routes.NewRoutes(test.Logger())
+	err := routes.ReceiveRoutes(s.T().Context(), cluster.RoutingConfig{{
+		Endpoints: []cluster.EndpointDescriptor{{
+			Path: "endpoint",
+		}},
+	}})
+	require.NoError(err)
+
+	locator := assembly.NewLocator(assembly.LocatorDeps{
+		Logger:          test.Logger(),
+		GrpcClients:     targetClients,
+		Routes:          routes,
+		SystemCli:       systemCli,
+		AdminCli:        adminCli,
+		AppAuthCache:    cache.New(),
+		AppSecretCache:  cache.New(),
+		RevocationCache: cache.New(),
+		AdminCache:      cache.New(),
+	})
+	locations := []conf.Location{{
+		PathPrefix:   "/api",
+		Protocol:     "grpc",
+		TargetModule: "target",
+	}}
+	handler, err := locator.Handler(config, locations)
+	require.NoError(err)
+
+	srv := httptest.NewServer(handler)
+	httpCli := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	httpReq, err := http.NewRequestWithContext(s.T().Context(), http.MethodPost, srv.URL+"/api/endpoint", nil)
+	require.NoError(err)
+	httpReq.Header.Set("x-application-token", "token")
+	httpResp, err := httpCli.Do(httpReq)
+	require.NoError(err)
+	defer httpResp.Body.Close()
+
+	require.EqualValues(http.StatusFound, httpResp.StatusCode)
+	require.EqualValues("/somewhere", httpResp.Header.Get("Location"))
+	require.ElementsMatch([]string{"a=1", "b=2"}, httpResp.Header.Values("Set-Cookie"))
+	require.EqualValues("text/plain", httpResp.Header.Get("Content-Type"))
+	require.Empty(httpResp.Header.Get("X-Debug"))
+
+	// без списка разрешенных заголовков переопределяется только статус
+	config.GrpcProxy.ResponseHeadersAllowlist = nil
+	handler, err = locator.Handler(config, locations)
+	require.NoError(err)
+	denySrv := httptest.NewServer(handler)
+	httpReq, err = http.NewRequestWithContext(s.T().Context(), http.MethodPost, denySrv.URL+"/api/endpoint", nil)
+	require.NoError(err)
+	httpReq.Header.Set("x-application-token", "token")
+	deniedResp, err := httpCli.Do(httpReq)
+	require.NoError(err)
+	defer deniedResp.Body.Close()
+
+	require.EqualValues(http.StatusFound, deniedResp.StatusCode)
+	require.Empty(deniedResp.Header.Get("Location"))
+	require.Empty(deniedResp.Header.Values("Set-Cookie"))
+}
+
+func (s *HappyPathTestSuite) TestGrpcProxy_ProblemJsonErrors() {
+	test, require := test.New(s.T())
+	config, systemCli, adminCli := s.commonDependencies(test)
+	config.Http.ErrorFormat = conf.ProblemJsonErrorFormat
+	config.LocationSettings = []conf.LocationSetting{{
+		PathPrefix: "/api",
+		GrpcStatusMapping: []conf.GrpcStatusMapping{{
+			GrpcCode:   "Aborted",
+			HttpStatus: http.StatusConflict,
+		}},
+	}}
+
+	targetService, targetCli := grpct.NewMock(test)
+	targetService.Mock("endpoint", func() error {
+		return status.Error(codes.Aborted, "concurrent modification")
+	}).Mock("validate", func() error {
+		st, err := status.New(codes.InvalidArgument, "validation failed").WithDetails(
+			&isp.Message{Body: &isp.Message_BytesBody{BytesBody: []byte(`{"field":"name"}`)}},
+			&isp.Message{Body: &isp.Message_BytesBody{BytesBody: []byte("plain text")}},
+		)
+		require.NoError(err)
+		return st.Err()
+	})
+	targetClients := map[string]*client.Client{"target": targetCli}
+
+	routes := routes.NewRoutes(test.Logger())
+	err := routes.ReceiveRoutes(s.T().Context(), cluster.RoutingConfig{{
+		Endpoints: []cluster.EndpointDescriptor{{
+			Path: "endpoint",
+		}, {
+			Path: "validate",
+		}},
+	}})
+	require.NoError(err)
+
+	logFile := s.T().TempDir() + "/gate.log"
+	logger, err := log.New(
+		log.WithLevel(log.DebugLevel),
+		log.WithDisableDefaultOutput(),
+		log.WithFileOutput(file.Output{File: logFile}),
+	)
+	require.NoError(err)
+	locator := assembly.NewLocator(assembly.LocatorDeps{
+		Logger:          logger,
+		GrpcClients:     targetClients,
+		Routes:          routes,
+		SystemCli:       systemCli,
+		AdminCli:        adminCli,
+		AppAuthCache:    cache.New(),
+		AppSecretCache:  cache.New(),
+		RevocationCache: cache.New(),
+		AdminCache:      cache.New(),
+	})
+	locations := []conf.Location{{
+		PathPrefix:   "/api",
+		Protocol:     "grpc",
+		TargetModule: "target",
+	}}
+	handler, err := locator.Handler(config, locations)
+	require.NoError(err)
+	srv := httptest.NewServer(handler)
+
+	requestId := requestid.Next()
+	resp, err := httpcli.New().Post(srv.URL+"/api/endpoint").
+		Header("x-application-token", "token").
+		Header("x-request-id", requestId).
+		Do(s.T().Context())
+	require.NoError(err)
+	require.EqualValues(http.StatusConflict, resp.StatusCode())
+	require.EqualValues("application/problem+json", resp.Raw.Header.Get("Content-Type"))
+	problem := make(map[string]any)
+	body, err := resp.BodyCopy()
+	require.NoError(err)
+	err = json.Unmarshal(body, &problem)
+	require.NoError(err)
+	require.EqualValues(http.StatusConflict, problem["status"])
+	require.EqualValues("Conflict", problem["title"])
+	require.EqualValues("concurrent modification", problem["detail"])
+	require.EqualValues(requestId, problem["instance"])
+
+	resp, err = httpcli.New().Post(srv.URL + "/api/endpoint").Do(s.T().Context())
+	require.NoError(err)
+	require.EqualValues(http.StatusUnauthorized, resp.StatusCode())
+	require.EqualValues("application/problem+json", resp.Raw.Header.Get("Content-Type"))
+	problem = make(map[string]any)
+	body, err = resp.BodyCopy()
+	require.NoError(err)
+	err = json.Unmarshal(body, &problem)
+	require.NoError(err)
+	require.EqualValues("application token required", problem["detail"])
+
+	resp, err = httpcli.New().Post(srv.URL+"/api/validate").
+		Header("x-application-token", "token").
+		Do(s.T().Context())
+	require.NoError(err)
+	require.EqualValues(http.StatusBadRequest, resp.StatusCode())
+	require.EqualValues("application/problem+json", resp.Raw.Header.Get("Content-Type"))
+	problem = make(map[string]any)
+	body, err = resp.BodyCopy()
+	require.NoError(err)
+	err = json.Unmarshal(body, &problem)
+	require.NoError(err)
+	require.EqualValues("validation failed", problem["detail"])
+	require.EqualValues([]any{map[string]any{"field": "name"}, "plain text"}, problem["details"])
+
+	require.NoError(logger.Sync())
+	logs, err := os.ReadFile(logFile)
+	require.NoError(err)
+	for line := range strings.Lines(string(logs)) {
+		if strings.Contains(line, "validation failed") {
+			require.NotContains(line, `"level":"error"`)
+		}
+	}
+}
+
 func (s *HappyPathTestSuite) TestHttpProxy() {
 	test, require := test.New(s.T())
 	config, systemCli, adminCli := s.commonDependencies(test)
@@ -141,7 +384,17 @@ func (s *HappyPathTestSuite) TestHttpProxy() {
 	}})
 	require.NoError(err)
 
-	locator := assembly.NewLocator(test.Logger(), nil, targetClients, routes, systemCli, adminCli, nil, nil, nil)
+	locator := assembly.NewLocator(assembly.LocatorDeps{
+		Logger:           test.Logger(),
+		HttpHostManagers: targetClients,
+		Routes:           routes,
+		SystemCli:        systemCli,
+		AdminCli:         adminCli,
+		AppAuthCache:     cache.New(),
+		AppSecretCache:   cache.New(),
+		RevocationCache:  cache.New(),
+		AdminCache:       cache.New(),
+	})
 	locations := []conf.Location{{
 		SkipAuth:     false,
 		PathPrefix:   "/api",
@@ -167,162 +420,1414 @@ func (s *HappyPathTestSuite) TestHttpProxy() {
 	require.EqualValues(req.Id, resp.Id)
 }
 
-func (s *HappyPathTestSuite) TestWsProxy() { // nolint:funlen
+func (s *HappyPathTestSuite) TestHttpProxy_HeaderRules() {
 	test, require := test.New(s.T())
 	config, systemCli, adminCli := s.commonDependencies(test)
+	config.LocationSettings = []conf.LocationSetting{{
+		PathPrefix: "/api",
+		RequestHeaderRules: []conf.HeaderRule{
+			{Action: conf.SetHeaderAction, Name: "X-Caller", Value: "app-{applicationId}"},
+			{Action: conf.RenameHeaderAction, Name: "X-Legacy", NewName: "X-Modern"},
+		},
+		ResponseHeaderRules: []conf.HeaderRule{
+			{Action: conf.RemoveHeaderAction, Name: "Server"},
+			{Action: conf.RemoveHeaderAction, Name: "X-Debug-*"},
+			{Action: conf.SetHeaderAction, Name: "X-Content-Type-Options", Value: "nosniff"},
+		},
+	}, {
+		PathPrefix: "/ws",
+		ResponseHeaderRules: []conf.HeaderRule{
+			{Action: conf.RemoveHeaderAction, Name: "Set-Cookie"},
+			{Action: conf.SetHeaderAction, Name: "X-Content-Type-Options", Value: "nosniff"},
+		},
+	}}
 
-	requestId := requestid.Next()
-	wsServer := etp.NewServer(etp.WithServerReadLimit(2048))
-	wsServer.On("hello", wsEventHandlerMock{
-		requestId: requestId,
-		require:   require,
-		t:         test.T(),
+	targetService := httpt.NewMock(test)
+	targetService.POST("/endpoint", func(w http.ResponseWriter, httpReq *http.Request) {
+		require.EqualValues("app-4", httpReq.Header.Get("X-Caller"))
+		require.EqualValues("value", httpReq.Header.Get("X-Modern"))
+		require.Empty(httpReq.Header.Get("X-Legacy"))
+		w.Header().Set("Server", "upstream")
+		w.Header().Set("X-Debug-Trace", "trace")
+		w.WriteHeader(http.StatusOK)
 	})
-
-	wsMux := http.NewServeMux()
-	wsMux.Handle("/service", http.HandlerFunc(func(writer http.ResponseWriter, r *http.Request) {
-		wsServer.ServeHTTP(writer, r)
+	targetUrl, err := url.Parse(targetService.BaseURL())
+	require.NoError(err)
+	wsTargetService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, httpReq *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, httpReq, http.Header{"Set-Cookie": {"upstream=1"}})
+		require.NoError(err)
+		_ = conn.Close()
 	}))
-	targetService := httptest.NewServer(wsMux)
-	targetUrl, err := url.Parse(targetService.URL)
+	defer wsTargetService.Close()
+	wsTargetUrl, err := url.Parse(wsTargetService.URL)
 	require.NoError(err)
-	rr := lb.NewRoundRobin([]string{targetUrl.Host})
-	targetClients := map[string]*lb.RoundRobin{"target": rr}
+	targetClients := map[string]*lb.RoundRobin{
+		"target":   lb.NewRoundRobin([]string{targetUrl.Host}),
+		"wsTarget": lb.NewRoundRobin([]string{wsTargetUrl.Host}),
+	}
 
 	routes := routes.NewRoutes(test.Logger())
 	err = routes.ReceiveRoutes(s.T().Context(), cluster.RoutingConfig{{
 		Endpoints: []cluster.EndpointDescriptor{{
+			Path: "/endpoint",
+		}, {
 			Path: "/service",
 		}},
 	}})
 	require.NoError(err)
 
-	locator := assembly.NewLocator(test.Logger(), nil, targetClients, routes, systemCli, adminCli, nil, nil, nil)
+	locator := assembly.NewLocator(assembly.LocatorDeps{
+		Logger:           test.Logger(),
+		HttpHostManagers: targetClients,
+		Routes:           routes,
+		SystemCli:        systemCli,
+		AdminCli:         adminCli,
+		AppAuthCache:     cache.New(),
+		AppSecretCache:   cache.New(),
+		RevocationCache:  cache.New(),
+		AdminCache:       cache.New(),
+	})
 	locations := []conf.Location{{
-		SkipAuth:     false,
+		PathPrefix:   "/api",
+		Protocol:     "http",
+		TargetModule: "target",
+	}, {
 		PathPrefix:   "/ws",
 		Protocol:     "ws",
-		TargetModule: "target",
+		TargetModule: "wsTarget",
 	}}
 	handler, err := locator.Handler(config, locations)
 	require.NoError(err)
 	srv := httptest.NewServer(handler)
 
-	cli := etp.NewClient(etp.WithClientDialOptions(&etp.DialOptions{
-		HTTPHeader: map[string][]string{
-			"x-request-id": {requestId},
-		},
-	}))
-	requestUrl, err := url.Parse(srv.URL)
+	wsUrl := strings.Replace(srv.URL, "http://", "ws://", 1) + "/ws/service?x-application-token=token"
+	conn, wsResp, err := websocket.DefaultDialer.DialContext(s.T().Context(), wsUrl, nil)
 	require.NoError(err)
-	requestUrl.Path = "ws/service"
-	requestUrl.RawQuery = url.Values{
-		"x-application-token": []string{"token"},
-		"x-auth-admin":        []string{"mock-token"},
-	}.Encode()
-	err = cli.Dial(context.Background(), requestUrl.String())
-	require.NoError(err)
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
-	defer cancel()
-	resp, err := cli.EmitWithAck(ctx, "hello", []byte("data"))
-	require.NoError(err)
-	require.EqualValues("world", string(resp))
-	err = cli.Close()
-	require.NoError(err)
-}
-
-type wsEventHandlerMock struct {
-	requestId string
-	require   *require.Assertions
-	t         require.TestingT
-}
+	require.Empty(wsResp.Header.Get("Set-Cookie"))
+	require.EqualValues("nosniff", wsResp.Header.Get("X-Content-Type-Options"))
+	require.NoError(conn.Close())
 
-func (s wsEventHandlerMock) Handle(_ context.Context, conn *etp.Conn, event msg.Event) []byte {
-	headers := conn.HttpRequest().Header
-	ctx := requestid.ToContext(context.Background(), headers.Get("x-request-id"))
-	assertHeaders(s.require, s.requestId, ctx, headers)
-	require.EqualValues(s.t, "data", event.Data)
-	return []byte("world")
+	resp, err := httpcli.New().Post(srv.URL+"/api/endpoint").
+		Header("x-application-token", "token").
+		Header("X-Legacy", "value").
+		StatusCodeToError().
+		Do(s.T().Context())
+	require.NoError(err)
+	require.Empty(resp.Raw.Header.Get("Server"))
+	require.Empty(resp.Raw.Header.Get("X-Debug-Trace"))
+	require.EqualValues("nosniff", resp.Raw.Header.Get("X-Content-Type-Options"))
 }
 
-func (s *HappyPathTestSuite) TestAdminAuthorization() {
+func (s *HappyPathTestSuite) TestHttpProxy_IpAccess() {
 	test, require := test.New(s.T())
 	config, systemCli, adminCli := s.commonDependencies(test)
 
-	targetService, targetCli := grpct.NewMock(test)
-	targetService.Mock("endpoint", func(ctx context.Context, authData grpc.AuthData, req request) response {
-		return response{Id: req.Id}
+	targetService := httpt.NewMock(test)
+	targetService.POST("/endpoint", func(w http.ResponseWriter, httpReq *http.Request) {
+		w.WriteHeader(http.StatusOK)
 	})
-	targetClients := map[string]*client.Client{"target": targetCli}
-	logger, err := log.New(log.WithLevel(log.DebugLevel))
+	targetUrl, err := url.Parse(targetService.BaseURL())
+	require.NoError(err)
+	targetClients := map[string]*lb.RoundRobin{"target": lb.NewRoundRobin([]string{targetUrl.Host})}
+
+	routes := routes.NewRoutes(test.Logger())
+	err = routes.ReceiveRoutes(s.T().Context(), cluster.RoutingConfig{{
+		Endpoints: []cluster.EndpointDescriptor{{
+			Path: "/endpoint",
+		}},
+	}})
 	require.NoError(err)
-	routes := routes.NewRoutes(logger)
-	locator := assembly.NewLocator(logger, targetClients, nil, routes, systemCli, adminCli, nil, nil, nil)
 
+	locator := assembly.NewLocator(assembly.LocatorDeps{
+		Logger:           test.Logger(),
+		HttpHostManagers: targetClients,
+		Routes:           routes,
+		SystemCli:        systemCli,
+		AdminCli:         adminCli,
+		AppAuthCache:     cache.New(),
+		AppSecretCache:   cache.New(),
+		RevocationCache:  cache.New(),
+		AdminCache:       cache.New(),
+	})
 	locations := []conf.Location{{
-		SkipAuth:     false,
 		PathPrefix:   "/api",
-		Protocol:     "grpc",
+		Protocol:     "http",
 		TargetModule: "target",
 	}}
-	handler, err := locator.Handler(config, locations)
-	require.NoError(err)
+	call := func(config conf.Remote) int {
+		handler, err := locator.Handler(config, locations)
+		require.NoError(err)
+		srv := httptest.NewServer(handler)
+		defer srv.Close()
 
-	err = routes.ReceiveRoutes(context.Background(), cluster.RoutingConfig{{
-		ModuleName: "target",
-		Endpoints: []cluster.EndpointDescriptor{{
-			Path:  "endpoint",
-			Inner: true,
-			Extra: cluster.RequireAdminPermission("ok_permission"),
-		}, {
-			Path:  "failed_endpoint",
-			Inner: true,
-			Extra: cluster.RequireAdminPermission("failed_permission"),
-		}},
-	}})
-	require.NoError(err)
+		resp, err := httpcli.New().Post(srv.URL+"/api/endpoint").
+			Header("x-application-token", "token").
+			Do(s.T().Context())
+		require.NoError(err)
+		return resp.StatusCode()
+	}
 
-	srv := httptest.NewServer(handler)
-	cli := httpcli.New()
-	req := request{Id: uuid.New().String()}
-	resp := response{}
-	err = cli.Post(srv.URL+"/api/endpoint").
-		Header("x-application-token", "token").
-		Header("x-auth-admin", "mock-token").
-		JsonRequestBody(req).
-		JsonResponseBody(&resp).
-		StatusCodeToError().
-		DoWithoutResponse(s.T().Context())
-	require.NoError(err)
-	require.EqualValues(req.Id, resp.Id)
+	require.EqualValues(http.StatusOK, call(config))
 
-	err = cli.Post(srv.URL+"/api/failed_endpoint").
-		Header("x-application-token", "token").
-		Header("x-auth-admin", "mock-token").
-		JsonRequestBody(req).
-		JsonResponseBody(&resp).
-		StatusCodeToError().
-		DoWithoutResponse(s.T().Context())
-	errResp := httpcli.ErrorResponse{}
-	require.ErrorAs(err, &errResp)
-	require.EqualValues(http.StatusForbidden, errResp.StatusCode)
+	config.ApplicationIpRules = []conf.ApplicationIpRule{{
+		ApplicationId: 4,
+		Allow:         []string{"10.0.0.0/8"},
+	}}
+	require.EqualValues(http.StatusForbidden, call(config))
+
+	config.ApplicationIpRules = []conf.ApplicationIpRule{{
+		ApplicationId: 4,
+		Allow:         []string{"127.0.0.0/8"},
+	}}
+	config.LocationSettings = []conf.LocationSetting{{
+		PathPrefix: "/api",
+		IpRule:     &conf.IpRule{Deny: []string{"127.0.0.1"}},
+	}}
+	require.EqualValues(http.StatusForbidden, call(config))
+
+	config.LocationSettings[0].IpRule = &conf.IpRule{Allow: []string{"127.0.0.1", "::1"}}
+	require.EqualValues(http.StatusOK, call(config))
 }
 
-func (s *HappyPathTestSuite) TestUserAuthorization() { // nolint:funlen
+func (s *HappyPathTestSuite) TestHttpProxy_SignedRequest() { // nolint:funlen
 	test, require := test.New(s.T())
-	config, systemCli, adminCli := s.commonDependencies(test)
-	userAuthData := fake.It[entity.UserAuthData]()
-	userAuthData.IdentityHeader = "x-" + userAuthData.IdentityHeader
-
-	targetService, targetCli := grpct.NewMock(test)
-	targetService.Mock("endpoint", func(ctx context.Context, authData grpc.AuthData, req request) response {
-		md, ok := metadata.FromIncomingContext(ctx)
-		require.True(ok)
-		require.ElementsMatch([]string{userAuthData.Identity}, md.Get(userAuthData.IdentityHeader))
+	config, _, adminCli := s.commonDependencies(test)
+	config.RequestSigning = conf.RequestSigning{
+		Enable:        true,
+		SignedHeaders: []string{"Content-Type"},
+	}
 
-		return response{Id: req.Id}
+	systemService, systemCli := grpct.NewMock(test)
+	systemService.Mock("system/secure/get_application_secret", func(req entity.ApplicationSecretRequest) entity.ApplicationSecretResponse {
+		if req.ApplicationId != 4 {
+			return entity.ApplicationSecretResponse{Found: false}
+		}
+		return entity.ApplicationSecretResponse{
+			Found:  true,
+			Secret: "secret",
+			AuthData: &entity.AppAuthData{
+				SystemId:      1,
+				DomainId:      2,
+				ServiceId:     3,
+				ApplicationId: 4,
+				AppName:       "test",
+			},
+		}
+	}).Mock("system/secure/authorize", func() entity.AuthorizeResponse {
+		return entity.AuthorizeResponse{Authorized: true}
 	})
-	targetService.Mock("failed_auth_endpoint", func(ctx context.Context, authData grpc.AuthData, req request) response {
+
+	targetService := httpt.NewMock(test)
+	targetService.POST("/endpoint", func(ctx context.Context, httpReq *http.Request, req request) response {
+		require.EqualValues("4", httpReq.Header.Get("x-application-identity"))
+		return response{Id: req.Id}
+	})
+	targetUrl, err := url.Parse(targetService.BaseURL())
+	require.NoError(err)
+	targetClients := map[string]*lb.RoundRobin{"target": lb.NewRoundRobin([]string{targetUrl.Host})}
+
+	routes := routes.NewRoutes(test.Logger())
+	err = routes.ReceiveRoutes(s.T().Context(), cluster.RoutingConfig{{
+		Endpoints: []cluster.EndpointDescriptor{{
+			Path: "/endpoint",
+		}},
+	}})
+	require.NoError(err)
+
+	lock := sync.Mutex{}
+	nonces := make(map[string]bool)
+	lockService, lockerCli := grpct.NewMock(test)
+	lockService.Mock("isp-lock-service/set_if_absent", func(req entity.SetIfAbsentRequest) entity.SetIfAbsentResponse {
+		lock.Lock()
+		defer lock.Unlock()
+		if nonces[req.Key] {
+			return entity.SetIfAbsentResponse{Set: false}
+		}
+		nonces[req.Key] = true
+		return entity.SetIfAbsentResponse{Set: true}
+	})
+
+	// реплики шлюза с общим isp-lock-service
+	newReplica := func() *httptest.Server {
+		locator := assembly.NewLocator(assembly.LocatorDeps{
+			Logger:           test.Logger(),
+			HttpHostManagers: targetClients,
+			Routes:           routes,
+			SystemCli:        systemCli,
+			AdminCli:         adminCli,
+			LockerCli:        lockerCli,
+			AppAuthCache:     cache.New(),
+			AppSecretCache:   cache.New(),
+			RevocationCache:  cache.New(),
+			AdminCache:       cache.New(),
+		})
+		handler, err := locator.Handler(config, []conf.Location{{
+			PathPrefix:   "/api",
+			Protocol:     "http",
+			TargetModule: "target",
+		}})
+		require.NoError(err)
+		return httptest.NewServer(handler)
+	}
+	srv := newReplica()
+	otherSrv := newReplica()
+
+	body := []byte(`{"id":"signed"}`)
+	call := func(srv *httptest.Server, applicationId int, nonce string, secret string) int {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		bodyHash := sha256.Sum256(body)
+		stringToSign := strings.Join([]string{
+			http.MethodPost,
+			"/api/endpoint",
+			"",
+			timestamp,
+			nonce,
+			"content-type:application/json",
+			hex.EncodeToString(bodyHash[:]),
+		}, "\n")
+		mac := hmac.New(sha256.New, []byte(secret))
+		_, _ = mac.Write([]byte(stringToSign))
+
+		resp, err := httpcli.New().Post(srv.URL+"/api/endpoint").
+			Header("Content-Type", "application/json").
+			Header("x-application-id", strconv.Itoa(applicationId)).
+			Header("x-request-timestamp", timestamp).
+			Header("x-request-nonce", nonce).
+			Header("x-request-signature", hex.EncodeToString(mac.Sum(nil))).
+			RequestBody(body).
+			Do(s.T().Context())
+		require.NoError(err)
+		return resp.StatusCode()
+	}
+
+	require.EqualValues(http.StatusOK, call(srv, 4, "nonce-1", "secret"))
+	require.EqualValues(http.StatusUnauthorized, call(srv, 4, "nonce-1", "secret"))
+	require.EqualValues(http.StatusUnauthorized, call(otherSrv, 4, "nonce-1", "secret"))
+	require.EqualValues(http.StatusOK, call(otherSrv, 4, "nonce-2", "secret"))
+	require.EqualValues(http.StatusUnauthorized, call(srv, 4, "nonce-3", "wrong"))
+	require.EqualValues(http.StatusUnauthorized, call(srv, 5, "nonce-4", "secret"))
+}
+
+func (s *HappyPathTestSuite) TestHttpProxy_TokenExtraction() {
+	test, require := test.New(s.T())
+	config, systemCli, adminCli := s.commonDependencies(test)
+	config.CustomAuth.TokenProviders = []conf.TokenProvider{{
+		Name: "bearer",
+		Type: conf.BearerTokenProviderType,
+	}}
+	config.TokenExtraction = conf.TokenExtraction{ForbidQueryTokens: true}
+	config.LocationSettings = []conf.LocationSetting{{
+		PathPrefix: "/bearer",
+		TokenExtraction: &conf.TokenExtraction{
+			ApplicationTokenProviders: []string{"bearer"},
+		},
+	}}
+
+	targetService := httpt.NewMock(test)
+	targetService.POST("/endpoint", func(w http.ResponseWriter, httpReq *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	targetUrl, err := url.Parse(targetService.BaseURL())
+	require.NoError(err)
+	targetClients := map[string]*lb.RoundRobin{"target": lb.NewRoundRobin([]string{targetUrl.Host})}
+
+	routes := routes.NewRoutes(test.Logger())
+	err = routes.ReceiveRoutes(s.T().Context(), cluster.RoutingConfig{{
+		Endpoints: []cluster.EndpointDescriptor{{
+			Path: "/endpoint",
+		}},
+	}})
+	require.NoError(err)
+
+	locator := assembly.NewLocator(assembly.LocatorDeps{
+		Logger:           test.Logger(),
+		HttpHostManagers: targetClients,
+		Routes:           routes,
+		SystemCli:        systemCli,
+		AdminCli:         adminCli,
+		AppAuthCache:     cache.New(),
+		AppSecretCache:   cache.New(),
+		RevocationCache:  cache.New(),
+		AdminCache:       cache.New(),
+	})
+	locations := []conf.Location{{
+		PathPrefix:   "/api",
+		Protocol:     "http",
+		TargetModule: "target",
+	}, {
+		PathPrefix:   "/bearer",
+		Protocol:     "http",
+		TargetModule: "target",
+	}}
+	handler, err := locator.Handler(config, locations)
+	require.NoError(err)
+	srv := httptest.NewServer(handler)
+
+	call := func(path string, header string, value string) int {
+		resp, err := httpcli.New().Post(srv.URL+path).
+			Header(header, value).
+			Do(s.T().Context())
+		require.NoError(err)
+		return resp.StatusCode()
+	}
+
+	require.EqualValues(http.StatusOK, call("/api/endpoint", "x-application-token", "token"))
+	require.EqualValues(http.StatusUnauthorized, call("/api/endpoint?x-application-token=token", "x-request-id", requestid.Next()))
+	require.EqualValues(http.StatusOK, call("/bearer/endpoint", "Authorization", "Bearer token"))
+	require.EqualValues(http.StatusUnauthorized, call("/bearer/endpoint", "x-application-token", "token"))
+}
+
+func (s *HappyPathTestSuite) TestHttpProxy_StripCredentials() {
+	test, require := test.New(s.T())
+	config, systemCli, adminCli := s.commonDependencies(test)
+	config.LocationSettings = []conf.LocationSetting{{
+		PathPrefix:         "/legacy",
+		ForwardCredentials: true,
+	}}
+
+	targetService := httpt.NewMock(test)
+	targetService.POST("/endpoint", func(w http.ResponseWriter, httpReq *http.Request) {
+		forwarded := httpReq.Header.Get("x-forwarded-credentials") == "true"
+		require.EqualValues(forwarded, httpReq.Header.Get("x-application-token") != "")
+		require.EqualValues(forwarded, httpReq.URL.Query().Get("x-application-token") != "")
+		require.EqualValues(forwarded, httpReq.URL.Query().Get("x-auth-admin") != "")
+		require.EqualValues("Bearer upstream", httpReq.Header.Get("Authorization"))
+		require.EqualValues("value", httpReq.URL.Query().Get("other"))
+		if !forwarded {
+			require.EqualValues("b=2&a=%2F+x&other=value", httpReq.URL.RawQuery)
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	targetUrl, err := url.Parse(targetService.BaseURL())
+	require.NoError(err)
+	targetClients := map[string]*lb.RoundRobin{"target": lb.NewRoundRobin([]string{targetUrl.Host})}
+
+	routes := routes.NewRoutes(test.Logger())
+	err = routes.ReceiveRoutes(s.T().Context(), cluster.RoutingConfig{{
+		Endpoints: []cluster.EndpointDescriptor{{
+			Path: "/endpoint",
+		}},
+	}})
+	require.NoError(err)
+
+	locator := assembly.NewLocator(assembly.LocatorDeps{
+		Logger:           test.Logger(),
+		HttpHostManagers: targetClients,
+		Routes:           routes,
+		SystemCli:        systemCli,
+		AdminCli:         adminCli,
+		AppAuthCache:     cache.New(),
+		AppSecretCache:   cache.New(),
+		RevocationCache:  cache.New(),
+		AdminCache:       cache.New(),
+	})
+	locations := []conf.Location{{
+		PathPrefix:   "/api",
+		Protocol:     "http",
+		TargetModule: "target",
+	}, {
+		PathPrefix:   "/legacy",
+		Protocol:     "http",
+		TargetModule: "target",
+	}}
+	handler, err := locator.Handler(config, locations)
+	require.NoError(err)
+	srv := httptest.NewServer(handler)
+
+	for _, prefix := range []string{"/api", "/legacy"} {
+		// копия токена приложения в query удаляется вместе с использованным заголовком
+		_, err = httpcli.New().Post(srv.URL+prefix+"/endpoint?b=2&x-auth-admin=mock-token&a=%2F+x&x-application-token=token&other=value").
+			Header("x-application-token", "token").
+			Header("Authorization", "Bearer upstream").
+			Header("x-forwarded-credentials", strconv.FormatBool(prefix == "/legacy")).
+			StatusCodeToError().
+			Do(s.T().Context())
+		require.NoError(err)
+	}
+}
+
+func (s *HappyPathTestSuite) TestHttpProxy_InternalToken() {
+	test, require := test.New(s.T())
+	config, systemCli, adminCli := s.commonDependencies(test)
+	config.InternalToken = conf.InternalToken{Enable: true}
+
+	_, privateKey, err := ed25519.GenerateKey(nil)
+	require.NoError(err)
+	signer, err := internaltoken.NewSigner(privateKey, "test")
+	require.NoError(err)
+
+	targetService := httpt.NewMock(test)
+	targetService.POST("/endpoint", func(w http.ResponseWriter, httpReq *http.Request) {
+		parts := strings.Split(httpReq.Header.Get("x-gate-token"), ".")
+		require.Len(parts, 3)
+		signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+		require.NoError(err)
+		require.True(ed25519.Verify(privateKey.Public().(ed25519.PublicKey), []byte(parts[0]+"."+parts[1]), signature))
+
+		payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+		require.NoError(err)
+		claims := internaltoken.Claims{}
+		require.NoError(json.Unmarshal(payload, &claims))
+		require.EqualValues(4, claims.Application.Id)
+		require.EqualValues(1, claims.Admin.Id)
+		require.EqualValues("/endpoint", claims.Endpoint)
+		require.EqualValues(httpReq.Header.Get("x-request-id"), claims.RequestId)
+		require.EqualValues("isp-gate-service", claims.Issuer)
+		require.EqualValues("target", claims.Audience)
+		require.Greater(claims.ExpiresAt, time.Now().Unix())
+		w.WriteHeader(http.StatusOK)
+	})
+	targetUrl, err := url.Parse(targetService.BaseURL())
+	require.NoError(err)
+	targetClients := map[string]*lb.RoundRobin{"target": lb.NewRoundRobin([]string{targetUrl.Host})}
+
+	routes := routes.NewRoutes(test.Logger())
+	err = routes.ReceiveRoutes(s.T().Context(), cluster.RoutingConfig{{
+		Endpoints: []cluster.EndpointDescriptor{{
+			Path: "/endpoint",
+		}},
+	}})
+	require.NoError(err)
+
+	locator := assembly.NewLocator(assembly.LocatorDeps{
+		Logger:              test.Logger(),
+		HttpHostManagers:    targetClients,
+		Routes:              routes,
+		SystemCli:           systemCli,
+		AdminCli:            adminCli,
+		AppAuthCache:        cache.New(),
+		AppSecretCache:      cache.New(),
+		RevocationCache:     cache.New(),
+		AdminCache:          cache.New(),
+		InternalTokenSigner: signer,
+	})
+	locations := []conf.Location{{
+		PathPrefix:   "/api",
+		Protocol:     "http",
+		TargetModule: "target",
+	}}
+	handler, err := locator.Handler(config, locations)
+	require.NoError(err)
+	srv := httptest.NewServer(handler)
+
+	_, err = httpcli.New().Post(srv.URL+"/api/endpoint").
+		Header("x-application-token", "token").
+		Header("x-auth-admin", "mock-token").
+		Header("x-gate-token", "spoofed").
+		StatusCodeToError().
+		Do(s.T().Context())
+	require.NoError(err)
+}
+
+func (s *HappyPathTestSuite) TestHttpProxy_InternalTokenStripping() {
+	test, require := test.New(s.T())
+	config, systemCli, adminCli := s.commonDependencies(test)
+
+	targetService := httpt.NewMock(test)
+	targetService.POST("/endpoint", func(w http.ResponseWriter, httpReq *http.Request) {
+		require.Empty(httpReq.Header.Get("x-gate-token"))
+		w.WriteHeader(http.StatusOK)
+	})
+	targetUrl, err := url.Parse(targetService.BaseURL())
+	require.NoError(err)
+	targetClients := map[string]*lb.RoundRobin{"target": lb.NewRoundRobin([]string{targetUrl.Host})}
+
+	routes := routes.NewRoutes(test.Logger())
+	err = routes.ReceiveRoutes(s.T().Context(), cluster.RoutingConfig{{
+		Endpoints: []cluster.EndpointDescriptor{{
+			Path: "/endpoint",
+		}},
+	}})
+	require.NoError(err)
+
+	locator := assembly.NewLocator(assembly.LocatorDeps{
+		Logger:           test.Logger(),
+		HttpHostManagers: targetClients,
+		Routes:           routes,
+		SystemCli:        systemCli,
+		AdminCli:         adminCli,
+		AppAuthCache:     cache.New(),
+		AppSecretCache:   cache.New(),
+		RevocationCache:  cache.New(),
+		AdminCache:       cache.New(),
+	})
+	locations := []conf.Location{{
+		PathPrefix:   "/api",
+		Protocol:     "http",
+		TargetModule: "target",
+	}, {
+		PathPrefix:   "/public",
+		Protocol:     "http",
+		TargetModule: "target",
+		SkipAuth:     true,
+	}}
+	handler, err := locator.Handler(config, locations)
+	require.NoError(err)
+	srv := httptest.NewServer(handler)
+
+	for _, prefix := range []string{"/api", "/public"} {
+		_, err = httpcli.New().Post(srv.URL+prefix+"/endpoint").
+			Header("x-application-token", "token").
+			Header("x-gate-token", "spoofed").
+			StatusCodeToError().
+			Do(s.T().Context())
+		require.NoError(err)
+	}
+}
+
+func (s *HappyPathTestSuite) TestHttpProxy_Cors() {
+	test, require := test.New(s.T())
+	config, systemCli, adminCli := s.commonDependencies(test)
+	config.LocationSettings = []conf.LocationSetting{{
+		PathPrefix: "/api",
+		Cors: &conf.Cors{
+			AllowedOrigins:   []string{"https://*.example.com"},
+			ExposedHeaders:   []string{"X-Request-Id"},
+			AllowCredentials: true,
+			MaxAgeInSec:      600,
+		},
+	}}
+
+	targetService := httpt.NewMock(test)
+	targetService.POST("/endpoint", func(w http.ResponseWriter, httpReq *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.WriteHeader(http.StatusOK)
+	})
+	targetUrl, err := url.Parse(targetService.BaseURL())
+	require.NoError(err)
+	targetClients := map[string]*lb.RoundRobin{"target": lb.NewRoundRobin([]string{targetUrl.Host})}
+
+	routes := routes.NewRoutes(test.Logger())
+	err = routes.ReceiveRoutes(s.T().Context(), cluster.RoutingConfig{{
+		Endpoints: []cluster.EndpointDescriptor{{
+			Path:       "/endpoint",
+			HttpMethod: http.MethodPost,
+		}},
+	}})
+	require.NoError(err)
+
+	locator := assembly.NewLocator(assembly.LocatorDeps{
+		Logger:           test.Logger(),
+		HttpHostManagers: targetClients,
+		Routes:           routes,
+		SystemCli:        systemCli,
+		AdminCli:         adminCli,
+		AppAuthCache:     cache.New(),
+		AppSecretCache:   cache.New(),
+		RevocationCache:  cache.New(),
+		AdminCache:       cache.New(),
+	})
+	locations := []conf.Location{{
+		PathPrefix:   "/api",
+		Protocol:     "http",
+		TargetModule: "target",
+	}}
+	handler, err := locator.Handler(config, locations)
+	require.NoError(err)
+	srv := httptest.NewServer(handler)
+	cli := httpcli.New()
+
+	preflightReq, err := http.NewRequestWithContext(s.T().Context(), http.MethodOptions, srv.URL+"/api/endpoint", nil)
+	require.NoError(err)
+	preflightReq.Header.Set("Origin", "https://app.example.com")
+	preflightReq.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	preflightReq.Header.Set("Access-Control-Request-Headers", "x-application-token")
+	preflightResp, err := http.DefaultClient.Do(preflightReq)
+	require.NoError(err)
+	defer preflightResp.Body.Close()
+	require.EqualValues(http.StatusNoContent, preflightResp.StatusCode)
+	require.EqualValues("https://app.example.com", preflightResp.Header.Get("Access-Control-Allow-Origin"))
+	require.EqualValues("true", preflightResp.Header.Get("Access-Control-Allow-Credentials"))
+	require.EqualValues("x-application-token", preflightResp.Header.Get("Access-Control-Allow-Headers"))
+	require.EqualValues("600", preflightResp.Header.Get("Access-Control-Max-Age"))
+
+	resp, err := cli.Post(srv.URL+"/api/endpoint").
+		Header("x-application-token", "token").
+		Header("Origin", "https://app.example.com").
+		StatusCodeToError().
+		Do(s.T().Context())
+	require.NoError(err)
+	require.EqualValues([]string{"https://app.example.com"}, resp.Raw.Header.Values("Access-Control-Allow-Origin"))
+	require.EqualValues("X-Request-Id", resp.Raw.Header.Get("Access-Control-Expose-Headers"))
+
+	resp, err = cli.Post(srv.URL+"/api/endpoint").
+		Header("x-application-token", "token").
+		Header("Origin", "https://evil.com").
+		StatusCodeToError().
+		Do(s.T().Context())
+	require.NoError(err)
+	require.Empty(resp.Raw.Header.Get("Access-Control-Allow-Origin"))
+	require.Empty(resp.Raw.Header.Get("Access-Control-Expose-Headers"))
+	require.Contains(resp.Raw.Header.Values("Vary"), "Origin")
+
+	config.LocationSettings[0].Cors.AllowedOrigins = []string{"*"}
+	_, err = locator.Handler(config, locations)
+	require.Error(err)
+}
+
+func (s *HappyPathTestSuite) TestWsProxy() { // nolint:funlen
+	test, require := test.New(s.T())
+	config, systemCli, adminCli := s.commonDependencies(test)
+
+	requestId := requestid.Next()
+	wsServer := etp.NewServer(etp.WithServerReadLimit(2048))
+	wsServer.On("hello", wsEventHandlerMock{
+		requestId: requestId,
+		require:   require,
+		t:         test.T(),
+	})
+
+	wsMux := http.NewServeMux()
+	wsMux.Handle("/service", http.HandlerFunc(func(writer http.ResponseWriter, r *http.Request) {
+		wsServer.ServeHTTP(writer, r)
+	}))
+	targetService := httptest.NewServer(wsMux)
+	targetUrl, err := url.Parse(targetService.URL)
+	require.NoError(err)
+	rr := lb.NewRoundRobin([]string{targetUrl.Host})
+	targetClients := map[string]*lb.RoundRobin{"target": rr}
+
+	routes := routes.NewRoutes(test.Logger())
+	err = routes.ReceiveRoutes(s.T().Context(), cluster.RoutingConfig{{
+		Endpoints: []cluster.EndpointDescriptor{{
+			Path: "/service",
+		}},
+	}})
+	require.NoError(err)
+
+	locator := assembly.NewLocator(assembly.LocatorDeps{
+		Logger:           test.Logger(),
+		HttpHostManagers: targetClients,
+		Routes:           routes,
+		SystemCli:        systemCli,
+		AdminCli:         adminCli,
+		AppAuthCache:     cache.New(),
+		AppSecretCache:   cache.New(),
+		RevocationCache:  cache.New(),
+		AdminCache:       cache.New(),
+	})
+	locations := []conf.Location{{
+		SkipAuth:     false,
+		PathPrefix:   "/ws",
+		Protocol:     "ws",
+		TargetModule: "target",
+	}}
+	handler, err := locator.Handler(config, locations)
+	require.NoError(err)
+	srv := httptest.NewServer(handler)
+
+	cli := etp.NewClient(etp.WithClientDialOptions(&etp.DialOptions{
+		HTTPHeader: map[string][]string{
+			"x-request-id": {requestId},
+		},
+	}))
+	requestUrl, err := url.Parse(srv.URL)
+	require.NoError(err)
+	requestUrl.Path = "ws/service"
+	requestUrl.RawQuery = url.Values{
+		"x-application-token": []string{"token"},
+		"x-auth-admin":        []string{"mock-token"},
+	}.Encode()
+	err = cli.Dial(context.Background(), requestUrl.String())
+	require.NoError(err)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
+	defer cancel()
+	resp, err := cli.EmitWithAck(ctx, "hello", []byte("data"))
+	require.NoError(err)
+	require.EqualValues("world", string(resp))
+	err = cli.Close()
+	require.NoError(err)
+}
+
+func (s *HappyPathTestSuite) TestWsProxy_Handshake() { // nolint:funlen
+	test, require := test.New(s.T())
+	config, systemCli, adminCli := s.commonDependencies(test)
+
+	wsMux := http.NewServeMux()
+	wsMux.HandleFunc("/echo", func(w http.ResponseWriter, httpReq *http.Request) {
+		require.EqualValues("session=1", httpReq.Header.Get("Cookie"))
+		upgrader := &websocket.Upgrader{Subprotocols: []string{"v2"}}
+		conn, err := upgrader.Upgrade(w, httpReq, http.Header{"Set-Cookie": {"upstream=1"}})
+		require.NoError(err)
+		defer conn.Close()
+		messageType, message, err := conn.ReadMessage()
+		require.NoError(err)
+		err = conn.WriteMessage(messageType, message)
+		require.NoError(err)
+		closeMessage := websocket.FormatCloseMessage(websocket.CloseGoingAway, "bye")
+		_ = conn.WriteMessage(websocket.CloseMessage, closeMessage)
+	})
+	wsMux.HandleFunc("/forbidden", func(w http.ResponseWriter, httpReq *http.Request) {
+		http.Error(w, "forbidden by upstream", http.StatusForbidden)
+	})
+	wsTarget := httptest.NewServer(wsMux)
+	defer wsTarget.Close()
+	wsTargetUrl, err := url.Parse(wsTarget.URL)
+	require.NoError(err)
+	downTarget := httptest.NewServer(http.NotFoundHandler())
+	downTargetUrl, err := url.Parse(downTarget.URL)
+	require.NoError(err)
+	downTarget.Close()
+
+	routes := routes.NewRoutes(test.Logger())
+	err = routes.ReceiveRoutes(s.T().Context(), cluster.RoutingConfig{{
+		Endpoints: []cluster.EndpointDescriptor{{
+			Path: "/echo",
+		}, {
+			Path: "/forbidden",
+		}},
+	}})
+	require.NoError(err)
+
+	locator := assembly.NewLocator(assembly.LocatorDeps{
+		Logger: test.Logger(),
+		HttpHostManagers: map[string]*lb.RoundRobin{
+			"target": lb.NewRoundRobin([]string{wsTargetUrl.Host}),
+			"down":   lb.NewRoundRobin([]string{downTargetUrl.Host}),
+		},
+		Routes:          routes,
+		SystemCli:       systemCli,
+		AdminCli:        adminCli,
+		AppAuthCache:    cache.New(),
+		AppSecretCache:  cache.New(),
+		RevocationCache: cache.New(),
+		AdminCache:      cache.New(),
+	})
+	handler, err := locator.Handler(config, []conf.Location{{
+		PathPrefix:   "/ws",
+		Protocol:     "ws",
+		TargetModule: "target",
+	}, {
+		PathPrefix:   "/down",
+		Protocol:     "ws",
+		TargetModule: "down",
+	}})
+	require.NoError(err)
+	srv := httptest.NewServer(handler)
+	wsUrl := strings.Replace(srv.URL, "http://", "ws://", 1)
+
+	dialer := &websocket.Dialer{Subprotocols: []string{"v1", "v2"}}
+	conn, wsResp, err := dialer.DialContext(s.T().Context(), wsUrl+"/ws/echo?x-application-token=token", http.Header{
+		"Cookie": {"session=1"},
+	})
+	require.NoError(err)
+	require.EqualValues("v2", conn.Subprotocol())
+	require.EqualValues("upstream=1", wsResp.Header.Get("Set-Cookie"))
+	err = conn.WriteMessage(websocket.TextMessage, []byte("hello"))
+	require.NoError(err)
+	_, message, err := conn.ReadMessage()
+	require.NoError(err)
+	require.EqualValues("hello", string(message))
+	_, _, err = conn.ReadMessage()
+	require.True(websocket.IsCloseError(err, websocket.CloseGoingAway))
+	require.NoError(conn.Close())
+
+	// ответ upstream на неудачный handshake передается клиенту как есть
+	_, wsResp, err = websocket.DefaultDialer.DialContext(s.T().Context(), wsUrl+"/ws/forbidden?x-application-token=token", nil)
+	require.ErrorIs(err, websocket.ErrBadHandshake)
+	require.EqualValues(http.StatusForbidden, wsResp.StatusCode)
+	body, err := io.ReadAll(wsResp.Body)
+	require.NoError(err)
+	require.Contains(string(body), "forbidden by upstream")
+
+	_, wsResp, err = websocket.DefaultDialer.DialContext(s.T().Context(), wsUrl+"/down/echo?x-application-token=token", nil)
+	require.ErrorIs(err, websocket.ErrBadHandshake)
+	require.EqualValues(http.StatusServiceUnavailable, wsResp.StatusCode)
+}
+
+type wsEventHandlerMock struct {
+	requestId string
+	require   *require.Assertions
+	t         require.TestingT
+}
+
+func (s wsEventHandlerMock) Handle(_ context.Context, conn *etp.Conn, event msg.Event) []byte {
+	headers := conn.HttpRequest().Header
+	ctx := requestid.ToContext(context.Background(), headers.Get("x-request-id"))
+	assertHeaders(s.require, s.requestId, ctx, headers)
+	require.EqualValues(s.t, "data", event.Data)
+	return []byte("world")
+}
+
+func (s *HappyPathTestSuite) TestAdminAuthorization() {
+	test, require := test.New(s.T())
+	config, systemCli, adminCli := s.commonDependencies(test)
+
+	targetService, targetCli := grpct.NewMock(test)
+	targetService.Mock("endpoint", func(ctx context.Context, authData grpc.AuthData, req request) response {
+		return response{Id: req.Id}
+	})
+	targetClients := map[string]*client.Client{"target": targetCli}
+	logger, err := log.New(log.WithLevel(log.DebugLevel))
+	require.NoError(err)
+	routes := routes.NewRoutes(logger)
+	locator := assembly.NewLocator(assembly.LocatorDeps{
+		Logger:          logger,
+		GrpcClients:     targetClients,
+		Routes:          routes,
+		SystemCli:       systemCli,
+		AdminCli:        adminCli,
+		AppAuthCache:    cache.New(),
+		AppSecretCache:  cache.New(),
+		RevocationCache: cache.New(),
+		AdminCache:      cache.New(),
+	})
+
+	locations := []conf.Location{{
+		SkipAuth:     false,
+		PathPrefix:   "/api",
+		Protocol:     "grpc",
+		TargetModule: "target",
+	}}
+	handler, err := locator.Handler(config, locations)
+	require.NoError(err)
+
+	err = routes.ReceiveRoutes(context.Background(), cluster.RoutingConfig{{
+		ModuleName: "target",
+		Endpoints: []cluster.EndpointDescriptor{{
+			Path:  "endpoint",
+			Inner: true,
+			Extra: cluster.RequireAdminPermission("ok_permission"),
+		}, {
+			Path:  "failed_endpoint",
+			Inner: true,
+			Extra: cluster.RequireAdminPermission("failed_permission"),
+		}},
+	}})
+	require.NoError(err)
+
+	srv := httptest.NewServer(handler)
+	cli := httpcli.New()
+	req := request{Id: uuid.New().String()}
+	resp := response{}
+	err = cli.Post(srv.URL+"/api/endpoint").
+		Header("x-application-token", "token").
+		Header("x-auth-admin", "mock-token").
+		JsonRequestBody(req).
+		JsonResponseBody(&resp).
+		StatusCodeToError().
+		DoWithoutResponse(s.T().Context())
+	require.NoError(err)
+	require.EqualValues(req.Id, resp.Id)
+
+	err = cli.Post(srv.URL+"/api/failed_endpoint").
+		Header("x-application-token", "token").
+		Header("x-auth-admin", "mock-token").
+		JsonRequestBody(req).
+		JsonResponseBody(&resp).
+		StatusCodeToError().
+		DoWithoutResponse(s.T().Context())
+	errResp := httpcli.ErrorResponse{}
+	require.ErrorAs(err, &errResp)
+	require.EqualValues(http.StatusForbidden, errResp.StatusCode)
+}
+
+func (s *HappyPathTestSuite) TestUserAuthorization() { // nolint:funlen
+	test, require := test.New(s.T())
+	config, systemCli, adminCli := s.commonDependencies(test)
+	userAuthData := fake.It[entity.UserAuthData]()
+	userAuthData.IdentityHeader = "x-" + userAuthData.IdentityHeader
+
+	targetService, targetCli := grpct.NewMock(test)
+	targetService.Mock("endpoint", func(ctx context.Context, authData grpc.AuthData, req request) response {
+		md, ok := metadata.FromIncomingContext(ctx)
+		require.True(ok)
+		require.ElementsMatch([]string{userAuthData.Identity}, md.Get(userAuthData.IdentityHeader))
+
+		return response{Id: req.Id}
+	})
+	targetService.Mock("failed_auth_endpoint", func(ctx context.Context, authData grpc.AuthData, req request) response {
+		return response{Id: req.Id}
+	})
+
+	routerMux := router.New()
+	defaultWrapper := endpoint.DefaultWrapper(
+		test.Logger(),
+		httplog.Noop(),
+	)
+
+	userAuthToken := fake.It[string]()
+
+	routerMux.POST("/test-user-auth/authenticate",
+		defaultWrapper.Endpoint(func(req entity.UserAuthenticateRequest) entity.UserAuthenticateResponse {
+			require.Equal(userAuthToken, req.Token)
+			return entity.UserAuthenticateResponse{
+				Authenticated: true,
+				AuthData:      &userAuthData,
+			}
+		}))
+	routerMux.POST("/failed-user-auth/authenticate",
+		defaultWrapper.Endpoint(func(req entity.UserAuthenticateRequest) entity.UserAuthenticateResponse {
+			require.Equal(userAuthToken, req.Token)
+			return entity.UserAuthenticateResponse{
+				Authenticated: false,
+				ErrorReason:   "test fail",
+			}
+		}))
+	routerMock := httptest.NewServer(routerMux)
+	targetUrl, err := url.Parse(routerMock.URL)
+	require.NoError(err)
+	rr := lb.NewRoundRobin([]string{targetUrl.Host})
+
+	targetClients := map[string]*client.Client{"target": targetCli}
+	logger, err := log.New(log.WithLevel(log.DebugLevel))
+	require.NoError(err)
+	routes := routes.NewRoutes(logger)
+	locator := assembly.NewLocator(assembly.LocatorDeps{
+		Logger:          logger,
+		GrpcClients:     targetClients,
+		Routes:          routes,
+		SystemCli:       systemCli,
+		AdminCli:        adminCli,
+		RouterLb:        rr,
+		UsersAuthCache:  cache.New(),
+		AppAuthCache:    cache.New(),
+		AppSecretCache:  cache.New(),
+		RevocationCache: cache.New(),
+		AdminCache:      cache.New(),
+	})
+
+	locations := []conf.Location{{
+		SkipAuth:     false,
+		PathPrefix:   "/api",
+		Protocol:     "grpc",
+		TargetModule: "target",
+	}}
+	config.CustomAuth = conf.CustomAuth{
+		TokenProviders: []conf.TokenProvider{
+			{
+				Name: "test_provider",
+				Type: conf.HeaderTokenProviderType,
+				HeaderProvider: &conf.HeaderTokenProvider{
+					HeaderName: "test-token-header",
+				},
+			},
+		},
+		UserAuthSettings: []conf.UserAuthSetting{
+			{
+				ModuleNameList:       []string{"target"},
+				TokenProviders:       []string{"test_provider"},
+				AuthenticateEndpoint: "test-user-auth/authenticate",
+			},
+			{
+				ModuleNameList:       []string{"target2"},
+				TokenProviders:       []string{"test_provider"},
+				AuthenticateEndpoint: "failed-user-auth/authenticate",
+			},
+		},
+	}
+	handler, err := locator.Handler(config, locations)
+	require.NoError(err)
+
+	err = routes.ReceiveRoutes(context.Background(), cluster.RoutingConfig{
+		{
+			ModuleName: "target",
+			Endpoints: []cluster.EndpointDescriptor{{
+				Path:             "endpoint",
+				Inner:            true,
+				UserAuthRequired: true,
+			},
+			},
+		},
+		{
+			ModuleName: "target2",
+			Endpoints: []cluster.EndpointDescriptor{
+				{
+					Path:             "failed_auth_endpoint",
+					Inner:            true,
+					UserAuthRequired: true,
+				}, {
+					Path:             "failed_endpoint",
+					Inner:            true,
+					UserAuthRequired: true,
+				},
+			},
+		},
+	})
+	require.NoError(err)
+
+	srv := httptest.NewServer(handler)
+	cli := httpcli.New()
+	req := request{Id: uuid.New().String()}
+	resp := response{}
+	err = cli.Post(srv.URL+"/api/endpoint").
+		Header("x-application-token", "token").
+		Header("test-token-header", userAuthToken).
+		Header("x-auth-admin", "mock-token").
+		JsonRequestBody(req).
+		JsonResponseBody(&resp).
+		StatusCodeToError().
+		DoWithoutResponse(s.T().Context())
+	require.NoError(err)
+	require.EqualValues(req.Id, resp.Id)
+
+	err = cli.Post(srv.URL+"/api/failed_auth_endpoint").
+		Header("x-application-token", "token").
+		Header("test-token-header", userAuthToken).
+		Header("x-auth-admin", "mock-token").
+		JsonRequestBody(req).
+		JsonResponseBody(&resp).
+		StatusCodeToError().
+		DoWithoutResponse(s.T().Context())
+	errResp := httpcli.ErrorResponse{}
+	require.ErrorAs(err, &errResp)
+	require.EqualValues(http.StatusUnauthorized, errResp.StatusCode)
+}
+
+func (s *HappyPathTestSuite) TestUserAuthorization_Introspection() { // nolint:funlen
+	test, require := test.New(s.T())
+	config, systemCli, adminCli := s.commonDependencies(test)
+
+	targetService, targetCli := grpct.NewMock(test)
+	targetService.Mock("endpoint", func(ctx context.Context, authData grpc.AuthData, req request) response {
+		md, ok := metadata.FromIncomingContext(ctx)
+		require.True(ok)
+		require.ElementsMatch([]string{"user-1"}, md.Get("x-user-id"))
+		require.ElementsMatch([]string{"read write"}, md.Get("x-user-scope"))
+		return response{Id: req.Id}
+	})
+
+	introspectionCalls := 0
+	introspectionServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		introspectionCalls++
+		clientId, clientSecret, ok := r.BasicAuth()
+		require.True(ok)
+		require.EqualValues("gate", clientId)
+		require.EqualValues("secret", clientSecret)
+		require.NoError(r.ParseForm())
+
+		claims := map[string]any{"active": false}
+		switch r.PostForm.Get("token") {
+		case "valid":
+			claims = map[string]any{
+				"active": true,
+				"sub":    "user-1",
+				"scope":  "read write",
+				"exp":    time.Now().Add(time.Hour).Unix(),
+			}
+		case "no-scope":
+			claims = map[string]any{"active": true, "sub": "user-2", "scope": "write"}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(json.NewEncoder(w).Encode(claims))
+	}))
+	defer introspectionServer.Close()
+
+	logger, err := log.New(log.WithLevel(log.DebugLevel))
+	require.NoError(err)
+	routes := routes.NewRoutes(logger)
+	targetClients := map[string]*client.Client{"target": targetCli}
+	locator := assembly.NewLocator(assembly.LocatorDeps{
+		Logger:          logger,
+		GrpcClients:     targetClients,
+		Routes:          routes,
+		SystemCli:       systemCli,
+		AdminCli:        adminCli,
+		UsersAuthCache:  cache.New(),
+		AppAuthCache:    cache.New(),
+		AppSecretCache:  cache.New(),
+		RevocationCache: cache.New(),
+		AdminCache:      cache.New(),
+	})
+	config.CustomAuth = conf.CustomAuth{
+		TokenProviders: []conf.TokenProvider{{
+			Name: "bearer",
+			Type: conf.BearerTokenProviderType,
+		}},
+		UserAuthSettings: []conf.UserAuthSetting{{
+			ModuleNameList: []string{"target"},
+			TokenProviders: []string{"bearer"},
+			Introspection: &conf.Introspection{
+				Endpoint:       introspectionServer.URL,
+				ClientId:       "gate",
+				ClientSecret:   "secret",
+				RequiredScopes: []string{"read"},
+				IdentityHeader: "x-user-id",
+				ClaimHeaders:   []conf.IntrospectionClaimHeader{{Claim: "scope", Header: "x-user-scope"}},
+			},
+		}},
+	}
+	handler, err := locator.Handler(config, []conf.Location{{
+		PathPrefix:   "/api",
+		Protocol:     "grpc",
+		TargetModule: "target",
+	}})
+	require.NoError(err)
+
+	err = routes.ReceiveRoutes(context.Background(), cluster.RoutingConfig{{
+		ModuleName: "target",
+		Endpoints: []cluster.EndpointDescriptor{{
+			Path:             "endpoint",
+			UserAuthRequired: true,
+		}},
+	}})
+	require.NoError(err)
+
+	srv := httptest.NewServer(handler)
+	call := func(token string) int {
+		resp, err := httpcli.New().Post(srv.URL+"/api/endpoint").
+			Header("x-application-token", "token").
+			Header("Authorization", "Bearer "+token).
+			JsonRequestBody(request{Id: uuid.New().String()}).
+			Do(s.T().Context())
+		require.NoError(err)
+		return resp.StatusCode()
+	}
+
+	require.EqualValues(http.StatusOK, call("valid"))
+	require.EqualValues(http.StatusOK, call("valid"))
+	require.EqualValues(1, introspectionCalls)
+	require.EqualValues(http.StatusUnauthorized, call("inactive"))
+	require.EqualValues(http.StatusUnauthorized, call("no-scope"))
+}
+
+func (s *HappyPathTestSuite) TestUserAuthorization_CacheUntilExpiry() { // nolint:funlen
+	test, require := test.New(s.T())
+	config, systemCli, adminCli := s.commonDependencies(test)
+
+	targetService, targetCli := grpct.NewMock(test)
+	targetService.Mock("endpoint", func(ctx context.Context, authData grpc.AuthData, req request) response {
+		return response{Id: req.Id}
+	})
+
+	routerMux := router.New()
+	defaultWrapper := endpoint.DefaultWrapper(
+		test.Logger(),
+		httplog.Noop(),
+	)
+	authCalls := atomic.Int32{}
+	routerMux.POST("/test-user-auth/authenticate",
+		defaultWrapper.Endpoint(func(req entity.UserAuthenticateRequest) entity.UserAuthenticateResponse {
+			authCalls.Add(1)
+			expiresAt := time.Now().Add(500 * time.Millisecond)
+			switch req.Token {
+			case "ttl-hint":
+				expiresAt = time.Now().Add(time.Hour)
+			case "expired":
+				expiresAt = time.Now().Add(-time.Minute)
+			}
+			return entity.UserAuthenticateResponse{
+				Authenticated: true,
+				AuthData:      &entity.UserAuthData{Identity: req.Token, IdentityHeader: "x-user-id"},
+				ExpiresAt:     &expiresAt,
+				CacheTtlSec:   1,
+			}
+		}))
+	routerMock := httptest.NewServer(routerMux)
+	targetUrl, err := url.Parse(routerMock.URL)
+	require.NoError(err)
+	rr := lb.NewRoundRobin([]string{targetUrl.Host})
+
+	logger, err := log.New(log.WithLevel(log.DebugLevel))
+	require.NoError(err)
+	routes := routes.NewRoutes(logger)
+	targetClients := map[string]*client.Client{"target": targetCli}
+	locator := assembly.NewLocator(assembly.LocatorDeps{
+		Logger:          logger,
+		GrpcClients:     targetClients,
+		Routes:          routes,
+		SystemCli:       systemCli,
+		AdminCli:        adminCli,
+		RouterLb:        rr,
+		UsersAuthCache:  cache.New(),
+		AppAuthCache:    cache.New(),
+		AppSecretCache:  cache.New(),
+		RevocationCache: cache.New(),
+		AdminCache:      cache.New(),
+	})
+	config.CustomAuth = conf.CustomAuth{
+		TokenProviders: []conf.TokenProvider{{
+			Name: "bearer",
+			Type: conf.BearerTokenProviderType,
+		}},
+		UserAuthSettings: []conf.UserAuthSetting{{
+			ModuleNameList:       []string{"target"},
+			TokenProviders:       []string{"bearer"},
+			AuthenticateEndpoint: "test-user-auth/authenticate",
+			CacheDataInSec:       3600,
+		}},
+	}
+	handler, err := locator.Handler(config, []conf.Location{{
+		PathPrefix:   "/api",
+		Protocol:     "grpc",
+		TargetModule: "target",
+	}})
+	require.NoError(err)
+
+	err = routes.ReceiveRoutes(context.Background(), cluster.RoutingConfig{{
+		ModuleName: "target",
+		Endpoints: []cluster.EndpointDescriptor{{
+			Path:             "endpoint",
+			UserAuthRequired: true,
+		}},
+	}})
+	require.NoError(err)
+
+	srv := httptest.NewServer(handler)
+	call := func(token string) {
+		err := httpcli.New().Post(srv.URL+"/api/endpoint").
+			Header("x-application-token", "token").
+			Header("Authorization", "Bearer "+token).
+			JsonRequestBody(request{Id: uuid.New().String()}).
+			StatusCodeToError().
+			DoWithoutResponse(s.T().Context())
+		require.NoError(err)
+	}
+
+	for range 2 {
+		resp, err := httpcli.New().Post(srv.URL+"/api/endpoint").
+			Header("x-application-token", "token").
+			Header("Authorization", "Bearer expired").
+			JsonRequestBody(request{Id: uuid.New().String()}).
+			Do(s.T().Context())
+		require.NoError(err)
+		require.EqualValues(http.StatusUnauthorized, resp.StatusCode())
+	}
+	require.EqualValues(2, authCalls.Load())
+	authCalls.Store(0)
+
+	call("expires")
+	call("expires")
+	require.EqualValues(1, authCalls.Load())
+	call("ttl-hint")
+	call("ttl-hint")
+	require.EqualValues(2, authCalls.Load())
+
+	time.Sleep(1100 * time.Millisecond)
+	call("expires")
+	call("ttl-hint")
+	require.EqualValues(4, authCalls.Load())
+}
+
+func (s *HappyPathTestSuite) TestUserAuthorization_Permissions() { // nolint:funlen
+	test, require := test.New(s.T())
+	config, systemCli, adminCli := s.commonDependencies(test)
+
+	targetService, targetCli := grpct.NewMock(test)
+	for _, path := range []string{"read", "write", "view", "manage"} {
+		targetService.Mock(path, func(req request) response {
+			return response{Id: req.Id}
+		})
+	}
+
+	routerMux := router.New()
+	defaultWrapper := endpoint.DefaultWrapper(
+		test.Logger(),
+		httplog.Noop(),
+	)
+	routerMux.POST("/test-user-auth/authenticate",
+		defaultWrapper.Endpoint(func(req entity.UserAuthenticateRequest) entity.UserAuthenticateResponse {
+			return entity.UserAuthenticateResponse{
+				Authenticated: true,
+				AuthData: &entity.UserAuthData{
+					Identity:       "user-1",
+					IdentityHeader: "x-user-id",
+					Roles:          []string{"viewer"},
+					Permissions:    []string{"read"},
+				},
+			}
+		}))
+	routerMock := httptest.NewServer(routerMux)
+	targetUrl, err := url.Parse(routerMock.URL)
+	require.NoError(err)
+	rr := lb.NewRoundRobin([]string{targetUrl.Host})
+
+	logger, err := log.New(log.WithLevel(log.DebugLevel))
+	require.NoError(err)
+	routes := routes.NewRoutes(logger)
+	targetClients := map[string]*client.Client{"target": targetCli}
+	locator := assembly.NewLocator(assembly.LocatorDeps{
+		Logger:          logger,
+		GrpcClients:     targetClients,
+		Routes:          routes,
+		SystemCli:       systemCli,
+		AdminCli:        adminCli,
+		RouterLb:        rr,
+		UsersAuthCache:  cache.New(),
+		AppAuthCache:    cache.New(),
+		AppSecretCache:  cache.New(),
+		RevocationCache: cache.New(),
+		AdminCache:      cache.New(),
+	})
+	config.CustomAuth = conf.CustomAuth{
+		TokenProviders: []conf.TokenProvider{{
+			Name: "bearer",
+			Type: conf.BearerTokenProviderType,
+		}},
+		UserAuthSettings: []conf.UserAuthSetting{{
+			ModuleNameList:       []string{"target"},
+			TokenProviders:       []string{"bearer"},
+			AuthenticateEndpoint: "test-user-auth/authenticate",
+		}},
+	}
+	handler, err := locator.Handler(config, []conf.Location{{
+		PathPrefix:   "/api",
+		Protocol:     "grpc",
+		TargetModule: "target",
+	}})
+	require.NoError(err)
+
+	err = routes.ReceiveRoutes(context.Background(), cluster.RoutingConfig{{
+		ModuleName: "target",
+		Endpoints: []cluster.EndpointDescriptor{{
+			Path:             "read",
+			UserAuthRequired: true,
+			Extra:            map[string]any{domain.RequiredUserPermissionsExtraKey: []any{"read"}},
+		}, {
+			Path:             "write",
+			UserAuthRequired: true,
+			Extra:            map[string]any{domain.RequiredUserPermissionsExtraKey: []any{"read", "write"}},
+		}, {
+			Path:             "view",
+			UserAuthRequired: true,
+			Extra:            map[string]any{domain.RequiredUserRolesExtraKey: []any{"admin", "viewer"}},
+		}, {
+			Path:             "manage",
+			UserAuthRequired: true,
+			Extra:            map[string]any{domain.RequiredUserRolesExtraKey: "admin"},
+		}},
+	}})
+	require.NoError(err)
+
+	srv := httptest.NewServer(handler)
+	call := func(path string) int {
+		resp, err := httpcli.New().Post(srv.URL+"/api/"+path).
+			Header("x-application-token", "token").
+			Header("Authorization", "Bearer user-token").
+			JsonRequestBody(request{Id: uuid.New().String()}).
+			Do(s.T().Context())
+		require.NoError(err)
+		return resp.StatusCode()
+	}
+
+	require.EqualValues(http.StatusOK, call("read"))
+	require.EqualValues(http.StatusForbidden, call("write"))
+	require.EqualValues(http.StatusOK, call("view"))
+	require.EqualValues(http.StatusForbidden, call("manage"))
+}
+
+func (s *HappyPathTestSuite) TestUserAuthorization_SkipAppAuth() { // nolint:funlen
+	test, require := test.New(s.T())
+	config, _, adminCli := s.commonDependencies(test)
+	systemService, systemCli := grpct.NewMock(test)
+	systemService.Mock("system/secure/authenticate", func() entity.AuthenticateResponse {
+		return entity.AuthenticateResponse{
+			Authenticated: false,
+			ErrorReason:   "",
+		}
+	}).Mock("system/secure/authorize", func() entity.AuthorizeResponse {
+		return entity.AuthorizeResponse{Authorized: false}
+	})
+
+	userAuthData := fake.It[entity.UserAuthData]()
+	userAuthData.IdentityHeader = "x-" + userAuthData.IdentityHeader
+
+	targetService, targetCli := grpct.NewMock(test)
+	targetService.Mock("endpoint", func(ctx context.Context, authData grpc.AuthData, req request) response {
+		md, ok := metadata.FromIncomingContext(ctx)
+		require.True(ok)
+		require.ElementsMatch([]string{userAuthData.Identity}, md.Get(userAuthData.IdentityHeader))
+
 		return response{Id: req.Id}
 	})
 
@@ -332,254 +1837,1283 @@ func (s *HappyPathTestSuite) TestUserAuthorization() { // nolint:funlen
 		httplog.Noop(),
 	)
 
-	userAuthToken := fake.It[string]()
+	userAuthToken := fake.It[string]()
+
+	routerMux.POST("/test-user-auth/authenticate",
+		defaultWrapper.Endpoint(func(req entity.UserAuthenticateRequest) entity.UserAuthenticateResponse {
+			require.Equal(userAuthToken, req.Token)
+			return entity.UserAuthenticateResponse{
+				Authenticated: true,
+				AuthData:      &userAuthData,
+			}
+		}))
+	routerMock := httptest.NewServer(routerMux)
+	targetUrl, err := url.Parse(routerMock.URL)
+	require.NoError(err)
+	rr := lb.NewRoundRobin([]string{targetUrl.Host})
+
+	targetClients := map[string]*client.Client{"target": targetCli}
+	logger, err := log.New(log.WithLevel(log.DebugLevel))
+	require.NoError(err)
+	routes := routes.NewRoutes(logger)
+	locator := assembly.NewLocator(assembly.LocatorDeps{
+		Logger:          logger,
+		GrpcClients:     targetClients,
+		Routes:          routes,
+		SystemCli:       systemCli,
+		AdminCli:        adminCli,
+		RouterLb:        rr,
+		UsersAuthCache:  cache.New(),
+		AppAuthCache:    cache.New(),
+		AppSecretCache:  cache.New(),
+		RevocationCache: cache.New(),
+		AdminCache:      cache.New(),
+	})
+
+	locations := []conf.Location{{
+		SkipAuth:     false,
+		PathPrefix:   "/api",
+		Protocol:     "grpc",
+		TargetModule: "target",
+	}}
+	config.CustomAuth = conf.CustomAuth{
+		TokenProviders: []conf.TokenProvider{
+			{
+				Name: "test_provider",
+				Type: conf.HeaderTokenProviderType,
+				HeaderProvider: &conf.HeaderTokenProvider{
+					HeaderName: "test-token-header",
+				},
+			},
+		},
+		UserAuthSettings: []conf.UserAuthSetting{
+			{
+				ModuleNameList:       []string{"target"},
+				TokenProviders:       []string{"test_provider"},
+				AuthenticateEndpoint: "test-user-auth/authenticate",
+				SkipAppAuth:          true,
+			},
+			{
+				ModuleNameList:       []string{"target2"},
+				TokenProviders:       []string{"test_provider"},
+				AuthenticateEndpoint: "test-user-auth/authenticate",
+			},
+		},
+	}
+	handler, err := locator.Handler(config, locations)
+	require.NoError(err)
+
+	err = routes.ReceiveRoutes(context.Background(), cluster.RoutingConfig{{
+		ModuleName: "target",
+		Endpoints: []cluster.EndpointDescriptor{{
+			Path:             "endpoint",
+			Inner:            true,
+			UserAuthRequired: true,
+		}}},
+		{
+			ModuleName: "target2",
+			Endpoints: []cluster.EndpointDescriptor{{
+				Path:             "failed_endpoint",
+				Inner:            true,
+				UserAuthRequired: true,
+			}},
+		},
+	})
+	require.NoError(err)
+
+	srv := httptest.NewServer(handler)
+	cli := httpcli.New()
+	req := request{Id: uuid.New().String()}
+	resp := response{}
+	err = cli.Post(srv.URL+"/api/endpoint").
+		Header("x-application-token", "token").
+		Header("test-token-header", userAuthToken).
+		Header("x-auth-admin", "mock-token").
+		JsonRequestBody(req).
+		JsonResponseBody(&resp).
+		StatusCodeToError().
+		DoWithoutResponse(s.T().Context())
+	require.NoError(err)
+	require.EqualValues(req.Id, resp.Id)
+
+	err = cli.Post(srv.URL+"/api/failed_endpoint").
+		Header("x-application-token", "token").
+		Header("test-token-header", userAuthToken).
+		Header("x-auth-admin", "mock-token").
+		JsonRequestBody(req).
+		JsonResponseBody(&resp).
+		StatusCodeToError().
+		DoWithoutResponse(s.T().Context())
+	errResp := httpcli.ErrorResponse{}
+	require.ErrorAs(err, &errResp)
+	require.EqualValues(http.StatusUnauthorized, errResp.StatusCode)
+}
+
+// nolint:ireturn
+func (s *HappyPathTestSuite) TestAccessPolicy() { // nolint:funlen
+	test, require := test.New(s.T())
+	config, systemCli, adminCli := s.commonDependencies(test)
+
+	paths := []string{"open", "report/daily", "broken", "other", "endpoint"}
+	targetService, targetCli := grpct.NewMock(test)
+	endpoints := make([]cluster.EndpointDescriptor, 0, len(paths))
+	for _, path := range paths {
+		targetService.Mock(path, func(req request) response {
+			return response{Id: req.Id}
+		})
+		endpoints = append(endpoints, cluster.EndpointDescriptor{Path: path})
+	}
+
+	logger, err := log.New(log.WithLevel(log.DebugLevel))
+	require.NoError(err)
+	routes := routes.NewRoutes(logger)
+	err = routes.ReceiveRoutes(context.Background(), cluster.RoutingConfig{{
+		ModuleName: "target",
+		Endpoints:  endpoints,
+	}})
+	require.NoError(err)
+	locator := assembly.NewLocator(assembly.LocatorDeps{
+		Logger:          logger,
+		GrpcClients:     map[string]*client.Client{"target": targetCli},
+		Routes:          routes,
+		SystemCli:       systemCli,
+		AdminCli:        adminCli,
+		AppAuthCache:    cache.New(),
+		AppSecretCache:  cache.New(),
+		RevocationCache: cache.New(),
+		AdminCache:      cache.New(),
+	})
+	locations := []conf.Location{{
+		PathPrefix:   "/api",
+		Protocol:     "grpc",
+		TargetModule: "target",
+	}}
+
+	config.AccessPolicy = conf.AccessPolicy{
+		Enable: true,
+		Rules: []conf.PolicyRule{{
+			Name:      "audit",
+			Effect:    conf.AuditPolicyEffect,
+			Condition: `app.authenticated`,
+		}, {
+			Name:      "open",
+			Effect:    conf.AllowPolicyEffect,
+			Condition: `app.id == 4 && request.endpoint == "open"`,
+		}, {
+			Name:      "reports",
+			Effect:    conf.DenyPolicyEffect,
+			Condition: `request.endpoint.startsWith("report/") && now.getHours("UTC") >= 0`,
+		}, {
+			Name:      "broken",
+			Effect:    conf.DenyPolicyEffect,
+			Condition: `request.endpoint == "broken" && app.unknown == 1`,
+		}, {
+			Name:      "other",
+			Effect:    conf.DenyPolicyEffect,
+			Condition: `request.endpoint in ["open", "other"] && !user.authenticated`,
+		}},
+	}
+	handler, err := locator.Handler(config, locations)
+	require.NoError(err)
+	srv := httptest.NewServer(handler)
+	call := func(path string) int {
+		resp, err := httpcli.New().Post(srv.URL+"/api/"+path).
+			Header("x-application-token", "token").
+			JsonRequestBody(request{Id: uuid.New().String()}).
+			Do(s.T().Context())
+		require.NoError(err)
+		return resp.StatusCode()
+	}
+	require.EqualValues(http.StatusOK, call("open"))
+	require.EqualValues(http.StatusForbidden, call("report/daily"))
+	require.EqualValues(http.StatusForbidden, call("broken"))
+	require.EqualValues(http.StatusForbidden, call("other"))
+	require.EqualValues(http.StatusOK, call("endpoint"))
+
+	config.AccessPolicy.DryRun = true
+	handler, err = locator.Handler(config, locations)
+	require.NoError(err)
+	srv = httptest.NewServer(handler)
+	require.EqualValues(http.StatusOK, call("report/daily"))
+
+	config.AccessPolicy.Rules = []conf.PolicyRule{{
+		Name:      "invalid",
+		Effect:    conf.DenyPolicyEffect,
+		Condition: `request.endpoint ==`,
+	}}
+	_, err = locator.Handler(config, locations)
+	require.Error(err)
+}
+
+func (s *HappyPathTestSuite) TestAuthorizationPrefetch() { // nolint:funlen
+	test, require := test.New(s.T())
+	config, _, adminCli := s.commonDependencies(test)
+	config.AuthorizationPrefetch = conf.AuthorizationPrefetch{Enable: true, RefreshIntervalInSec: 60}
+
+	paths := []string{"endpoint", "report/daily", "other"}
+	targetService, targetCli := grpct.NewMock(test)
+	endpoints := make([]cluster.EndpointDescriptor, 0, len(paths))
+	for _, path := range paths {
+		targetService.Mock(path, func(req request) response {
+			return response{Id: req.Id}
+		})
+		endpoints = append(endpoints, cluster.EndpointDescriptor{Path: path})
+	}
+
+	authorizeCalls := atomic.Int32{}
+	permissionsCalls := atomic.Int32{}
+	newSystemCli := func(withPermissions bool) *client.Client {
+		systemService, systemCli := grpct.NewMock(test)
+		systemService.Mock("system/secure/authenticate", func() entity.AuthenticateResponse {
+			return entity.AuthenticateResponse{
+				Authenticated: true,
+				AuthData:      &entity.AppAuthData{ApplicationId: 4, AppName: "test"},
+			}
+		}).Mock("system/secure/authorize", func(req entity.AuthorizeRequest) entity.AuthorizeResponse {
+			authorizeCalls.Add(1)
+			return entity.AuthorizeResponse{Authorized: req.Endpoint == "endpoint"}
+		})
+		if withPermissions {
+			systemService.Mock("system/secure/get_application_permissions", func(req entity.ApplicationPermissionsRequest) entity.ApplicationPermissionsResponse {
+				permissionsCalls.Add(1)
+				require.EqualValues(4, req.ApplicationId)
+				return entity.ApplicationPermissionsResponse{
+					Endpoints: []entity.EndpointPermission{
+						{HttpMethod: http.MethodPost, Endpoint: "/endpoint"},
+						{Endpoint: "report/*"},
+					},
+				}
+			})
+		}
+		return systemCli
+	}
+
+	logger, err := log.New(log.WithLevel(log.DebugLevel))
+	require.NoError(err)
+	routes := routes.NewRoutes(logger)
+	err = routes.ReceiveRoutes(context.Background(), cluster.RoutingConfig{{
+		ModuleName: "target",
+		Endpoints:  endpoints,
+	}})
+	require.NoError(err)
+	newServer := func(systemCli *client.Client) *httptest.Server {
+		locator := assembly.NewLocator(assembly.LocatorDeps{
+			Logger:          logger,
+			GrpcClients:     map[string]*client.Client{"target": targetCli},
+			Routes:          routes,
+			SystemCli:       systemCli,
+			AdminCli:        adminCli,
+			AppAuthCache:    cache.New(),
+			AppSecretCache:  cache.New(),
+			RevocationCache: cache.New(),
+			AdminCache:      cache.New(),
+		})
+		handler, err := locator.Handler(config, []conf.Location{{
+			PathPrefix:   "/api",
+			Protocol:     "grpc",
+			TargetModule: "target",
+		}})
+		require.NoError(err)
+		return httptest.NewServer(handler)
+	}
+	call := func(srv *httptest.Server, path string) int {
+		resp, err := httpcli.New().Post(srv.URL+"/api/"+path).
+			Header("x-application-token", "token").
+			JsonRequestBody(request{Id: uuid.New().String()}).
+			Do(s.T().Context())
+		require.NoError(err)
+		return resp.StatusCode()
+	}
+
+	srv := newServer(newSystemCli(true))
+	require.EqualValues(http.StatusOK, call(srv, "endpoint"))
+	require.EqualValues(http.StatusOK, call(srv, "report/daily"))
+	require.EqualValues(http.StatusForbidden, call(srv, "other"))
+	require.EqualValues(http.StatusForbidden, call(srv, "other"))
+	require.EqualValues(1, permissionsCalls.Load())
+	require.EqualValues(0, authorizeCalls.Load())
+
+	srv = newServer(newSystemCli(false))
+	require.EqualValues(http.StatusOK, call(srv, "endpoint"))
+	require.EqualValues(http.StatusForbidden, call(srv, "other"))
+	require.EqualValues(2, authorizeCalls.Load())
+}
+
+func (s *HappyPathTestSuite) TestRevocation() { // nolint:funlen
+	test, require := test.New(s.T())
+	config, _, adminCli := s.commonDependencies(test)
+	config.Caching.AuthenticationDataInSec = 60
+
+	authenticateCalls := atomic.Int32{}
+	systemService, systemCli := grpct.NewMock(test)
+	systemService.Mock("system/secure/authenticate", func() entity.AuthenticateResponse {
+		authenticateCalls.Add(1)
+		return entity.AuthenticateResponse{
+			Authenticated: true,
+			AuthData:      &entity.AppAuthData{ApplicationId: 4, AppName: "test"},
+		}
+	}).Mock("system/secure/authorize", func() entity.AuthorizeResponse {
+		return entity.AuthorizeResponse{Authorized: true}
+	})
+	targetService, targetCli := grpct.NewMock(test)
+	targetService.Mock("endpoint", func(req request) response {
+		return response{Id: req.Id}
+	})
+
+	lockerCli, publishCalls := pubSubMock(test)
+	newReplica := func() (*cache.Cache, service.Revocation) {
+		appAuthCache := cache.New()
+		usersAuthCache := cache.New()
+		revocationService := service.NewRevocation(
+			repository.NewRevocationList(cache.New()),
+			repository.NewAuthenticationCache(appAuthCache, 0),
+			repository.NewApplicationSecretCache(cache.New(), 0),
+			repository.NewUserAuthenticationCache(usersAuthCache),
+			repository.NewAdminCache(cache.New(), 0),
+			repository.NewRevocationEvents(lockerCli, gatemetrics.NewStorage(metrics.DefaultRegistry)),
+			time.Minute,
+			test.Logger(),
+		)
+		return appAuthCache, revocationService
+	}
+	peerAppAuthCache, peerRevocationService := newReplica()
+	peerAppAuthCache.Set("token", []byte(`{"applicationId":4}`), time.Minute)
+	subscribeCtx, cancelSubscribe := context.WithCancel(s.T().Context())
+	defer cancelSubscribe()
+	go peerRevocationService.Subscribe(subscribeCtx, 10*time.Millisecond)
+
+	appAuthCache, revocationService := newReplica()
+	adminService := service.NewAdmin(
+		repository.NewAdminCache(cache.New(), 0),
+		repository.NewAdmin(adminCli),
+		repository.NewRevocationList(cache.New()),
+		0,
+		0,
+	)
+	mux := http.NewServeMux()
+	mux.Handle(domain.RevocationPath, revocation.Handler(revocationService, adminService, "", test.Logger()))
+	infra := httptest.NewServer(mux)
+
+	logger, err := log.New(log.WithLevel(log.DebugLevel))
+	require.NoError(err)
+	routes := routes.NewRoutes(logger)
+	locator := assembly.NewLocator(assembly.LocatorDeps{
+		Logger:          logger,
+		GrpcClients:     map[string]*client.Client{"target": targetCli},
+		Routes:          routes,
+		SystemCli:       systemCli,
+		AdminCli:        adminCli,
+		AppAuthCache:    appAuthCache,
+		AppSecretCache:  cache.New(),
+		RevocationCache: cache.New(),
+		AdminCache:      cache.New(),
+	})
+	handler, err := locator.Handler(config, []conf.Location{{
+		PathPrefix:   "/api",
+		Protocol:     "grpc",
+		TargetModule: "target",
+	}})
+	require.NoError(err)
+	err = routes.ReceiveRoutes(context.Background(), cluster.RoutingConfig{{
+		ModuleName: "target",
+		Endpoints:  []cluster.EndpointDescriptor{{Path: "endpoint"}},
+	}})
+	require.NoError(err)
 
-	routerMux.POST("/test-user-auth/authenticate",
-		defaultWrapper.Endpoint(func(req entity.UserAuthenticateRequest) entity.UserAuthenticateResponse {
-			require.Equal(userAuthToken, req.Token)
-			return entity.UserAuthenticateResponse{
-				Authenticated: true,
-				AuthData:      &userAuthData,
-			}
-		}))
-	routerMux.POST("/failed-user-auth/authenticate",
-		defaultWrapper.Endpoint(func(req entity.UserAuthenticateRequest) entity.UserAuthenticateResponse {
-			require.Equal(userAuthToken, req.Token)
-			return entity.UserAuthenticateResponse{
-				Authenticated: false,
-				ErrorReason:   "test fail",
-			}
-		}))
-	routerMock := httptest.NewServer(routerMux)
-	targetUrl, err := url.Parse(routerMock.URL)
+	srv := httptest.NewServer(handler)
+	call := func() {
+		err := httpcli.New().Post(srv.URL+"/api/endpoint").
+			Header("x-application-token", "token").
+			JsonRequestBody(request{Id: uuid.New().String()}).
+			StatusCodeToError().
+			DoWithoutResponse(s.T().Context())
+		require.NoError(err)
+	}
+	call()
+	call()
+	require.EqualValues(1, authenticateCalls.Load())
+
+	resp, err := httpcli.New().Post(infra.URL + domain.RevocationPath).
+		JsonRequestBody(entity.RevocationRequest{ApplicationId: 4}).
+		Do(s.T().Context())
 	require.NoError(err)
-	rr := lb.NewRoundRobin([]string{targetUrl.Host})
+	require.EqualValues(http.StatusUnauthorized, resp.StatusCode())
+
+	revocationResp := entity.RevocationResponse{}
+	err = httpcli.New().Post(infra.URL+domain.RevocationPath).
+		Header("x-auth-admin", "admin-token").
+		JsonRequestBody(entity.RevocationRequest{ApplicationId: 4}).
+		JsonResponseBody(&revocationResp).
+		StatusCodeToError().
+		DoWithoutResponse(s.T().Context())
+	require.NoError(err)
+	require.True(revocationResp.Broadcast)
+	require.EqualValues(2, publishCalls.Load())
+
+	require.Eventually(func() bool {
+		_, ok := peerAppAuthCache.Get("token")
+		return !ok
+	}, time.Second, 10*time.Millisecond)
+	call()
+	require.EqualValues(2, authenticateCalls.Load())
+}
+
+func (s *HappyPathTestSuite) TestAdminAuthenticationCache() {
+	test, require := test.New(s.T())
+	config, systemCli, _ := s.commonDependencies(test)
+	config.Caching.AdminAuthenticationDataInSec = 60
+	config.Caching.AdminNegativeAuthenticationDataInSec = 60
+
+	authenticateCalls := atomic.Int32{}
+	adminMock, adminCli := grpct.NewMock(test)
+	adminMock.Mock("admin/secure/authenticate", func(req entity.AdminAuthorizeRequest) entity.AdminAuthenticateResponse {
+		authenticateCalls.Add(1)
+		return entity.AdminAuthenticateResponse{Authenticated: true, AdminId: 1}
+	})
+	targetService, targetCli := grpct.NewMock(test)
+	targetService.Mock("endpoint", func(req request) response {
+		return response{Id: req.Id}
+	})
 
-	targetClients := map[string]*client.Client{"target": targetCli}
 	logger, err := log.New(log.WithLevel(log.DebugLevel))
 	require.NoError(err)
 	routes := routes.NewRoutes(logger)
-	locator := assembly.NewLocator(logger, targetClients, nil, routes, systemCli, adminCli, nil, rr, cache.New())
+	err = routes.ReceiveRoutes(s.T().Context(), cluster.RoutingConfig{{
+		ModuleName: "target",
+		Endpoints:  []cluster.EndpointDescriptor{{Path: "endpoint"}},
+	}})
+	require.NoError(err)
 
-	locations := []conf.Location{{
-		SkipAuth:     false,
+	adminCache := cache.New()
+	revocationCache := cache.New()
+	locator := assembly.NewLocator(assembly.LocatorDeps{
+		Logger:          logger,
+		GrpcClients:     map[string]*client.Client{"target": targetCli},
+		Routes:          routes,
+		SystemCli:       systemCli,
+		AdminCli:        adminCli,
+		AppAuthCache:    cache.New(),
+		AppSecretCache:  cache.New(),
+		RevocationCache: revocationCache,
+		AdminCache:      adminCache,
+	})
+	handler, err := locator.Handler(config, []conf.Location{{
 		PathPrefix:   "/api",
 		Protocol:     "grpc",
 		TargetModule: "target",
-	}}
-	config.CustomAuth = conf.CustomAuth{
-		TokenProviders: []conf.TokenProvider{
-			{
-				Name: "test_provider",
-				Type: conf.HeaderTokenProviderType,
-				HeaderProvider: &conf.HeaderTokenProvider{
-					HeaderName: "test-token-header",
-				},
-			},
-		},
-		UserAuthSettings: []conf.UserAuthSetting{
-			{
-				ModuleNameList:       []string{"target"},
-				TokenProviders:       []string{"test_provider"},
-				AuthenticateEndpoint: "test-user-auth/authenticate",
-			},
-			{
-				ModuleNameList:       []string{"target2"},
-				TokenProviders:       []string{"test_provider"},
-				AuthenticateEndpoint: "failed-user-auth/authenticate",
-			},
-		},
+	}})
+	require.NoError(err)
+
+	srv := httptest.NewServer(handler)
+	call := func() {
+		err := httpcli.New().Post(srv.URL+"/api/endpoint").
+			Header("x-application-token", "token").
+			Header("x-auth-admin", "admin-token").
+			JsonRequestBody(request{Id: uuid.New().String()}).
+			StatusCodeToError().
+			DoWithoutResponse(s.T().Context())
+		require.NoError(err)
 	}
-	handler, err := locator.Handler(config, locations)
+	call()
+	call()
+	require.EqualValues(1, authenticateCalls.Load())
+
+	lockerCli, _ := pubSubMock(test)
+	revocationService := service.NewRevocation(
+		repository.NewRevocationList(revocationCache),
+		repository.NewAuthenticationCache(cache.New(), 0),
+		repository.NewApplicationSecretCache(cache.New(), 0),
+		repository.NewUserAuthenticationCache(cache.New()),
+		repository.NewAdminCache(adminCache, 0),
+		repository.NewRevocationEvents(lockerCli, gatemetrics.NewStorage(metrics.DefaultRegistry)),
+		time.Minute,
+		test.Logger(),
+	)
+	revocationService.Revoke(s.T().Context(), entity.RevocationRequest{AdminId: 1})
+
+	call()
+	call()
+	require.EqualValues(2, authenticateCalls.Load())
+}
+
+func (s *HappyPathTestSuite) TestAuditLog() { // nolint:funlen
+	test, require := test.New(s.T())
+	config, systemCli, adminCli := s.commonDependencies(test)
+	config.Logging.AuditRequestBodyHashEnable = true
+
+	targetService, targetCli := grpct.NewMock(test)
+	targetService.Mock("endpoint", func(req request) response {
+		return response{Id: req.Id}
+	}).Mock("public", func(req request) response {
+		return response{Id: req.Id}
+	})
+	logger, err := log.New(log.WithLevel(log.DebugLevel))
+	require.NoError(err)
+	routes := routes.NewRoutes(logger)
+	err = routes.ReceiveRoutes(s.T().Context(), cluster.RoutingConfig{{
+		ModuleName: "target",
+		Endpoints: []cluster.EndpointDescriptor{{
+			Path:  "endpoint",
+			Inner: true,
+			Extra: cluster.RequireAdminPermission("ok_permission"),
+		}, {
+			Path:  "failed_endpoint",
+			Inner: true,
+			Extra: cluster.RequireAdminPermission("failed_permission"),
+		}, {
+			Path: "public",
+		}},
+	}})
 	require.NoError(err)
 
-	err = routes.ReceiveRoutes(context.Background(), cluster.RoutingConfig{
-		{
-			ModuleName: "target",
-			Endpoints: []cluster.EndpointDescriptor{{
-				Path:             "endpoint",
-				Inner:            true,
-				UserAuthRequired: true,
-			},
-			},
-		},
-		{
-			ModuleName: "target2",
-			Endpoints: []cluster.EndpointDescriptor{
-				{
-					Path:             "failed_auth_endpoint",
-					Inner:            true,
-					UserAuthRequired: true,
-				}, {
-					Path:             "failed_endpoint",
-					Inner:            true,
-					UserAuthRequired: true,
-				},
-			},
+	auditLog := &bytes.Buffer{}
+	locator := assembly.NewLocator(assembly.LocatorDeps{
+		Logger:          logger,
+		GrpcClients:     map[string]*client.Client{"target": targetCli},
+		Routes:          routes,
+		SystemCli:       systemCli,
+		AdminCli:        adminCli,
+		AppAuthCache:    cache.New(),
+		AppSecretCache:  cache.New(),
+		RevocationCache: cache.New(),
+		AdminCache:      cache.New(),
+		AuditWriter:     audit.NewWriter(auditLog),
+	})
+	handler, err := locator.Handler(config, []conf.Location{{
+		PathPrefix:   "/api",
+		Protocol:     "grpc",
+		TargetModule: "target",
+	}})
+	require.NoError(err)
+
+	srv := httptest.NewServer(handler)
+	body := []byte(`{"id":"audit"}`)
+	call := func(endpoint string, adminToken string, body []byte) int {
+		req := httpcli.New().Post(srv.URL+"/api/"+endpoint).
+			Header("x-application-token", "token").
+			Header("Content-Type", "application/json").
+			RequestBody(body)
+		if adminToken != "" {
+			req = req.Header("x-auth-admin", adminToken)
+		}
+		resp, err := req.Do(s.T().Context())
+		require.NoError(err)
+		return resp.StatusCode()
+	}
+	require.EqualValues(http.StatusOK, call("endpoint", "mock-token", body))
+	require.EqualValues(http.StatusForbidden, call("failed_endpoint", "mock-token", body))
+	require.EqualValues(http.StatusOK, call("public", "", body))
+	require.EqualValues(http.StatusForbidden, call("failed_endpoint", "mock-token", bytes.Repeat([]byte("a"), 4<<20)))
+
+	lines := strings.Split(strings.TrimSpace(auditLog.String()), "\n")
+	require.Len(lines, 3)
+	records := make([]domain.AuditRecord, 0, len(lines))
+	for _, line := range lines {
+		record := domain.AuditRecord{}
+		err := json.Unmarshal([]byte(line), &record)
+		require.NoError(err)
+		records = append(records, record)
+	}
+
+	bodyHash := sha256.Sum256(body)
+	require.EqualValues("endpoint", records[0].Endpoint)
+	require.EqualValues(http.StatusOK, records[0].StatusCode)
+	require.EqualValues(1, records[0].AdminId)
+	require.EqualValues(4, records[0].ApplicationId)
+	require.True(records[0].Inner)
+	require.NotEmpty(records[0].RequestId)
+	require.NotEmpty(records[0].ClientIp)
+	require.EqualValues(hex.EncodeToString(bodyHash[:]), records[0].RequestBodyHash)
+	require.Empty(records[0].Events)
+
+	require.EqualValues("failed_endpoint", records[1].Endpoint)
+	require.EqualValues(http.StatusForbidden, records[1].StatusCode)
+	require.EqualValues([]string{domain.AuditAdminAuthorizationDeniedEvent}, records[1].Events)
+	// тело отклоненного запроса не читается upstream, но дочитывается для хеша
+	require.EqualValues(hex.EncodeToString(bodyHash[:]), records[1].RequestBodyHash)
+	require.False(records[1].RequestBodyHashUnknown)
+
+	// большое тело отклоненного запроса дочитывается только до лимита
+	require.Empty(records[2].RequestBodyHash)
+	require.True(records[2].RequestBodyHashUnknown)
+}
+
+func (s *HappyPathTestSuite) TestBodyLogLimits() { // nolint:funlen
+	test, require := test.New(s.T())
+	config, systemCli, adminCli := s.commonDependencies(test)
+	config.Logging.MaxBodyLogBytes = 16
+	config.Logging.BodyMasking = []conf.BodyMaskingRule{{Fields: []string{"password"}}}
+
+	image := bytes.Repeat([]byte{0x89, 'P', 'N', 'G'}, 64)
+	targetService := httpt.NewMock(test)
+	targetService.POST("/upload", func(w http.ResponseWriter, httpReq *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}).GET("/download", func(w http.ResponseWriter, httpReq *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write(image)
+	})
+	targetUrl, err := url.Parse(targetService.BaseURL())
+	require.NoError(err)
+	// ответ до чтения тела,транспорт дочитывает тело параллельно с логированием
+	rejectService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, httpReq *http.Request) {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+	}))
+	s.T().Cleanup(rejectService.Close)
+	rejectUrl, err := url.Parse(rejectService.URL)
+	require.NoError(err)
+
+	logFile := s.T().TempDir() + "/gate.log"
+	logger, err := log.New(
+		log.WithLevel(log.DebugLevel),
+		log.WithDisableDefaultOutput(),
+		log.WithFileOutput(file.Output{File: logFile}),
+	)
+	require.NoError(err)
+	routes := routes.NewRoutes(logger)
+	err = routes.ReceiveRoutes(s.T().Context(), cluster.RoutingConfig{{
+		Endpoints: []cluster.EndpointDescriptor{{Path: "/upload"}, {Path: "/reject"}, {Path: "/download"}},
+	}})
+	require.NoError(err)
+
+	locator := assembly.NewLocator(assembly.LocatorDeps{
+		Logger: logger,
+		HttpHostManagers: map[string]*lb.RoundRobin{
+			"target": lb.NewRoundRobin([]string{targetUrl.Host}),
+			"reject": lb.NewRoundRobin([]string{rejectUrl.Host}),
 		},
+		Routes:          routes,
+		SystemCli:       systemCli,
+		AdminCli:        adminCli,
+		AppAuthCache:    cache.New(),
+		AppSecretCache:  cache.New(),
+		RevocationCache: cache.New(),
+		AdminCache:      cache.New(),
+	})
+	handler, err := locator.Handler(config, []conf.Location{{
+		PathPrefix:   "/api",
+		Protocol:     "http",
+		TargetModule: "target",
+	}, {
+		PathPrefix:   "/reject-api",
+		Protocol:     "http",
+		TargetModule: "reject",
+	}})
+	require.NoError(err)
+	srv := httptest.NewServer(handler)
+
+	_, err = httpcli.New().Post(srv.URL+"/api/upload").
+		Header("x-application-token", "token").
+		Header("Content-Type", "application/json").
+		RequestBody([]byte(`{"password":"secret","data":"` + strings.Repeat("a", 100) + `"}`)).
+		StatusCodeToError().
+		Do(s.T().Context())
+	require.NoError(err)
+	bodyReader, bodyWriter := io.Pipe()
+	go func() {
+		// upstream дочитывает до ответа только небольшое тело
+		for range 10 {
+			_, _ = bodyWriter.Write(bytes.Repeat([]byte("a"), 64*1024))
+			time.Sleep(10 * time.Millisecond)
+		}
+		_ = bodyWriter.Close()
+	}()
+	rejectReq, err := http.NewRequestWithContext(s.T().Context(), http.MethodPost, srv.URL+"/reject-api/reject", bodyReader)
+	require.NoError(err)
+	rejectReq.Header.Set("x-application-token", "token")
+	rejectReq.Header.Set("Content-Type", "application/json")
+	rejected, err := http.DefaultClient.Do(rejectReq)
+	require.NoError(err)
+	_ = rejected.Body.Close()
+	require.EqualValues(http.StatusRequestEntityTooLarge, rejected.StatusCode)
+	downloaded, err := httpcli.New().Get(srv.URL+"/api/download").
+		Header("x-application-token", "token").
+		StatusCodeToError().
+		Do(s.T().Context())
+	require.NoError(err)
+	downloadedBody, err := downloaded.BodyCopy()
+	require.NoError(err)
+	require.EqualValues(image, downloadedBody)
+
+	data, err := os.ReadFile(logFile)
+	require.NoError(err)
+	records := make(map[string]map[string]any)
+	for line := range strings.Lines(string(data)) {
+		record := make(map[string]any)
+		err := json.Unmarshal([]byte(line), &record)
+		require.NoError(err)
+		if record["msg"] == "log request" {
+			records[record["endpoint"].(string)] = record
+		}
+	}
+
+	require.EqualValues(`{"password":"***"...[truncated, 16 of 131 bytes]`, records["upload"]["request"])
+	require.EqualValues(`{"ok":true}`, records["upload"]["response"])
+	require.Contains(records["reject"]["request"], "[truncated")
+	imageHash := sha256.Sum256(image)
+	require.EqualValues(
+		"[binary body: image/png, 256 bytes, sha256: "+hex.EncodeToString(imageHash[:])+"]",
+		records["download"]["response"],
+	)
+}
+
+func (s *HappyPathTestSuite) TestLoggingOverrides() {
+	test, require := test.New(s.T())
+	config, systemCli, adminCli := s.commonDependencies(test)
+	config.Logging.LogLevel = log.InfoLevel
+	config.Logging.RequestLogEnable = false
+	config.Logging.BodyLogEnable = false
+	config.Logging.Overrides = []conf.LoggingOverride{{
+		EndpointPrefix:   "expired",
+		RequestLogEnable: true,
+		ExpireAt:         time.Now().Add(-time.Minute),
+	}, {
+		ApplicationId:    4,
+		RequestLogEnable: true,
+		BodyLogEnable:    true,
+		LogLevel:         log.InfoLevel,
+	}}
+
+	targetService, targetCli := grpct.NewMock(test)
+	targetService.Mock("debug", func(req request) response {
+		return response{Id: req.Id}
+	})
+	logFile := s.T().TempDir() + "/gate.log"
+	logger, err := log.New(
+		log.WithLevel(log.InfoLevel),
+		log.WithDisableDefaultOutput(),
+		log.WithFileOutput(file.Output{File: logFile}),
+	)
+	require.NoError(err)
+	routes := routes.NewRoutes(logger)
+	err = routes.ReceiveRoutes(s.T().Context(), cluster.RoutingConfig{{
+		ModuleName: "target",
+		Endpoints:  []cluster.EndpointDescriptor{{Path: "debug"}},
+	}})
+	require.NoError(err)
+
+	locator := assembly.NewLocator(assembly.LocatorDeps{
+		Logger:          logger,
+		GrpcClients:     map[string]*client.Client{"target": targetCli},
+		Routes:          routes,
+		SystemCli:       systemCli,
+		AdminCli:        adminCli,
+		AppAuthCache:    cache.New(),
+		AppSecretCache:  cache.New(),
+		RevocationCache: cache.New(),
+		AdminCache:      cache.New(),
+	})
+	handler, err := locator.Handler(config, []conf.Location{{
+		PathPrefix:   "/api",
+		Protocol:     "grpc",
+		TargetModule: "target",
+	}})
+	require.NoError(err)
+	srv := httptest.NewServer(handler)
+
+	err = httpcli.New().Post(srv.URL+"/api/debug").
+		Header("x-application-token", "token").
+		JsonRequestBody(request{Id: "debug-id"}).
+		StatusCodeToError().
+		DoWithoutResponse(s.T().Context())
+	require.NoError(err)
+
+	data, err := os.ReadFile(logFile)
+	require.NoError(err)
+	record := make(map[string]any)
+	for line := range strings.Lines(string(data)) {
+		lineRecord := make(map[string]any)
+		err := json.Unmarshal([]byte(line), &lineRecord)
+		require.NoError(err)
+		if lineRecord["msg"] == "log request" {
+			record = lineRecord
+		}
+	}
+	require.EqualValues("info", record["level"])
+	require.EqualValues("debug", record["endpoint"])
+	require.JSONEq(`{"id":"debug-id"}`, record["request"].(string))
+	require.JSONEq(`{"id":"debug-id"}`, record["response"].(string))
+}
+
+func (s *HappyPathTestSuite) TestTracing() { // nolint:funlen
+	test, require := test.New(s.T())
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	defaultProvider := tracing.DefaultProvider
+	tracing.DefaultProvider = provider
+	s.T().Cleanup(func() {
+		tracing.DefaultProvider = defaultProvider
+		_ = provider.Shutdown(context.Background())
+	})
+	config, systemCli, adminCli := s.commonDependencies(test)
+
+	traceparent := make(chan string, 1)
+	targetService, targetCli := grpct.NewMock(test)
+	targetService.Mock("endpoint", func(ctx context.Context, req request) response {
+		md, _ := metadata.FromIncomingContext(ctx)
+		traceparent <- strings.Join(md.Get("traceparent"), "")
+		return response{Id: req.Id}
+	})
+
+	logger, err := log.New(log.WithLevel(log.DebugLevel))
+	require.NoError(err)
+	routes := routes.NewRoutes(logger)
+	err = routes.ReceiveRoutes(s.T().Context(), cluster.RoutingConfig{{
+		ModuleName: "target",
+		Endpoints:  []cluster.EndpointDescriptor{{Path: "endpoint"}},
+	}})
+	require.NoError(err)
+
+	locator := assembly.NewLocator(assembly.LocatorDeps{
+		Logger:          logger,
+		GrpcClients:     map[string]*client.Client{"target": targetCli},
+		Routes:          routes,
+		SystemCli:       systemCli,
+		AdminCli:        adminCli,
+		AppAuthCache:    cache.New(),
+		AppSecretCache:  cache.New(),
+		RevocationCache: cache.New(),
+		AdminCache:      cache.New(),
 	})
+	handler, err := locator.Handler(config, []conf.Location{{
+		PathPrefix:   "/api",
+		Protocol:     "grpc",
+		TargetModule: "target",
+	}})
 	require.NoError(err)
-
 	srv := httptest.NewServer(handler)
-	cli := httpcli.New()
-	req := request{Id: uuid.New().String()}
-	resp := response{}
-	err = cli.Post(srv.URL+"/api/endpoint").
+
+	incomingTraceId := "4bf92f3577b34da6a3ce929d0e0e4736"
+	err = httpcli.New().Post(srv.URL+"/api/endpoint").
 		Header("x-application-token", "token").
-		Header("test-token-header", userAuthToken).
-		Header("x-auth-admin", "mock-token").
-		JsonRequestBody(req).
-		JsonResponseBody(&resp).
+		Header("traceparent", "00-"+incomingTraceId+"-00f067aa0ba902b7-01").
+		JsonRequestBody(request{Id: uuid.New().String()}).
 		StatusCodeToError().
 		DoWithoutResponse(s.T().Context())
 	require.NoError(err)
-	require.EqualValues(req.Id, resp.Id)
 
-	err = cli.Post(srv.URL+"/api/failed_auth_endpoint").
-		Header("x-application-token", "token").
-		Header("test-token-header", userAuthToken).
-		Header("x-auth-admin", "mock-token").
-		JsonRequestBody(req).
-		JsonResponseBody(&resp).
-		StatusCodeToError().
-		DoWithoutResponse(s.T().Context())
-	errResp := httpcli.ErrorResponse{}
-	require.ErrorAs(err, &errResp)
-	require.EqualValues(http.StatusUnauthorized, errResp.StatusCode)
+	spanByName := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().TraceID().String() == incomingTraceId {
+			spanByName[span.Name()] = span
+		}
+	}
+	serverSpan, ok := spanByName["POST endpoint"]
+	require.True(ok)
+	require.EqualValues(trace.SpanKindServer, serverSpan.SpanKind())
+	require.Contains(serverSpan.Attributes(), attribute.Int("http.status_code", http.StatusOK))
+
+	authenticateSpan, ok := spanByName["authenticate"]
+	require.True(ok)
+	require.EqualValues(serverSpan.SpanContext().SpanID(), authenticateSpan.Parent().SpanID())
+	authorizeSpan, ok := spanByName["authorize"]
+	require.True(ok)
+	require.EqualValues(serverSpan.SpanContext().SpanID(), authorizeSpan.Parent().SpanID())
+
+	upstreamSpan, ok := spanByName["proxy target"]
+	require.True(ok)
+	require.EqualValues(trace.SpanKindClient, upstreamSpan.SpanKind())
+	require.EqualValues(serverSpan.SpanContext().SpanID(), upstreamSpan.Parent().SpanID())
+	require.EqualValues(
+		"00-"+incomingTraceId+"-"+upstreamSpan.SpanContext().SpanID().String()+"-01",
+		<-traceparent,
+	)
 }
 
-func (s *HappyPathTestSuite) TestUserAuthorization_SkipAppAuth() { // nolint:funlen
+func (s *HappyPathTestSuite) TestGateMetrics() { // nolint:funlen
 	test, require := test.New(s.T())
-	config, _, adminCli := s.commonDependencies(test)
-	systemService, systemCli := grpct.NewMock(test)
-	systemService.Mock("system/secure/authenticate", func() entity.AuthenticateResponse {
+	config, systemCli, adminCli := s.commonDependencies(test)
+
+	targetService, targetCli := grpct.NewMock(test)
+	targetService.Mock("endpoint", func(req request) response {
+		return response{Id: req.Id}
+	})
+
+	logger, err := log.New(log.WithLevel(log.DebugLevel))
+	require.NoError(err)
+	routes := routes.NewRoutes(logger)
+	err = routes.ReceiveRoutes(s.T().Context(), cluster.RoutingConfig{{
+		ModuleName: "metrics-target",
+		Endpoints:  []cluster.EndpointDescriptor{{Path: "endpoint"}},
+	}})
+	require.NoError(err)
+
+	gateMetrics := gatemetrics.NewStorage(metrics.DefaultRegistry)
+	newHandler := func(config conf.Remote, systemCli *client.Client) http.Handler {
+		locator := assembly.NewLocator(assembly.LocatorDeps{
+			Logger:          logger,
+			GrpcClients:     map[string]*client.Client{"metrics-target": targetCli},
+			Routes:          routes,
+			SystemCli:       systemCli,
+			AdminCli:        adminCli,
+			AppAuthCache:    cache.New(),
+			AppSecretCache:  cache.New(),
+			RevocationCache: cache.New(),
+			AdminCache:      cache.New(),
+			GateMetrics:     gateMetrics,
+		})
+		handler, err := locator.Handler(config, []conf.Location{{
+			PathPrefix:   "/metrics-api",
+			Protocol:     "grpc",
+			TargetModule: "metrics-target",
+		}})
+		require.NoError(err)
+		return handler
+	}
+	srv := httptest.NewServer(newHandler(config, systemCli))
+
+	authorizationHits := metricValue(require, "gate_cache_request_count", map[string]string{"cache": "authorization", "result": "hit"})
+	for range 2 {
+		err = httpcli.New().Post(srv.URL+"/metrics-api/endpoint").
+			Header("x-application-token", "token").
+			JsonRequestBody(request{Id: uuid.New().String()}).
+			StatusCodeToError().
+			DoWithoutResponse(s.T().Context())
+		require.NoError(err)
+	}
+	resp, err := httpcli.New().Post(srv.URL + "/metrics-api/endpoint").
+		JsonRequestBody(request{Id: uuid.New().String()}).
+		Do(s.T().Context())
+	require.NoError(err)
+	require.EqualValues(http.StatusUnauthorized, resp.StatusCode())
+	resp, err = httpcli.New().Post(srv.URL+"/metrics-api/unknown").
+		Header("x-application-token", "token").
+		Do(s.T().Context())
+	require.NoError(err)
+	require.EqualValues(http.StatusNotImplemented, resp.StatusCode())
+
+	require.EqualValues(2, metricValue(require, "gate_request_count", map[string]string{
+		"location":       "/metrics-api",
+		"target_module":  "metrics-target",
+		"application_id": "4",
+		"status_class":   "2xx",
+		"stage":          "proxy",
+	}))
+	require.EqualValues(1, metricValue(require, "gate_request_count", map[string]string{
+		"location":       "/metrics-api",
+		"target_module":  "metrics-target",
+		"application_id": "none",
+		"status_class":   "4xx",
+		"stage":          "auth",
+	}))
+	require.EqualValues(1, metricValue(require, "gate_unknown_endpoint_count", map[string]string{
+		"location": "/metrics-api",
+	}))
+	require.EqualValues(
+		authorizationHits+1,
+		metricValue(require, "gate_cache_request_count", map[string]string{"cache": "authorization", "result": "hit"}),
+	)
+
+	// приложения,получившие отдельную метку,учитываются в лимите после обновления конфигурации
+	config.Metrics.ApplicationIdsLimit = 1
+	otherSystemService, otherSystemCli := grpct.NewMock(test)
+	otherSystemService.Mock("system/secure/authenticate", func() entity.AuthenticateResponse {
 		return entity.AuthenticateResponse{
-			Authenticated: false,
-			ErrorReason:   "",
+			Authenticated: true,
+			AuthData:      &entity.AppAuthData{ApplicationId: 5, AppName: "other"},
 		}
 	}).Mock("system/secure/authorize", func() entity.AuthorizeResponse {
-		return entity.AuthorizeResponse{Authorized: false}
+		return entity.AuthorizeResponse{Authorized: true}
 	})
+	reloadedSrv := httptest.NewServer(newHandler(config, otherSystemCli))
+	err = httpcli.New().Post(reloadedSrv.URL+"/metrics-api/endpoint").
+		Header("x-application-token", "other-token").
+		JsonRequestBody(request{Id: uuid.New().String()}).
+		StatusCodeToError().
+		DoWithoutResponse(s.T().Context())
+	require.NoError(err)
+	require.EqualValues(1, metricValue(require, "gate_request_count", map[string]string{
+		"location":       "/metrics-api",
+		"target_module":  "metrics-target",
+		"application_id": "other",
+		"status_class":   "2xx",
+		"stage":          "proxy",
+	}))
+}
 
-	userAuthData := fake.It[entity.UserAuthData]()
-	userAuthData.IdentityHeader = "x-" + userAuthData.IdentityHeader
+func (s *HappyPathTestSuite) TestServerTiming() { // nolint:funlen
+	test, require := test.New(s.T())
+	config, systemCli, adminCli := s.commonDependencies(test)
+	config.ServerTiming = conf.ServerTiming{
+		Enable:         true,
+		ApplicationIds: []int{4},
+	}
+	config.Logging.SlowRequestThresholdInMs = 10
 
 	targetService, targetCli := grpct.NewMock(test)
-	targetService.Mock("endpoint", func(ctx context.Context, authData grpc.AuthData, req request) response {
-		md, ok := metadata.FromIncomingContext(ctx)
-		require.True(ok)
-		require.ElementsMatch([]string{userAuthData.Identity}, md.Get(userAuthData.IdentityHeader))
-
+	targetService.Mock("endpoint", func(req request) response {
+		time.Sleep(20 * time.Millisecond)
 		return response{Id: req.Id}
 	})
-
-	routerMux := router.New()
-	defaultWrapper := endpoint.DefaultWrapper(
-		test.Logger(),
-		httplog.Noop(),
+	logFile := s.T().TempDir() + "/gate.log"
+	logger, err := log.New(
+		log.WithLevel(log.InfoLevel),
+		log.WithDisableDefaultOutput(),
+		log.WithFileOutput(file.Output{File: logFile}),
 	)
+	require.NoError(err)
+	routes := routes.NewRoutes(logger)
+	err = routes.ReceiveRoutes(s.T().Context(), cluster.RoutingConfig{{
+		ModuleName: "target",
+		Endpoints:  []cluster.EndpointDescriptor{{Path: "endpoint"}},
+	}})
+	require.NoError(err)
 
-	userAuthToken := fake.It[string]()
+	locator := assembly.NewLocator(assembly.LocatorDeps{
+		Logger:          logger,
+		GrpcClients:     map[string]*client.Client{"target": targetCli},
+		Routes:          routes,
+		SystemCli:       systemCli,
+		AdminCli:        adminCli,
+		AppAuthCache:    cache.New(),
+		AppSecretCache:  cache.New(),
+		RevocationCache: cache.New(),
+		AdminCache:      cache.New(),
+	})
+	handler, err := locator.Handler(config, []conf.Location{{
+		PathPrefix:   "/api",
+		Protocol:     "grpc",
+		TargetModule: "target",
+	}})
+	require.NoError(err)
+	srv := httptest.NewServer(handler)
 
-	routerMux.POST("/test-user-auth/authenticate",
-		defaultWrapper.Endpoint(func(req entity.UserAuthenticateRequest) entity.UserAuthenticateResponse {
-			require.Equal(userAuthToken, req.Token)
-			return entity.UserAuthenticateResponse{
-				Authenticated: true,
-				AuthData:      &userAuthData,
-			}
-		}))
-	routerMock := httptest.NewServer(routerMux)
-	targetUrl, err := url.Parse(routerMock.URL)
+	resp, err := httpcli.New().Post(srv.URL+"/api/endpoint").
+		Header("x-application-token", "token").
+		JsonRequestBody(request{Id: uuid.New().String()}).
+		StatusCodeToError().
+		Do(s.T().Context())
 	require.NoError(err)
-	rr := lb.NewRoundRobin([]string{targetUrl.Host})
+	serverTiming := make(map[string]float64)
+	for metric := range strings.SplitSeq(resp.Raw.Header.Get("Server-Timing"), ", ") {
+		name, duration, ok := strings.Cut(metric, ";dur=")
+		require.True(ok)
+		serverTiming[name], err = strconv.ParseFloat(duration, 64)
+		require.NoError(err)
+	}
+	for _, name := range []string{"resolve", "app_auth", "authz", "upstream", "total"} {
+		require.Contains(serverTiming, name)
+	}
+	require.GreaterOrEqual(serverTiming["upstream"], 20.0)
+	require.GreaterOrEqual(serverTiming["total"], serverTiming["upstream"])
 
-	targetClients := map[string]*client.Client{"target": targetCli}
-	logger, err := log.New(log.WithLevel(log.DebugLevel))
+	resp, err = httpcli.New().Post(srv.URL + "/api/endpoint").
+		JsonRequestBody(request{Id: uuid.New().String()}).
+		Do(s.T().Context())
+	require.NoError(err)
+	require.EqualValues(http.StatusUnauthorized, resp.StatusCode())
+	require.Empty(resp.Raw.Header.Get("Server-Timing"))
+
+	data, err := os.ReadFile(logFile)
+	require.NoError(err)
+	slowRequests := make([]map[string]any, 0)
+	for line := range strings.Lines(string(data)) {
+		record := make(map[string]any)
+		err := json.Unmarshal([]byte(line), &record)
+		require.NoError(err)
+		if record["msg"] == "slow request" {
+			slowRequests = append(slowRequests, record)
+		}
+	}
+	require.Len(slowRequests, 1)
+	require.EqualValues("endpoint", slowRequests[0]["endpoint"])
+	require.EqualValues(4, slowRequests[0]["applicationId"])
+	timings, ok := slowRequests[0]["timingsMs"].(map[string]any)
+	require.True(ok)
+	require.Contains(timings, "upstream")
+	require.Contains(timings, "total")
+}
+
+func (s *HappyPathTestSuite) TestHttpProxy_ServerSentEvents() {
+	test, require := test.New(s.T())
+	config, systemCli, adminCli := s.commonDependencies(test)
+	config.Logging.BodyLogEnable = true
+	config.ServerTiming.Enable = true
+
+	release := make(chan struct{})
+	targetService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, httpReq *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("data: first\n\n"))
+		_ = http.NewResponseController(w).Flush()
+		select {
+		case <-release:
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer targetService.Close()
+	targetUrl, err := url.Parse(targetService.URL)
+	require.NoError(err)
+	targetClients := map[string]*lb.RoundRobin{"target": lb.NewRoundRobin([]string{targetUrl.Host})}
+
+	routes := routes.NewRoutes(test.Logger())
+	err = routes.ReceiveRoutes(s.T().Context(), cluster.RoutingConfig{{
+		Endpoints: []cluster.EndpointDescriptor{{
+			Path: "/events",
+		}},
+	}})
 	require.NoError(err)
-	routes := routes.NewRoutes(logger)
-	locator := assembly.NewLocator(logger, targetClients, nil, routes, systemCli, adminCli, nil, rr, cache.New())
 
+	locator := assembly.NewLocator(assembly.LocatorDeps{
+		Logger:           test.Logger(),
+		HttpHostManagers: targetClients,
+		Routes:           routes,
+		SystemCli:        systemCli,
+		AdminCli:         adminCli,
+		AppAuthCache:     cache.New(),
+		AppSecretCache:   cache.New(),
+		RevocationCache:  cache.New(),
+		AdminCache:       cache.New(),
+	})
 	locations := []conf.Location{{
-		SkipAuth:     false,
 		PathPrefix:   "/api",
-		Protocol:     "grpc",
+		Protocol:     "http",
 		TargetModule: "target",
 	}}
-	config.CustomAuth = conf.CustomAuth{
-		TokenProviders: []conf.TokenProvider{
-			{
-				Name: "test_provider",
-				Type: conf.HeaderTokenProviderType,
-				HeaderProvider: &conf.HeaderTokenProvider{
-					HeaderName: "test-token-header",
-				},
-			},
-		},
-		UserAuthSettings: []conf.UserAuthSetting{
-			{
-				ModuleNameList:       []string{"target"},
-				TokenProviders:       []string{"test_provider"},
-				AuthenticateEndpoint: "test-user-auth/authenticate",
-				SkipAppAuth:          true,
-			},
-			{
-				ModuleNameList:       []string{"target2"},
-				TokenProviders:       []string{"test_provider"},
-				AuthenticateEndpoint: "test-user-auth/authenticate",
-			},
-		},
-	}
 	handler, err := locator.Handler(config, locations)
 	require.NoError(err)
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+	defer close(release)
 
-	err = routes.ReceiveRoutes(context.Background(), cluster.RoutingConfig{{
+	httpReq, err := http.NewRequestWithContext(s.T().Context(), http.MethodGet, srv.URL+"/api/events", nil)
+	require.NoError(err)
+	httpReq.Header.Set("x-application-token", "token")
+
+	// заголовки и событие должны прийти до завершения обработчика upstream
+	type result struct {
+		resp *http.Response
+		line string
+		err  error
+	}
+	results := make(chan result, 1)
+	go func() {
+		resp, err := http.DefaultClient.Do(httpReq) // nolint:bodyclose
+		if err != nil {
+			results <- result{err: err}
+			return
+		}
+		line, err := bufio.NewReader(resp.Body).ReadString('\n')
+		results <- result{resp: resp, line: line, err: err}
+	}()
+	select {
+	case res := <-results:
+		require.NoError(res.err)
+		defer res.resp.Body.Close()
+		require.EqualValues(http.StatusOK, res.resp.StatusCode)
+		require.NotEmpty(res.resp.Header.Get("x-request-id"))
+		require.EqualValues("data: first\n", res.line)
+	case <-time.After(2 * time.Second):
+		require.Fail("event is not flushed")
+	}
+}
+
+func (s *HappyPathTestSuite) TestRequestIdEcho() { // nolint:funlen
+	test, require := test.New(s.T())
+	config, systemCli, adminCli := s.commonDependencies(test)
+	config.EnableClientRequestIdForwarding = false
+	config.RequestId = conf.RequestId{Format: conf.UlidRequestIdFormat, Prefix: "gate-"}
+
+	upstreamRequestId := make(chan string, 1)
+	targetService := httpt.NewMock(test)
+	targetService.POST("/endpoint", func(ctx context.Context, httpReq *http.Request, req request) response {
+		upstreamRequestId <- httpReq.Header.Get("x-request-id")
+		return response{Id: req.Id}
+	})
+	targetUrl, err := url.Parse(targetService.BaseURL())
+	require.NoError(err)
+	wsMux := http.NewServeMux()
+	wsMux.HandleFunc("/service", func(writer http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(writer, r, nil)
+		if err == nil {
+			_ = conn.Close()
+		}
+	})
+	wsTarget := httptest.NewServer(wsMux)
+	wsTargetUrl, err := url.Parse(wsTarget.URL)
+	require.NoError(err)
+
+	routes := routes.NewRoutes(test.Logger())
+	err = routes.ReceiveRoutes(s.T().Context(), cluster.RoutingConfig{{
 		ModuleName: "target",
-		Endpoints: []cluster.EndpointDescriptor{{
-			Path:             "endpoint",
-			Inner:            true,
-			UserAuthRequired: true,
-		}}},
-		{
-			ModuleName: "target2",
-			Endpoints: []cluster.EndpointDescriptor{{
-				Path:             "failed_endpoint",
-				Inner:            true,
-				UserAuthRequired: true,
-			}},
+		Endpoints:  []cluster.EndpointDescriptor{{Path: "/endpoint"}},
+	}, {
+		ModuleName: "ws-target",
+		Endpoints:  []cluster.EndpointDescriptor{{Path: "/service"}},
+	}})
+	require.NoError(err)
+
+	locator := assembly.NewLocator(assembly.LocatorDeps{
+		Logger: test.Logger(),
+		HttpHostManagers: map[string]*lb.RoundRobin{
+			"target":    lb.NewRoundRobin([]string{targetUrl.Host}),
+			"ws-target": lb.NewRoundRobin([]string{wsTargetUrl.Host}),
 		},
+		Routes:          routes,
+		SystemCli:       systemCli,
+		AdminCli:        adminCli,
+		AppAuthCache:    cache.New(),
+		AppSecretCache:  cache.New(),
+		RevocationCache: cache.New(),
+		AdminCache:      cache.New(),
 	})
+	handler, err := locator.Handler(config, []conf.Location{{
+		PathPrefix:   "/api",
+		Protocol:     "http",
+		TargetModule: "target",
+	}, {
+		PathPrefix:   "/ws",
+		Protocol:     "ws",
+		TargetModule: "ws-target",
+	}})
 	require.NoError(err)
-
 	srv := httptest.NewServer(handler)
-	cli := httpcli.New()
-	req := request{Id: uuid.New().String()}
-	resp := response{}
-	err = cli.Post(srv.URL+"/api/endpoint").
+
+	resp, err := httpcli.New().Post(srv.URL+"/api/endpoint").
 		Header("x-application-token", "token").
-		Header("test-token-header", userAuthToken).
-		Header("x-auth-admin", "mock-token").
-		JsonRequestBody(req).
-		JsonResponseBody(&resp).
+		Header("x-request-id", "client-request-id").
+		JsonRequestBody(request{Id: uuid.New().String()}).
 		StatusCodeToError().
-		DoWithoutResponse(s.T().Context())
+		Do(s.T().Context())
 	require.NoError(err)
-	require.EqualValues(req.Id, resp.Id)
+	requestId := resp.Raw.Header.Get("x-request-id")
+	require.Regexp(`^gate-[0-9A-HJKMNP-TV-Z]{26}$`, requestId)
+	require.EqualValues(requestId, <-upstreamRequestId)
+	require.Len(resp.Raw.Header.Values("x-request-id"), 1)
 
-	err = cli.Post(srv.URL+"/api/failed_endpoint").
+	errorBody := func(resp *httpcli.Response) map[string]any {
+		body, err := resp.BodyCopy()
+		require.NoError(err)
+		result := make(map[string]any)
+		err = json.Unmarshal(body, &result)
+		require.NoError(err)
+		return result
+	}
+	resp, err = httpcli.New().Post(srv.URL + "/api/endpoint").
+		JsonRequestBody(request{Id: uuid.New().String()}).
+		Do(s.T().Context())
+	require.NoError(err)
+	require.EqualValues(http.StatusUnauthorized, resp.StatusCode())
+	requestId = resp.Raw.Header.Get("x-request-id")
+	require.Regexp(`^gate-`, requestId)
+	require.EqualValues(requestId, errorBody(resp)["requestId"])
+
+	resp, err = httpcli.New().Post(srv.URL+"/api/unknown").
 		Header("x-application-token", "token").
-		Header("test-token-header", userAuthToken).
-		Header("x-auth-admin", "mock-token").
-		JsonRequestBody(req).
-		JsonResponseBody(&resp).
-		StatusCodeToError().
-		DoWithoutResponse(s.T().Context())
-	errResp := httpcli.ErrorResponse{}
-	require.ErrorAs(err, &errResp)
-	require.EqualValues(http.StatusUnauthorized, errResp.StatusCode)
+		Do(s.T().Context())
+	require.NoError(err)
+	require.EqualValues(http.StatusNotImplemented, resp.StatusCode())
+	requestId = resp.Raw.Header.Get("x-request-id")
+	require.Regexp(`^gate-`, requestId)
+	require.EqualValues(requestId, errorBody(resp)["requestId"])
+
+	wsUrl := strings.Replace(srv.URL, "http://", "ws://", 1) + "/ws/service?x-application-token=token"
+	conn, wsResp, err := websocket.DefaultDialer.DialContext(s.T().Context(), wsUrl, nil)
+	require.NoError(err)
+	require.Regexp(`^gate-`, wsResp.Header.Get("x-request-id"))
+	require.NoError(conn.Close())
 }
 
-// nolint:ireturn
 func (s *HappyPathTestSuite) commonDependencies(test *test.Test) (conf.Remote, *client.Client, *client.Client) {
 	config := conf.Remote{
 		Http: conf.Http{MaxRequestBodySizeInMb: 1, ProxyTimeoutInSec: 15},
@@ -651,3 +3185,52 @@ func assertHeaders(require *require.Assertions, requestId string, ctx context.Co
 	require.NoError(err)
 	require.EqualValues(1, adminId)
 }
+
+// pubSubMock pub/sub isp-lock-service в памяти,первая публикация завершается ошибкой
+func pubSubMock(test *test.Test) (*client.Client, *atomic.Int32) {
+	lock := sync.Mutex{}
+	messages := make([]entity.PubSubMessage, 0)
+	publishCalls := &atomic.Int32{}
+	lockService, lockerCli := grpct.NewMock(test)
+	lockService.Mock("isp-lock-service/pubsub/publish", func(req entity.PublishRequest) error {
+		if publishCalls.Add(1) == 1 {
+			return status.Error(codes.Unavailable, "not available")
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		messages = append(messages, entity.PubSubMessage{Id: int64(len(messages) + 1), Data: req.Data})
+		return nil
+	}).Mock("isp-lock-service/pubsub/poll", func(req entity.PollRequest) entity.PollResponse {
+		lock.Lock()
+		defer lock.Unlock()
+		return entity.PollResponse{
+			Messages: slices.Clone(messages[req.AfterId:]),
+			LastId:   int64(len(messages)),
+		}
+	})
+	return lockerCli, publishCalls
+}
+
+func metricValue(require *require.Assertions, name string, labels map[string]string) float64 {
+	families, err := metrics.DefaultRegistry.Gather()
+	require.NoError(err)
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			metricLabels := make(map[string]string, len(metric.GetLabel()))
+			for _, label := range metric.GetLabel() {
+				metricLabels[label.GetName()] = label.GetValue()
+			}
+			if !maps.Equal(labels, metricLabels) {
+				continue
+			}
+			if metric.GetCounter() != nil {
+				return metric.GetCounter().GetValue()
+			}
+			return metric.GetGauge().GetValue()
+		}
+	}
+	return 0
+}
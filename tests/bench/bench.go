@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	"isp-gate-service/assembly"
+	"isp-gate-service/cache"
 	"isp-gate-service/conf"
 	"isp-gate-service/domain"
 	"isp-gate-service/entity"
@@ -86,7 +87,17 @@ func main() {
 	})
 	targetClients := map[string]*client.Client{"target": targetCli}
 	logger, _ = log.New(log.WithLevel(log.DebugLevel))
-	locator := assembly.NewLocator(logger, targetClients, nil, routes.NewRoutes(logger), systemCli, adminCli, nil, nil, nil)
+	locator := assembly.NewLocator(assembly.LocatorDeps{
+		Logger:          logger,
+		GrpcClients:     targetClients,
+		Routes:          routes.NewRoutes(logger),
+		SystemCli:       systemCli,
+		AdminCli:        adminCli,
+		AppAuthCache:    cache.New(),
+		AppSecretCache:  cache.New(),
+		RevocationCache: cache.New(),
+		AdminCache:      cache.New(),
+	})
 	locations := []conf.Location{{
 		SkipAuth:     false,
 		PathPrefix:   "/api",